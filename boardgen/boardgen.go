@@ -6,54 +6,42 @@ import (
 	"github.com/bcspragu/Codenames/codenames"
 )
 
-var baseAgents = []codenames.Agent{
-	codenames.RedAgent,
-	codenames.RedAgent,
-	codenames.RedAgent,
-	codenames.RedAgent,
-	codenames.RedAgent,
-	codenames.RedAgent,
-	codenames.RedAgent,
-	codenames.RedAgent,
-	codenames.BlueAgent,
-	codenames.BlueAgent,
-	codenames.BlueAgent,
-	codenames.BlueAgent,
-	codenames.BlueAgent,
-	codenames.BlueAgent,
-	codenames.BlueAgent,
-	codenames.BlueAgent,
-	codenames.Bystander,
-	codenames.Bystander,
-	codenames.Bystander,
-	codenames.Bystander,
-	codenames.Bystander,
-	codenames.Bystander,
-	codenames.Bystander,
-	codenames.Assassin,
-}
-
+// New returns a standard board for a red vs. blue game, where starter goes
+// first.
 func New(starter codenames.Team, r *rand.Rand) *codenames.Board {
-	agents := make([]codenames.Agent, len(baseAgents))
-	copy(agents, baseAgents)
+	return NewFromSpec(codenames.StandardBoard, codenames.DefaultTeams, starter, r)
+}
 
-	switch starter {
-	case codenames.RedTeam:
-		agents = append(agents, codenames.RedAgent)
-	case codenames.BlueTeam:
-		agents = append(agents, codenames.BlueAgent)
-	}
+// NewWithTeams returns a board for a game with the given teams, where starter
+// goes first. Two team games get the standard board from New, three team
+// games get a codenames.ThreeTeamBoard.
+func NewWithTeams(teams []codenames.Team, starter codenames.Team, r *rand.Rand) *codenames.Board {
+	return NewFromSpec(codenames.DefaultBoardSpec(teams), teams, starter, r)
+}
 
-	// Pick words at random from our list.
-	used := make(map[string]struct{})
-	var selected []string
-	for len(used) < codenames.Size {
-		word := codenames.Words[r.Intn(len(codenames.Words))]
-		if _, ok := used[word]; !ok {
-			used[word] = struct{}{}
-			selected = append(selected, word)
+// NewFromSpec returns a board laid out like the given spec, for a game with
+// the given teams, where starter goes first. The spec should already be
+// validated.
+func NewFromSpec(spec *codenames.BoardSpec, teams []codenames.Team, starter codenames.Team, r *rand.Rand) *codenames.Board {
+	var agents []codenames.Agent
+	add := func(n int, agent codenames.Agent) {
+		for i := 0; i < n; i++ {
+			agents = append(agents, agent)
 		}
 	}
+	for _, team := range teams {
+		add(spec.Agents, codenames.AgentForTeam(team))
+	}
+	add(spec.Bystanders, codenames.Bystander)
+	add(spec.Assassins, codenames.Assassin)
+	add(spec.StarterAgents, codenames.AgentForTeam(starter))
+
+	return deal(agents, r)
+}
+
+// deal shuffles the given agents onto a board of random words.
+func deal(agents []codenames.Agent, r *rand.Rand) *codenames.Board {
+	selected := randomWords(r, len(agents))
 
 	var cards []codenames.Card
 	for i, idx := range r.Perm(len(agents)) {
@@ -65,3 +53,64 @@ func New(starter codenames.Team, r *rand.Rand) *codenames.Board {
 
 	return &codenames.Board{Cards: cards}
 }
+
+// duetKey is one card in a Duet key, from each side's point of view.
+type duetKey struct {
+	red, blue codenames.Agent
+}
+
+// duetKeys is the layout of the Duet key card: each side has 9 agents, 3
+// assassins, and 13 bystanders, and 15 agents in total between them.
+var duetKeys = func() []duetKey {
+	var keys []duetKey
+	add := func(n int, red, blue codenames.Agent) {
+		for i := 0; i < n; i++ {
+			keys = append(keys, duetKey{red: red, blue: blue})
+		}
+	}
+	add(3, codenames.RedAgent, codenames.BlueAgent)
+	add(1, codenames.Assassin, codenames.Assassin)
+	add(1, codenames.RedAgent, codenames.Assassin)
+	add(1, codenames.Assassin, codenames.BlueAgent)
+	add(1, codenames.Assassin, codenames.Bystander)
+	add(1, codenames.Bystander, codenames.Assassin)
+	add(5, codenames.RedAgent, codenames.Bystander)
+	add(5, codenames.Bystander, codenames.BlueAgent)
+	add(7, codenames.Bystander, codenames.Bystander)
+	return keys
+}()
+
+// NewDuet returns a board for a Duet game, with a key card for each side. The
+// red side's agents are RedAgents and the blue side's are BlueAgents, but
+// they're all working together.
+func NewDuet(r *rand.Rand) *codenames.Board {
+	selected := randomWords(r, len(duetKeys))
+
+	b := &codenames.Board{
+		Keys: map[codenames.Team][]codenames.Agent{
+			codenames.RedTeam:  make([]codenames.Agent, len(duetKeys)),
+			codenames.BlueTeam: make([]codenames.Agent, len(duetKeys)),
+		},
+	}
+	for i, idx := range r.Perm(len(duetKeys)) {
+		b.Cards = append(b.Cards, codenames.Card{Codename: selected[i]})
+		b.Keys[codenames.RedTeam][i] = duetKeys[idx].red
+		b.Keys[codenames.BlueTeam][i] = duetKeys[idx].blue
+	}
+
+	return b
+}
+
+// randomWords picks n unique words at random from our list.
+func randomWords(r *rand.Rand, n int) []string {
+	used := make(map[string]struct{})
+	var selected []string
+	for len(used) < n {
+		word := codenames.Words[r.Intn(len(codenames.Words))]
+		if _, ok := used[word]; !ok {
+			used[word] = struct{}{}
+			selected = append(selected, word)
+		}
+	}
+	return selected
+}
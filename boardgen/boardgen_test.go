@@ -45,3 +45,111 @@ func TestNew(t *testing.T) {
 		t.Errorf("unexpected board (-want +got)\n%s", diff)
 	}
 }
+
+func TestNewDuet(t *testing.T) {
+	b := NewDuet(rand.New(rand.NewSource(0)))
+
+	if len(b.Cards) != codenames.Size {
+		t.Fatalf("got %d cards, want %d", len(b.Cards), codenames.Size)
+	}
+
+	agents := 0
+	for i, card := range b.Cards {
+		if card.Agent != codenames.UnknownAgent {
+			t.Errorf("card %q had agent %q, Duet cards should start unknown", card.Codename, card.Agent)
+		}
+		if b.Keys[codenames.RedTeam][i] == codenames.RedAgent || b.Keys[codenames.BlueTeam][i] == codenames.BlueAgent {
+			agents++
+		}
+	}
+	if agents != 15 {
+		t.Errorf("got %d agents between both keys, want 15", agents)
+	}
+
+	for team, agent := range map[codenames.Team]codenames.Agent{
+		codenames.RedTeam:  codenames.RedAgent,
+		codenames.BlueTeam: codenames.BlueAgent,
+	} {
+		got := make(map[codenames.Agent]int)
+		for _, a := range b.Keys[team] {
+			got[a]++
+		}
+		want := map[codenames.Agent]int{
+			agent:               9,
+			codenames.Assassin:  3,
+			codenames.Bystander: 13,
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected %q key (-want +got)\n%s", team, diff)
+		}
+	}
+}
+
+func TestNewWithTeams(t *testing.T) {
+	b := NewWithTeams(codenames.ThreeTeams, codenames.GreenTeam, rand.New(rand.NewSource(0)))
+
+	if len(b.Cards) != codenames.Size {
+		t.Fatalf("got %d cards, want %d", len(b.Cards), codenames.Size)
+	}
+
+	got := make(map[codenames.Agent]int)
+	for _, card := range b.Cards {
+		got[card.Agent]++
+	}
+	want := map[codenames.Agent]int{
+		codenames.GreenAgent: 7,
+		codenames.RedAgent:   6,
+		codenames.BlueAgent:  6,
+		codenames.Bystander:  5,
+		codenames.Assassin:   1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected agents (-want +got)\n%s", diff)
+	}
+}
+
+func TestNewFromSpec(t *testing.T) {
+	tests := []struct {
+		desc string
+		spec *codenames.BoardSpec
+		want map[codenames.Agent]int
+	}{
+		{
+			desc: "quick",
+			spec: codenames.QuickBoard,
+			want: map[codenames.Agent]int{
+				codenames.RedAgent:  6,
+				codenames.BlueAgent: 5,
+				codenames.Bystander: 4,
+				codenames.Assassin:  1,
+			},
+		},
+		{
+			desc: "deep",
+			spec: codenames.DeepBoard,
+			want: map[codenames.Agent]int{
+				codenames.RedAgent:  13,
+				codenames.BlueAgent: 12,
+				codenames.Bystander: 9,
+				codenames.Assassin:  2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			b := NewFromSpec(test.spec, codenames.DefaultTeams, codenames.RedTeam, rand.New(rand.NewSource(0)))
+			if len(b.Cards) != test.spec.Size() {
+				t.Fatalf("got %d cards, want %d", len(b.Cards), test.spec.Size())
+			}
+
+			got := make(map[codenames.Agent]int)
+			for _, card := range b.Cards {
+				got[card.Agent]++
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("unexpected agents (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
+	"strconv"
 
 	"github.com/bcspragu/Codenames/codenames"
 	"github.com/bcspragu/Codenames/web"
@@ -61,8 +63,39 @@ func (c *Client) CreateUser(name string, pt codenames.PlayerType) (string, error
 	return resp.UserID, nil
 }
 
-func (c *Client) CreateGame() (codenames.GameID, error) {
-	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game", nil)
+// GameOptions configure a new game. The zero value is a classic game.
+type GameOptions struct {
+	Mode codenames.GameMode `json:"mode,omitempty"`
+	// NumTeams is the number of teams in a classic game, either 2 or 3. Zero
+	// means 2.
+	NumTeams int `json:"num_teams,omitempty"`
+	// BoardSpec is the layout of the board in a classic game, like
+	// codenames.QuickBoard. Nil means the default board.
+	BoardSpec *codenames.BoardSpec `json:"board_spec,omitempty"`
+	// Board is the name of a preset board in a classic game, like "QUICK", as
+	// an alternative to BoardSpec.
+	Board string `json:"board,omitempty"`
+	// Timers is how long each role gets for their turn. Nil means turns
+	// aren't timed.
+	Timers *codenames.TurnTimers `json:"timers,omitempty"`
+	// AllowSpymasterSpectators lets spectators that ask for it see the whole
+	// board before the game is over.
+	AllowSpymasterSpectators bool `json:"allow_spymaster_spectators,omitempty"`
+	// LockTeams stops players from picking their own team and role, so only
+	// the game creator can assign them.
+	LockTeams bool `json:"lock_teams,omitempty"`
+	// Unlisted keeps the game out of the lobby, so players can only join it by
+	// ID.
+	Unlisted bool `json:"unlisted,omitempty"`
+}
+
+// CreateGame creates a new game with the given options, which can be nil.
+func (c *Client) CreateGame(opts *GameOptions) (codenames.GameID, error) {
+	if opts == nil {
+		opts = &GameOptions{}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game", toBody(opts))
 	if err != nil {
 		return "", fmt.Errorf("failed to form request: %w", err)
 	}
@@ -76,6 +109,19 @@ func (c *Client) CreateGame() (codenames.GameID, error) {
 	return codenames.GameID(resp.ID), nil
 }
 
+func (c *Client) Game(gID codenames.GameID) (*codenames.Game, error) {
+	req, err := http.NewRequest(http.MethodGet, c.scheme+"://"+c.addr+"/api/game/"+string(gID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to form request: %w", err)
+	}
+
+	var resp *codenames.Game
+	if err := c.do(req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to load game: %w", err)
+	}
+	return resp, nil
+}
+
 func (c *Client) Players(gID codenames.GameID) ([]*web.Player, error) {
 	req, err := http.NewRequest(http.MethodGet, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/players", nil)
 	if err != nil {
@@ -89,6 +135,99 @@ func (c *Client) Players(gID codenames.GameID) ([]*web.Player, error) {
 	return resp, nil
 }
 
+func (c *Client) History(gID codenames.GameID) ([]*web.HistoryStep, error) {
+	req, err := http.NewRequest(http.MethodGet, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/history", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to form request: %w", err)
+	}
+
+	var resp []*web.HistoryStep
+	if err := c.do(req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to load game history: %w", err)
+	}
+	return resp, nil
+}
+
+// ChatHistory loads the chat messages in a game that the player can see.
+func (c *Client) ChatHistory(gID codenames.GameID) ([]*web.ChatMessage, error) {
+	req, err := http.NewRequest(http.MethodGet, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/chat", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to form request: %w", err)
+	}
+
+	var resp []*web.ChatMessage
+	if err := c.do(req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to load chat history: %w", err)
+	}
+
+	return resp, nil
+}
+
+// PlayerGames loads a page of the games the logged in player is in, starting
+// at the given offset, along with their record across all of their finished
+// games. A limit of zero leaves the page size up to the server.
+func (c *Client) PlayerGames(limit, offset int) (*web.PlayerGames, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	q.Set("offset", strconv.Itoa(offset))
+	req, err := http.NewRequest(http.MethodGet, c.scheme+"://"+c.addr+"/api/user/games?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to form request: %w", err)
+	}
+
+	var resp *web.PlayerGames
+	if err := c.do(req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to load games: %w", err)
+	}
+	return resp, nil
+}
+
+// Leaderboard loads the highest rated players in a role. A limit of zero
+// leaves it up to the server.
+func (c *Client) Leaderboard(role codenames.Role, limit int) ([]*web.RatedPlayer, error) {
+	q := url.Values{}
+	q.Set("role", string(role))
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	req, err := http.NewRequest(http.MethodGet, c.scheme+"://"+c.addr+"/api/leaderboard?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to form request: %w", err)
+	}
+
+	var resp []*web.RatedPlayer
+	if err := c.do(req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to load leaderboard: %w", err)
+	}
+	return resp, nil
+}
+
+// PlayerStats loads the record and ratings of a user or AI player.
+func (c *Client) PlayerStats(pID codenames.PlayerID) (*web.PlayerStats, error) {
+	endpoint := c.scheme + "://" + c.addr + "/api/"
+	switch pID.PlayerType {
+	case codenames.PlayerTypeHuman:
+		endpoint += "user/"
+	case codenames.PlayerTypeRobot:
+		endpoint += "ai/"
+	default:
+		return nil, fmt.Errorf("unknown player type %q", pID.PlayerType)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint+url.PathEscape(pID.ID)+"/stats", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to form request: %w", err)
+	}
+
+	var resp *web.PlayerStats
+	if err := c.do(req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to load player stats: %w", err)
+	}
+	return resp, nil
+}
+
 func (c *Client) JoinGame(gID codenames.GameID) error {
 	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/join", nil)
 	if err != nil {
@@ -102,6 +241,110 @@ func (c *Client) JoinGame(gID codenames.GameID) error {
 	return nil
 }
 
+// Spectate joins the game as a spectator. If spymasterView is true, the
+// spectator sees the whole board once the game is over, or before then if the
+// game creator allows it.
+func (c *Client) Spectate(gID codenames.GameID, spymasterView bool) error {
+	body := struct {
+		SpymasterView bool `json:"spymaster_view"`
+	}{spymasterView}
+
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/spectate", toBody(body))
+	if err != nil {
+		return fmt.Errorf("failed to form request: %w", err)
+	}
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to spectate game: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) LeaveGame(gID codenames.GameID) error {
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/leave", nil)
+	if err != nil {
+		return fmt.Errorf("failed to form request: %w", err)
+	}
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to leave game: %w", err)
+	}
+
+	return nil
+}
+
+// KickPlayer removes a player from the game, only the game creator can do
+// this.
+func (c *Client) KickPlayer(gID codenames.GameID, pID codenames.PlayerID) error {
+	body := struct {
+		PlayerID codenames.PlayerID `json:"player_id"`
+	}{pID}
+
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/kick", toBody(body))
+	if err != nil {
+		return fmt.Errorf("failed to form request: %w", err)
+	}
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to kick player: %w", err)
+	}
+
+	return nil
+}
+
+// TransferOwner hands control of the game to another person in it, only the
+// game creator can do this.
+func (c *Client) TransferOwner(gID codenames.GameID, pID codenames.PlayerID) error {
+	body := struct {
+		PlayerID codenames.PlayerID `json:"player_id"`
+	}{pID}
+
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/transferOwner", toBody(body))
+	if err != nil {
+		return fmt.Errorf("failed to form request: %w", err)
+	}
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to transfer game: %w", err)
+	}
+
+	return nil
+}
+
+// RematchOptions configure a rematch of a finished game. The zero value keeps
+// everyone in their roles and picks a random starting team.
+type RematchOptions struct {
+	// RotateSpymasters passes the spymaster role to the next player on each
+	// team.
+	RotateSpymasters bool `json:"rotate_spymasters,omitempty"`
+	// AlternateStarter has the team after the last game's starting team go
+	// first.
+	AlternateStarter bool `json:"alternate_starter,omitempty"`
+}
+
+// Rematch starts a new game with the same players as the given finished game,
+// and returns the ID of the new game. If someone already asked for a rematch,
+// it returns that game instead.
+func (c *Client) Rematch(gID codenames.GameID, opts *RematchOptions) (codenames.GameID, error) {
+	if opts == nil {
+		opts = &RematchOptions{}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/rematch", toBody(opts))
+	if err != nil {
+		return "", fmt.Errorf("failed to form request: %w", err)
+	}
+
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return "", fmt.Errorf("failed to start rematch: %w", err)
+	}
+	return codenames.GameID(resp.ID), nil
+}
+
 func (c *Client) RequestAI(gID codenames.GameID) (codenames.RobotID, error) {
 	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/requestAI", nil)
 	if err != nil {
@@ -138,10 +381,64 @@ func (c *Client) AssignRole(gID codenames.GameID, pID codenames.PlayerID, team c
 	return nil
 }
 
-func (c *Client) StartGame(gID codenames.GameID) error {
+// ChooseRole asks for the given team and role for ourselves, which works
+// unless the game creator has locked teams.
+func (c *Client) ChooseRole(gID codenames.GameID, team codenames.Team, role codenames.Role) error {
+	body := struct {
+		Team string `json:"team"`
+		Role string `json:"role"`
+	}{string(team), string(role)}
+
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/chooseRole", toBody(body))
+	if err != nil {
+		return fmt.Errorf("failed to form request: %w", err)
+	}
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to choose role: %w", err)
+	}
+
+	return nil
+}
+
+// LockTeams sets whether players can pick their own team and role, only the
+// game creator can do this.
+func (c *Client) LockTeams(gID codenames.GameID, locked bool) error {
+	body := struct {
+		Locked bool `json:"locked"`
+	}{locked}
+
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/lockTeams", toBody(body))
+	if err != nil {
+		return fmt.Errorf("failed to form request: %w", err)
+	}
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to lock teams: %w", err)
+	}
+
+	return nil
+}
+
+// StartOptions configure how a game is started. The zero value assigns
+// players without a role randomly.
+type StartOptions struct {
+	// AssignmentStrategy is how players without a role get one, like
+	// web.BalancedAssignment. Empty means web.RandomAssignment.
+	AssignmentStrategy web.AssignmentStrategy
+}
+
+// StartGame starts the game, giving everyone that doesn't have a role one.
+// The options can be nil.
+func (c *Client) StartGame(gID codenames.GameID, opts *StartOptions) error {
+	if opts == nil {
+		opts = &StartOptions{}
+	}
+
 	body := struct {
-		RandomAssignment bool `json:"random_assignment"`
-	}{true}
+		RandomAssignment   bool                   `json:"random_assignment"`
+		AssignmentStrategy web.AssignmentStrategy `json:"assignment_strategy,omitempty"`
+	}{true, opts.AssignmentStrategy}
 
 	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/start", toBody(body))
 	if err != nil {
@@ -157,9 +454,10 @@ func (c *Client) StartGame(gID codenames.GameID) error {
 
 func (c *Client) GiveClue(gID codenames.GameID, clue *codenames.Clue) error {
 	body := struct {
-		Word  string `json:"word"`
-		Count int    `json:"count"`
-	}{clue.Word, clue.Count}
+		Word      string `json:"word"`
+		Count     int    `json:"count"`
+		Unlimited bool   `json:"unlimited"`
+	}{clue.Word, clue.Count, clue.Unlimited}
 
 	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/clue", toBody(body))
 	if err != nil {
@@ -191,6 +489,19 @@ func (c *Client) GiveGuess(gID codenames.GameID, guess string, confirmed bool) e
 	return nil
 }
 
+func (c *Client) Pass(gID codenames.GameID) error {
+	req, err := http.NewRequest(http.MethodPost, c.scheme+"://"+c.addr+"/api/game/"+string(gID)+"/pass", nil)
+	if err != nil {
+		return fmt.Errorf("failed to form request: %w", err)
+	}
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to pass: %w", err)
+	}
+
+	return nil
+}
+
 func (c *Client) do(req *http.Request, resp interface{}) error {
 	httpResp, err := c.http.Do(req)
 	if err != nil {
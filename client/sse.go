@@ -0,0 +1,181 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bcspragu/Codenames/codenames"
+)
+
+// Transport is how a client gets updates to a game from the server.
+type Transport int
+
+const (
+	// WebSocketTransport gets updates over a WebSocket. It's the default.
+	WebSocketTransport Transport = iota
+	// SSETransport gets updates over a Server-Sent Events stream, for networks
+	// where WebSockets get blocked.
+	SSETransport
+)
+
+// ListenOptions configure how ListenForUpdates gets updates. The zero value
+// uses a WebSocket.
+type ListenOptions struct {
+	Transport Transport
+}
+
+// eventStream is a Server-Sent Events stream of updates to a game. Like a
+// GameConn, it reconnects if the stream drops, and the server sends any updates
+// that were missed in the meantime.
+type eventStream struct {
+	url  string
+	http *http.Client
+
+	// gc passes updates to the hooks, the same way it would for updates from a
+	// WebSocket.
+	gc *GameConn
+
+	// lastID is the ID of the last event we got, which the server uses to
+	// figure out what we missed.
+	lastID string
+}
+
+// listenSSE is ListenForUpdates over an event stream.
+func (c *Client) listenSSE(gID codenames.GameID, hooks WSHooks) error {
+	es := &eventStream{
+		url:  c.scheme + "://" + c.addr + "/api/game/" + string(gID) + "/events",
+		http: c.http,
+		gc: &GameConn{
+			closing: make(chan struct{}),
+			done:    make(chan struct{}),
+			msgs:    make(chan []byte, 100),
+			hooks:   hooks,
+		},
+	}
+
+	body, err := es.open()
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	if hooks.OnConnect != nil {
+		go hooks.OnConnect()
+	}
+
+	go es.gc.handleMessages()
+	go es.read(body)
+
+	return es.gc.Wait()
+}
+
+// open requests the stream, picking up after the last event we got.
+func (es *eventStream) open() (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, es.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to form request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if es.lastID != "" {
+		req.Header.Set("Last-Event-ID", es.lastID)
+	}
+
+	resp, err := es.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, handleError(resp)
+	}
+	return resp.Body, nil
+}
+
+func (es *eventStream) read(body io.ReadCloser) {
+	defer close(es.gc.done)
+	for {
+		err := es.readFrom(body)
+		body.Close()
+		log.Printf("lost event stream for game, reconnecting: %v", err)
+
+		if body, err = es.reconnect(); err != nil {
+			es.gc.err = err
+			return
+		}
+	}
+}
+
+// readFrom reads events from the stream until it fails or ends.
+func (es *eventStream) readFrom(body io.Reader) error {
+	var (
+		br   = bufio.NewReader(body)
+		data []string
+	)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			return errors.New("server closed the stream")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// A blank line ends the event.
+		if line == "" {
+			if len(data) > 0 {
+				es.gc.msgs <- []byte(strings.Join(data, "\n"))
+			}
+			data = nil
+			continue
+		}
+
+		field, value := line, ""
+		if idx := strings.Index(line, ":"); idx != -1 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "":
+			// It's a comment, which the server sends to keep the stream alive.
+		case "id":
+			es.lastID = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
+
+// reconnect reopens the stream, with backoff, asking the server for the updates
+// we missed.
+func (es *eventStream) reconnect() (io.ReadCloser, error) {
+	backoff := minReconnectBackoff
+	var err error
+	for i := 0; i < maxReconnectAttempts; i++ {
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+
+		var body io.ReadCloser
+		if body, err = es.open(); err != nil {
+			log.Printf("failed to reconnect to game (attempt %d): %v", i+1, err)
+			continue
+		}
+
+		// If we never got an event, we don't know where to pick up from, so
+		// let the hooks know they might have missed something.
+		if es.lastID == "" {
+			es.gc.msgs <- []byte(`{"action":"RESYNC"}`)
+		}
+		if es.gc.hooks.OnReconnect != nil {
+			go es.gc.hooks.OnReconnect()
+		}
+		return body, nil
+	}
+	return nil, fmt.Errorf("failed to reconnect after %d attempts: %w", maxReconnectAttempts, err)
+}
@@ -2,9 +2,14 @@ package client
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bcspragu/Codenames/codenames"
@@ -12,54 +17,286 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-type wsClient struct {
-	conn  *websocket.Conn
+const (
+	// wsRequestTimeout is how long to wait for the server to respond to an
+	// action sent over a game connection.
+	wsRequestTimeout = 30 * time.Second
+
+	// When a game connection drops, we try to reconnect, waiting
+	// minReconnectBackoff before the first attempt and twice as long before
+	// each attempt after that, up to maxReconnectBackoff.
+	minReconnectBackoff = 500 * time.Millisecond
+	maxReconnectBackoff = 30 * time.Second
+	// maxReconnectAttempts is how many times in a row we try to reconnect before
+	// giving up.
+	maxReconnectAttempts = 10
+)
+
+// GameConn is a WebSocket connection to a game. Updates to the game are passed
+// to the hooks it was opened with, and game actions can be sent over it
+// instead of making a separate request for each one.
+//
+// If the connection drops, it reconnects automatically and the server sends
+// any updates that were missed in the meantime.
+type GameConn struct {
+	// nextID and lastSeq are first so they're 64-bit aligned for atomic
+	// operations.
+	nextID uint64
+	// lastSeq is the sequence number of the last update from the server.
+	lastSeq uint64
+
+	// epoch is what the server calls the sequence of updates lastSeq is from.
+	epoch atomic.Value
+
+	addr   string
+	dialer *websocket.Dialer
+
 	msgs  chan []byte
-	done  chan struct{}
 	hooks WSHooks
+	// closing is closed when Close is called, and done is closed once the
+	// connection is closed for good.
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	// err is why the connection closed, it's set before done is closed.
+	err error
+
+	// writeMu guards conn, which gets replaced when we reconnect and only
+	// supports one writer at a time.
+	writeMu sync.Mutex
+	conn    *websocket.Conn
+
+	mu      sync.Mutex
+	pending map[string]chan *wsReply
+}
+
+type wsReply struct {
+	ack *web.Ack
+	err *web.ActionError
+	// lost is set if the connection dropped before the request was answered.
+	lost error
 }
 
-func (c *Client) ListenForUpdates(gID codenames.GameID, hooks WSHooks) error {
+// ListenForUpdates connects to the given game and passes updates to the hooks
+// until the connection is closed, reconnecting if it drops. If opts is nil,
+// updates come over a WebSocket.
+func (c *Client) ListenForUpdates(gID codenames.GameID, hooks WSHooks, opts *ListenOptions) error {
+	if opts == nil {
+		opts = &ListenOptions{}
+	}
+
+	switch opts.Transport {
+	case WebSocketTransport:
+		gc, err := c.Connect(gID, hooks)
+		if err != nil {
+			return err
+		}
+		return gc.Wait()
+	case SSETransport:
+		return c.listenSSE(gID, hooks)
+	default:
+		return fmt.Errorf("unknown transport %d", opts.Transport)
+	}
+}
+
+// Connect opens a WebSocket connection to the given game, which passes updates
+// to the hooks until it is closed.
+func (c *Client) Connect(gID codenames.GameID, hooks WSHooks) (*GameConn, error) {
 	scheme := "ws"
 	if c.scheme == "https" {
 		scheme = "wss"
 	}
 
-	addr := scheme + "://" + c.addr + "/api/game/" + string(gID) + "/ws"
-
-	dialer := &websocket.Dialer{
-		Proxy:            http.ProxyFromEnvironment,
-		HandshakeTimeout: 45 * time.Second,
-		Jar:              c.http.Jar,
+	gc := &GameConn{
+		addr: scheme + "://" + c.addr + "/api/game/" + string(gID) + "/ws",
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: 45 * time.Second,
+			Jar:              c.http.Jar,
+		},
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+		// We buffer it in case messages come in while we're waiting on user input.
+		// We don't want to process messages concurrently, because that seems
+		// likely to cause tricky problems.
+		msgs:    make(chan []byte, 100),
+		hooks:   hooks,
+		pending: make(map[string]chan *wsReply),
 	}
-	conn, _, err := dialer.Dial(addr, nil)
+
+	conn, _, err := gc.dialer.Dial(gc.addr, nil)
 	if err != nil {
-		return fmt.Errorf("failed to connect to server: %w", err)
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
+	gc.conn = conn
 
 	if hooks.OnConnect != nil {
 		go hooks.OnConnect()
 	}
 
-	wsc := &wsClient{
-		conn: conn,
-		done: make(chan struct{}),
-		// We buffer it in case messages come in while we're waiting on user input.
-		// We don't want to process messages concurrently, because that seems
-		// likely to cause tricky problems.
-		msgs:  make(chan []byte, 100),
-		hooks: hooks,
+	go gc.handleMessages()
+	go gc.read(conn)
+
+	return gc, nil
+}
+
+// Wait blocks until the connection is closed, and returns why. It returns nil
+// if the connection was closed with Close.
+func (gc *GameConn) Wait() error {
+	<-gc.done
+	return gc.err
+}
+
+// Close closes the connection.
+func (gc *GameConn) Close() error {
+	gc.closeOnce.Do(func() { close(gc.closing) })
+
+	gc.writeMu.Lock()
+	defer gc.writeMu.Unlock()
+	return gc.conn.Close()
+}
+
+func (gc *GameConn) closed() bool {
+	select {
+	case <-gc.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// GiveClue gives a clue, the same as Client.GiveClue.
+func (gc *GameConn) GiveClue(clue *codenames.Clue) error {
+	body := struct {
+		Word      string `json:"word"`
+		Count     int    `json:"count"`
+		Unlimited bool   `json:"unlimited"`
+	}{clue.Word, clue.Count, clue.Unlimited}
+
+	if err := gc.send("CLUE", body); err != nil {
+		return fmt.Errorf("failed to give clue to game: %w", err)
 	}
+	return nil
+}
+
+// GiveGuess guesses a card, the same as Client.GiveGuess.
+func (gc *GameConn) GiveGuess(guess string, confirmed bool) error {
+	body := struct {
+		Guess     string `json:"guess"`
+		Confirmed bool   `json:"confirmed"`
+	}{guess, confirmed}
+
+	if err := gc.send("GUESS", body); err != nil {
+		return fmt.Errorf("failed to give guess to game: %w", err)
+	}
+	return nil
+}
+
+// Pass votes to end the team's turn, the same as Client.Pass.
+func (gc *GameConn) Pass() error {
+	if err := gc.send("PASS", nil); err != nil {
+		return fmt.Errorf("failed to pass: %w", err)
+	}
+	return nil
+}
 
-	go wsc.handleMessages()
+// Chat sends a message to everyone in the game.
+func (gc *GameConn) Chat(msg string) error {
+	return gc.chat(codenames.ChatScopeAll, msg)
+}
 
-	return wsc.read()
+// TeamChat sends a message to just the other operatives on the player's team.
+func (gc *GameConn) TeamChat(msg string) error {
+	return gc.chat(codenames.ChatScopeTeam, msg)
 }
 
-func (ws *wsClient) read() error {
-	defer close(ws.done)
+func (gc *GameConn) chat(scope codenames.ChatScope, msg string) error {
+	body := struct {
+		Message string              `json:"message"`
+		Scope   codenames.ChatScope `json:"scope"`
+	}{msg, scope}
+
+	if err := gc.send("CHAT", body); err != nil {
+		return fmt.Errorf("failed to send chat message: %w", err)
+	}
+	return nil
+}
+
+// send sends an action to the server and waits for it to be handled. The body
+// has the same fields as the equivalent HTTP request.
+func (gc *GameConn) send(action string, body interface{}) error {
+	req := make(map[string]interface{})
+	if body != nil {
+		dat, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		if err := json.Unmarshal(dat, &req); err != nil {
+			return fmt.Errorf("failed to decode request fields: %w", err)
+		}
+	}
+
+	id := strconv.FormatUint(atomic.AddUint64(&gc.nextID, 1), 10)
+	req["v"] = web.WSProtocolVersion
+	req["id"] = id
+	req["action"] = action
+
+	replyChan := make(chan *wsReply, 1)
+	gc.mu.Lock()
+	gc.pending[id] = replyChan
+	gc.mu.Unlock()
+	defer func() {
+		gc.mu.Lock()
+		delete(gc.pending, id)
+		gc.mu.Unlock()
+	}()
+
+	gc.writeMu.Lock()
+	err := gc.conn.WriteJSON(req)
+	gc.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	select {
+	case r := <-replyChan:
+		if r.lost != nil {
+			return fmt.Errorf("connection dropped before request was handled, it may or may not have gone through: %w", r.lost)
+		}
+		if r.err != nil {
+			return &httpError{statusCode: r.err.Code, body: r.err.Message}
+		}
+		return nil
+	case <-gc.done:
+		return fmt.Errorf("connection closed before request was handled: %w", gc.err)
+	case <-time.After(wsRequestTimeout):
+		return errors.New("timed out waiting for response")
+	}
+}
+
+func (gc *GameConn) read(conn *websocket.Conn) {
+	defer close(gc.done)
+	for {
+		err := gc.readFrom(conn)
+		if gc.closed() {
+			return
+		}
+		log.Printf("lost connection to game, reconnecting: %v", err)
+		gc.dropPending(err)
+
+		if conn, err = gc.reconnect(); err != nil {
+			if !gc.closed() {
+				gc.err = err
+			}
+			return
+		}
+	}
+}
+
+// readFrom reads messages from the connection until it fails.
+func (gc *GameConn) readFrom(conn *websocket.Conn) error {
 	for {
-		messageType, message, err := ws.conn.ReadMessage()
+		messageType, message, err := conn.ReadMessage()
 		if err != nil {
 			return fmt.Errorf("ReadMessage: %w", err)
 		}
@@ -68,16 +305,140 @@ func (ws *wsClient) read() error {
 			continue
 		}
 
-		ws.msgs <- message
+		var header struct {
+			Action string `json:"action"`
+			Epoch  string `json:"epoch"`
+			Seq    uint64 `json:"seq"`
+		}
+		if err := json.Unmarshal(message, &header); err != nil {
+			log.Printf("failed to unmarshal message header from server: %v", err)
+			continue
+		}
+		if header.Epoch != "" {
+			// The server greets us with where its updates are at, and tells us
+			// again if we have to start over.
+			gc.epoch.Store(header.Epoch)
+			atomic.StoreUint64(&gc.lastSeq, header.Seq)
+		} else if header.Seq > 0 {
+			atomic.StoreUint64(&gc.lastSeq, header.Seq)
+		}
+
+		// Responses to our own requests get handled right away, everything else
+		// goes in line for the hooks.
+		if !gc.handleReply(header.Action, message) {
+			gc.msgs <- message
+		}
+	}
+}
+
+// reconnect reopens the connection, with backoff, asking the server for the
+// updates we missed.
+func (gc *GameConn) reconnect() (*websocket.Conn, error) {
+	backoff := minReconnectBackoff
+	var err error
+	for i := 0; i < maxReconnectAttempts; i++ {
+		select {
+		case <-time.After(backoff):
+		case <-gc.closing:
+			return nil, errors.New("connection closed")
+		}
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+
+		// If the server never said where its updates were at, we don't know
+		// where to pick up from, so we start fresh and let the hooks know they
+		// might have missed something.
+		addr, seq := gc.addr, atomic.LoadUint64(&gc.lastSeq)
+		epoch, _ := gc.epoch.Load().(string)
+		if epoch != "" {
+			addr += "?" + url.Values{
+				"epoch": {epoch},
+				"since": {strconv.FormatUint(seq, 10)},
+			}.Encode()
+		}
+
+		var conn *websocket.Conn
+		if conn, _, err = gc.dialer.Dial(addr, nil); err != nil {
+			log.Printf("failed to reconnect to game (attempt %d): %v", i+1, err)
+			continue
+		}
+
+		gc.writeMu.Lock()
+		if gc.closed() {
+			gc.writeMu.Unlock()
+			conn.Close()
+			return nil, errors.New("connection closed")
+		}
+		gc.conn = conn
+		gc.writeMu.Unlock()
+
+		if epoch == "" {
+			gc.msgs <- []byte(`{"action":"RESYNC"}`)
+		}
+		if gc.hooks.OnReconnect != nil {
+			go gc.hooks.OnReconnect()
+		}
+		return conn, nil
 	}
+	return nil, fmt.Errorf("failed to reconnect after %d attempts: %w", maxReconnectAttempts, err)
 }
 
-func (ws *wsClient) handleMessages() {
+// dropPending fails any requests still waiting on a response, since they were
+// sent over a connection that's gone now.
+func (gc *GameConn) dropPending(err error) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	for id, replyChan := range gc.pending {
+		replyChan <- &wsReply{lost: err}
+		delete(gc.pending, id)
+	}
+}
+
+// handleReply passes responses to requests sent over the connection to
+// whoever is waiting on them, and reports whether the message was one.
+func (gc *GameConn) handleReply(action string, msg []byte) bool {
+	var (
+		id    string
+		reply = &wsReply{}
+	)
+	switch action {
+	case "ACK":
+		reply.ack = &web.Ack{}
+		if err := json.Unmarshal(msg, reply.ack); err != nil {
+			log.Printf("failed to unmarshal ack: %v", err)
+			return true
+		}
+		id = reply.ack.ID
+	case "ERROR":
+		reply.err = &web.ActionError{}
+		if err := json.Unmarshal(msg, reply.err); err != nil {
+			log.Printf("failed to unmarshal action error: %v", err)
+			return true
+		}
+		id = reply.err.ID
+	default:
+		return false
+	}
+
+	gc.mu.Lock()
+	replyChan, ok := gc.pending[id]
+	delete(gc.pending, id)
+	gc.mu.Unlock()
+	if !ok {
+		log.Printf("got %s for unknown request %q", action, id)
+		return true
+	}
+	replyChan <- reply
+	return true
+}
+
+func (gc *GameConn) handleMessages() {
 	for {
 		select {
-		case <-ws.done:
+		case <-gc.done:
 			return
-		case msg := <-ws.msgs:
+		case msg := <-gc.msgs:
 			var justAction struct {
 				Action string `json:"action"`
 			}
@@ -88,15 +449,45 @@ func (ws *wsClient) handleMessages() {
 
 			switch justAction.Action {
 			case "GAME_START":
-				ws.handleGameStart(msg)
+				gc.handleGameStart(msg)
 			case "CLUE_GIVEN":
-				ws.handleClueGiven(msg)
+				gc.handleClueGiven(msg)
 			case "PLAYER_VOTE":
-				ws.handlePlayerVote(msg)
+				gc.handlePlayerVote(msg)
 			case "GUESS_GIVEN":
-				ws.handleGuessGiven(msg)
+				gc.handleGuessGiven(msg)
+			case "TURN_PASSED":
+				gc.handleTurnPassed(msg)
+			case "TIMER":
+				gc.handleTimer(msg)
+			case "PLAYER_JOINED":
+				gc.handlePlayerJoined(msg)
+			case "ROLE_ASSIGNED":
+				gc.handleRoleAssigned(msg)
+			case "TEAMS_LOCKED":
+				gc.handleTeamsLocked(msg)
+			case "PLAYER_LEFT":
+				gc.handlePlayerLeft(msg)
+			case "PLAYER_KICKED":
+				gc.handlePlayerKicked(msg)
+			case "CREATOR_CHANGED":
+				gc.handleCreatorChanged(msg)
+			case "REMATCH":
+				gc.handleRematch(msg)
+			case "PLAYER_ONLINE":
+				gc.handlePlayerOnline(msg)
+			case "PLAYER_OFFLINE":
+				gc.handlePlayerOffline(msg)
+			case "CHAT":
+				gc.handleChat(msg)
+			case "CONNECTED":
+				// Nothing to do, readFrom already noted where we're at.
+			case "RESYNC":
+				if gc.hooks.OnResync != nil {
+					gc.hooks.OnResync()
+				}
 			case "GAME_END":
-				ws.handleGameEnd(msg)
+				gc.handleGameEnd(msg)
 			default:
 				log.Printf("unknown message action %q", justAction.Action)
 			}
@@ -104,7 +495,7 @@ func (ws *wsClient) handleMessages() {
 	}
 }
 
-func (ws *wsClient) handleGameStart(dat []byte) {
+func (gc *GameConn) handleGameStart(dat []byte) {
 	var gs web.GameStart
 	if err := json.Unmarshal(dat, &gs); err != nil {
 		log.Printf("handleGameStart: %v", err)
@@ -113,69 +504,245 @@ func (ws *wsClient) handleGameStart(dat []byte) {
 
 	fmt.Println(string(dat))
 	fmt.Printf("%+v\n", gs)
-	if ws.hooks.OnStart == nil {
+	if gc.hooks.OnStart == nil {
 		return
 	}
-	ws.hooks.OnStart(&gs)
+	gc.hooks.OnStart(&gs)
 }
 
-func (ws *wsClient) handleClueGiven(dat []byte) {
+func (gc *GameConn) handleClueGiven(dat []byte) {
 	var cg web.ClueGiven
 	if err := json.Unmarshal(dat, &cg); err != nil {
 		log.Printf("handleClueGiven: %v", err)
 		return
 	}
 
-	if ws.hooks.OnClueGiven == nil {
+	if gc.hooks.OnClueGiven == nil {
 		return
 	}
-	ws.hooks.OnClueGiven(&cg)
+	gc.hooks.OnClueGiven(&cg)
 }
 
-func (ws *wsClient) handlePlayerVote(dat []byte) {
+func (gc *GameConn) handlePlayerVote(dat []byte) {
 	var pv web.PlayerVote
 	if err := json.Unmarshal(dat, &pv); err != nil {
 		log.Printf("handlePlayerVote: %v", err)
 		return
 	}
 
-	if ws.hooks.OnPlayerVote == nil {
+	if gc.hooks.OnPlayerVote == nil {
 		return
 	}
-	ws.hooks.OnPlayerVote(&pv)
+	gc.hooks.OnPlayerVote(&pv)
 }
 
-func (ws *wsClient) handleGuessGiven(dat []byte) {
+func (gc *GameConn) handleGuessGiven(dat []byte) {
 	var gg web.GuessGiven
 	if err := json.Unmarshal(dat, &gg); err != nil {
 		log.Printf("handleGuessGiven: %v", err)
 		return
 	}
 
-	if ws.hooks.OnGuessGiven == nil {
+	if gc.hooks.OnGuessGiven == nil {
+		return
+	}
+	gc.hooks.OnGuessGiven(&gg)
+}
+
+func (gc *GameConn) handleTurnPassed(dat []byte) {
+	var tp web.TurnPassed
+	if err := json.Unmarshal(dat, &tp); err != nil {
+		log.Printf("handleTurnPassed: %v", err)
+		return
+	}
+
+	if gc.hooks.OnPass == nil {
+		return
+	}
+	gc.hooks.OnPass(&tp)
+}
+
+func (gc *GameConn) handleTimer(dat []byte) {
+	var tt web.TurnTimer
+	if err := json.Unmarshal(dat, &tt); err != nil {
+		log.Printf("handleTimer: %v", err)
+		return
+	}
+
+	if gc.hooks.OnTimer == nil {
+		return
+	}
+	gc.hooks.OnTimer(&tt)
+}
+
+func (gc *GameConn) handlePlayerJoined(dat []byte) {
+	var pj web.PlayerJoined
+	if err := json.Unmarshal(dat, &pj); err != nil {
+		log.Printf("handlePlayerJoined: %v", err)
+		return
+	}
+
+	if gc.hooks.OnPlayerJoined == nil {
+		return
+	}
+	gc.hooks.OnPlayerJoined(&pj)
+}
+
+func (gc *GameConn) handleRoleAssigned(dat []byte) {
+	var ra web.RoleAssigned
+	if err := json.Unmarshal(dat, &ra); err != nil {
+		log.Printf("handleRoleAssigned: %v", err)
+		return
+	}
+
+	if gc.hooks.OnRoleAssigned == nil {
+		return
+	}
+	gc.hooks.OnRoleAssigned(&ra)
+}
+
+func (gc *GameConn) handleTeamsLocked(dat []byte) {
+	var tl web.TeamsLocked
+	if err := json.Unmarshal(dat, &tl); err != nil {
+		log.Printf("handleTeamsLocked: %v", err)
+		return
+	}
+
+	if gc.hooks.OnTeamsLocked == nil {
+		return
+	}
+	gc.hooks.OnTeamsLocked(&tl)
+}
+
+func (gc *GameConn) handlePlayerLeft(dat []byte) {
+	var pl web.PlayerLeft
+	if err := json.Unmarshal(dat, &pl); err != nil {
+		log.Printf("handlePlayerLeft: %v", err)
+		return
+	}
+
+	if gc.hooks.OnPlayerLeft == nil {
+		return
+	}
+	gc.hooks.OnPlayerLeft(&pl)
+}
+
+func (gc *GameConn) handlePlayerKicked(dat []byte) {
+	var pk web.PlayerKicked
+	if err := json.Unmarshal(dat, &pk); err != nil {
+		log.Printf("handlePlayerKicked: %v", err)
+		return
+	}
+
+	if gc.hooks.OnPlayerKicked == nil {
+		return
+	}
+	gc.hooks.OnPlayerKicked(&pk)
+}
+
+func (gc *GameConn) handleCreatorChanged(dat []byte) {
+	var cc web.CreatorChanged
+	if err := json.Unmarshal(dat, &cc); err != nil {
+		log.Printf("handleCreatorChanged: %v", err)
+		return
+	}
+
+	if gc.hooks.OnCreatorChanged == nil {
+		return
+	}
+	gc.hooks.OnCreatorChanged(&cc)
+}
+
+func (gc *GameConn) handleRematch(dat []byte) {
+	var rm web.Rematch
+	if err := json.Unmarshal(dat, &rm); err != nil {
+		log.Printf("handleRematch: %v", err)
+		return
+	}
+
+	if gc.hooks.OnRematch == nil {
+		return
+	}
+	gc.hooks.OnRematch(&rm)
+}
+
+func (gc *GameConn) handlePlayerOnline(dat []byte) {
+	var po web.PlayerOnline
+	if err := json.Unmarshal(dat, &po); err != nil {
+		log.Printf("handlePlayerOnline: %v", err)
+		return
+	}
+
+	if gc.hooks.OnPlayerOnline == nil {
+		return
+	}
+	gc.hooks.OnPlayerOnline(&po)
+}
+
+func (gc *GameConn) handlePlayerOffline(dat []byte) {
+	var po web.PlayerOffline
+	if err := json.Unmarshal(dat, &po); err != nil {
+		log.Printf("handlePlayerOffline: %v", err)
 		return
 	}
-	ws.hooks.OnGuessGiven(&gg)
+
+	if gc.hooks.OnPlayerOffline == nil {
+		return
+	}
+	gc.hooks.OnPlayerOffline(&po)
+}
+
+func (gc *GameConn) handleChat(dat []byte) {
+	var cm web.ChatMessage
+	if err := json.Unmarshal(dat, &cm); err != nil {
+		log.Printf("handleChat: %v", err)
+		return
+	}
+
+	if gc.hooks.OnChat == nil {
+		return
+	}
+	gc.hooks.OnChat(&cm)
 }
 
-func (ws *wsClient) handleGameEnd(dat []byte) {
+func (gc *GameConn) handleGameEnd(dat []byte) {
 	var ge web.GameEnd
 	if err := json.Unmarshal(dat, &ge); err != nil {
 		log.Printf("handleGameEnd: %v", err)
 		return
 	}
 
-	if ws.hooks.OnEnd == nil {
+	if gc.hooks.OnEnd == nil {
 		return
 	}
-	ws.hooks.OnEnd(&ge)
+	gc.hooks.OnEnd(&ge)
 }
 
 type WSHooks struct {
-	OnConnect    func()
+	OnConnect func()
+	// OnReconnect is called when the connection comes back after dropping.
+	// Updates sent while it was down are passed to the other hooks as usual.
+	OnReconnect func()
+	// OnResync is called when we might have missed updates while the
+	// connection was down, in which case the game should be reloaded.
+	OnResync func()
+
 	OnStart      func(*web.GameStart)
 	OnClueGiven  func(*web.ClueGiven)
 	OnPlayerVote func(*web.PlayerVote)
 	OnGuessGiven func(*web.GuessGiven)
+	OnPass       func(*web.TurnPassed)
+	OnTimer      func(*web.TurnTimer)
 	OnEnd        func(*web.GameEnd)
+
+	OnPlayerJoined   func(*web.PlayerJoined)
+	OnRoleAssigned   func(*web.RoleAssigned)
+	OnTeamsLocked    func(*web.TeamsLocked)
+	OnPlayerLeft     func(*web.PlayerLeft)
+	OnPlayerKicked   func(*web.PlayerKicked)
+	OnCreatorChanged func(*web.CreatorChanged)
+	OnRematch        func(*web.Rematch)
+	OnPlayerOnline   func(*web.PlayerOnline)
+	OnPlayerOffline  func(*web.PlayerOffline)
+	OnChat           func(*web.ChatMessage)
 }
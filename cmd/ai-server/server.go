@@ -147,6 +147,14 @@ func (s *Server) playGame(c *client.Client, gID codenames.GameID, rID codenames.
 		lastClue *codenames.Clue
 	)
 
+	// myTurnToClue returns true if we're the spymaster for the team that's up.
+	myTurnToClue := func(g *codenames.Game) bool {
+		return role == codenames.SpymasterRole &&
+			g.Status != codenames.Finished &&
+			g.State.ActiveRole == codenames.SpymasterRole &&
+			g.State.ActiveTeam == team
+	}
+
 	err := c.ListenForUpdates(gID, client.WSHooks{
 		OnConnect: func() {
 			// TODO(bcspragu): Decide if we need to do anything once we connect.
@@ -162,7 +170,7 @@ func (s *Server) playGame(c *client.Client, gID codenames.GameID, rID codenames.
 			}
 
 			if role == codenames.SpymasterRole && gs.Game.State.ActiveTeam == team {
-				clue, err := s.giveClue(gs.Game.State.Board, toAgent(team))
+				clue, err := s.giveClue(gs.Game.State.Board, codenames.AgentForTeam(team))
 				if err != nil {
 					log.Printf("[ERROR] failed to make a clue: %v", err)
 					return
@@ -197,12 +205,13 @@ func (s *Server) playGame(c *client.Client, gID codenames.GameID, rID codenames.
 			}
 		},
 		OnGuessGiven: func(gg *web.GuessGiven) {
-			// We only want to formulate a clue when the *other* team has just
-			// finished guessing.
-			if gg.Team != team && !gg.CanKeepGuessing && role == codenames.SpymasterRole {
+			// We only want to formulate a clue when another team has just
+			// finished guessing, and it's now our turn. With three teams, it
+			// might not be.
+			if myTurnToClue(gg.Game) {
 				fmt.Println("My turn to clue!")
 
-				clue, err := s.giveClue(gg.Game.State.Board, toAgent(team))
+				clue, err := s.giveClue(gg.Game.State.Board, codenames.AgentForTeam(team))
 				if err != nil {
 					log.Printf("[ERROR] failed to make a clue: %v", err)
 					return
@@ -233,7 +242,24 @@ func (s *Server) playGame(c *client.Client, gID codenames.GameID, rID codenames.
 				return
 			}
 		},
-	})
+		OnPass: func(tp *web.TurnPassed) {
+			// Same as above, if another team passed, it might be our turn to clue.
+			if !myTurnToClue(tp.Game) {
+				return
+			}
+
+			clue, err := s.giveClue(tp.Game.State.Board, codenames.AgentForTeam(team))
+			if err != nil {
+				log.Printf("[ERROR] failed to make a clue: %v", err)
+				return
+			}
+
+			if err := c.GiveClue(gID, clue); err != nil {
+				log.Printf("[ERROR] failed to give clue: %v", err)
+				return
+			}
+		},
+	}, nil)
 	if err != nil {
 		log.Printf("[ERROR] error listening for updates in game %q: %v", gID, err)
 	}
@@ -252,17 +278,6 @@ func (s *Server) giveClue(b *codenames.Board, agent codenames.Agent) (*codenames
 	return clue, nil
 }
 
-func toAgent(team codenames.Team) codenames.Agent {
-	switch team {
-	case codenames.BlueTeam:
-		return codenames.BlueAgent
-	case codenames.RedTeam:
-		return codenames.RedAgent
-	default:
-		return codenames.UnknownAgent
-	}
-}
-
 func (s *Server) guess(b *codenames.Board, clue *codenames.Clue) (string, error) {
 	guess, err := s.ai.Guess(b, clue)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/bcspragu/Codenames/client"
 	"github.com/bcspragu/Codenames/codenames"
@@ -16,8 +17,21 @@ import (
 
 func main() {
 	var (
-		serverScheme = flag.String("server_scheme", "http", "The scheme of the server to connect to to play the game.")
-		serverAddr   = flag.String("server_addr", "localhost:8080", "The address of the server to connect to to play the game.")
+		serverScheme             = flag.String("server_scheme", "http", "The scheme of the server to connect to to play the game.")
+		serverAddr               = flag.String("server_addr", "localhost:8080", "The address of the server to connect to to play the game.")
+		mode                     = flag.String("mode", "CLASSIC", "The game mode to use when creating a game, either CLASSIC or DUET.")
+		numTeams                 = flag.Int("num_teams", 2, "The number of teams to use when creating a CLASSIC game, either 2 or 3.")
+		board                    = flag.String("board", "", "The board to use when creating a CLASSIC game, either STANDARD, QUICK (4x4) or DEEP (6x6). Defaults to the standard board for the number of teams.")
+		spymasterSecs            = flag.Int("spymaster_secs", 0, "How many seconds spymasters get to give a clue when creating a game, or zero for no limit.")
+		operativeSecs            = flag.Int("operative_secs", 0, "How many seconds operatives get to guess when creating a game, or zero for no limit.")
+		allowSpymasterSpectators = flag.Bool("allow_spymaster_spectators", false, "Whether spectators can see the whole board before the game is over, when creating a game.")
+		spectate                 = flag.Bool("spectate", false, "If true, watch the game instead of playing in it.")
+		spymasterView            = flag.Bool("spymaster_view", false, "If true and spectating, ask to see the whole board.")
+		assignment               = flag.String("assignment", "RANDOM", "How to assign players without a role when starting a game you created, either RANDOM or BALANCED (based on past games).")
+		lockTeams                = flag.Bool("lock_teams", false, "Whether only the game creator can assign teams, when creating a game.")
+		wantTeam                 = flag.String("team", "", "The team to ask for after joining a game, like RED or BLUE.")
+		wantRole                 = flag.String("role", "", "The role to ask for after joining a game, either SPYMASTER or OPERATIVE.")
+		useSSE                   = flag.Bool("sse", false, "If true, get game updates over Server-Sent Events instead of a WebSocket, for networks that block WebSockets.")
 	)
 	flag.Parse()
 
@@ -25,7 +39,6 @@ func main() {
 	reader := bufio.NewReader(os.Stdin)
 
 	name := prompt(reader, "Enter a username: ")
-	gameToJoin := prompt(reader, "Enter a game ID to join, or blank to create a game: ", allowEmpty())
 
 	c, err := client.New(*serverScheme, *serverAddr)
 	if err != nil {
@@ -37,9 +50,33 @@ func main() {
 		log.Fatalf("failed to create user: %v", err)
 	}
 
+	// Usage: codenames-client replay GAME_ID
+	if flag.Arg(0) == "replay" {
+		if flag.NArg() != 2 {
+			log.Fatal("usage: codenames-client replay GAME_ID")
+		}
+		if err := replay(reader, c, codenames.GameID(flag.Arg(1))); err != nil {
+			log.Fatalf("failed to replay game: %v", err)
+		}
+		return
+	}
+
+	gameToJoin := prompt(reader, "Enter a game ID to join, or blank to create a game: ", allowEmpty())
+
 	var gameID codenames.GameID
 	if gameToJoin == "" {
-		gID, err := c.CreateGame()
+		gameMode, ok := codenames.ToGameMode(strings.ToUpper(*mode))
+		if !ok {
+			log.Fatalf("unknown game mode %q", *mode)
+		}
+		opts := &client.GameOptions{Mode: gameMode, NumTeams: *numTeams, AllowSpymasterSpectators: *allowSpymasterSpectators, LockTeams: *lockTeams}
+		if *spymasterSecs > 0 || *operativeSecs > 0 {
+			opts.Timers = &codenames.TurnTimers{SpymasterSecs: *spymasterSecs, OperativeSecs: *operativeSecs}
+		}
+		if *board != "" {
+			opts.Board = strings.ToUpper(*board)
+		}
+		gID, err := c.CreateGame(opts)
 		if err != nil {
 			log.Fatalf("failed to create game: %v", err)
 		}
@@ -49,8 +86,35 @@ func main() {
 		gameID = codenames.GameID(gameToJoin)
 	}
 
-	if err := c.JoinGame(gameID); err != nil {
-		log.Fatalf("failed to join game: %v", err)
+	if *spectate {
+		if err := c.Spectate(gameID, *spymasterView); err != nil {
+			log.Fatalf("failed to spectate game: %v", err)
+		}
+		// The game might already be going, show where it's at.
+		g, err := c.Game(gameID)
+		if err != nil {
+			log.Fatalf("failed to load game: %v", err)
+		}
+		if g.Status != codenames.Pending {
+			printBoard(g.State.Board, g.State.BoardSpec())
+		}
+	} else {
+		if err := c.JoinGame(gameID); err != nil {
+			log.Fatalf("failed to join game: %v", err)
+		}
+		if *wantTeam != "" || *wantRole != "" {
+			t, ok := codenames.ToTeam(strings.ToUpper(*wantTeam))
+			if !ok {
+				log.Fatalf("invalid team %q", *wantTeam)
+			}
+			r, ok := codenames.ToRole(strings.ToUpper(*wantRole))
+			if !ok {
+				log.Fatalf("invalid role %q", *wantRole)
+			}
+			if err := c.ChooseRole(gameID, t, r); err != nil {
+				log.Fatalf("failed to choose role: %v", err)
+			}
+		}
 	}
 
 	var (
@@ -58,12 +122,33 @@ func main() {
 		role codenames.Role
 	)
 
+	// shouldClue returns true if it's our turn to give a clue.
+	shouldClue := func(g *codenames.Game) bool {
+		return g.Status != codenames.Finished &&
+			role == codenames.SpymasterRole &&
+			g.State.ActiveRole == codenames.SpymasterRole &&
+			g.State.ActiveTeam == team
+	}
+	// shouldGuess returns true if it's our turn to guess. In Duet, spymasters
+	// guess for the other side's clues.
+	shouldGuess := func(g *codenames.Game) bool {
+		return g.Status != codenames.Finished &&
+			(role == codenames.OperativeRole || g.State.Mode == codenames.DuetMode) &&
+			g.State.ActiveRole == codenames.OperativeRole &&
+			g.State.GuessingTeam() == team
+	}
+
+	listenOpts := &client.ListenOptions{}
+	if *useSSE {
+		listenOpts.Transport = client.SSETransport
+	}
+
 	// defer termui.Close()
 	err = c.ListenForUpdates(gameID, client.WSHooks{
 		OnConnect: func() {
 			if gameToJoin == "" {
 				// Means we created the game, so we need to start it.
-				lobbyShell(reader, c, gameID)
+				lobbyShell(reader, c, gameID, web.AssignmentStrategy(strings.ToUpper(*assignment)))
 			}
 		},
 		OnStart: func(gs *web.GameStart) {
@@ -76,19 +161,22 @@ func main() {
 			// if err := termui.Init(); err != nil {
 			// 	log.Fatalf("failed to initialize termui: %v", err)
 			// }
-			printBoard(gs.Game.State.Board)
+			printBoard(gs.Game.State.Board, gs.Game.State.BoardSpec())
 
 			// If the game started, and we're the starter spymaster, give a clue.
-			if role == codenames.SpymasterRole && gs.Game.State.ActiveTeam == team {
+			if shouldClue(gs.Game) {
 				if err := giveAClue(c, gameID, reader); err != nil {
 					log.Fatalf("failed to give clue: %v", err)
 				}
 			}
 		},
 		OnClueGiven: func(cg *web.ClueGiven) {
-			fmt.Printf("Clue Given: %q %d\n", cg.Clue.Word, cg.Clue.Count)
+			fmt.Printf("Clue Given: %s\n", cg.Clue)
+			if cg.Game.State.Mode == codenames.DuetMode {
+				fmt.Printf("%d turns left\n", cg.Game.State.TurnsLeft)
+			}
 
-			if role != codenames.OperativeRole || team != cg.Team {
+			if !shouldGuess(cg.Game) {
 				return
 			}
 
@@ -106,23 +194,80 @@ func main() {
 
 			// We're an operative on the active team and we got the last one correct
 			// and have guesses left.
-			if gg.CanKeepGuessing && role == codenames.OperativeRole && team == gg.Team {
+			if gg.CanKeepGuessing && shouldGuess(gg.Game) {
 				if err := giveAGuess(c, gameID, gg.Game.State.Board, reader); err != nil {
 					log.Fatalf("failed to give clue: %v", err)
 				}
 			}
 
 			// We're the opposing spymaster and the other team is done guessing.
-			if !gg.CanKeepGuessing && role == codenames.SpymasterRole && team != gg.Team {
+			if !gg.CanKeepGuessing && shouldClue(gg.Game) {
+				if err := giveAClue(c, gameID, reader); err != nil {
+					log.Fatalf("failed to give clue: %v", err)
+				}
+			}
+		},
+		OnPass: func(tp *web.TurnPassed) {
+			if tp.Expired {
+				fmt.Printf("%s ran out of time\n", tp.Team)
+			} else {
+				fmt.Printf("%s passed\n", tp.Team)
+			}
+
+			// We're the opposing spymaster and the other team is done guessing.
+			if shouldClue(tp.Game) {
 				if err := giveAClue(c, gameID, reader); err != nil {
 					log.Fatalf("failed to give clue: %v", err)
 				}
 			}
 		},
+		OnTimer: func(tt *web.TurnTimer) {
+			fmt.Printf("%s %s has %s to go\n", tt.Team, tt.Role, time.Until(tt.Deadline).Round(time.Second))
+		},
+		OnPlayerJoined: func(pj *web.PlayerJoined) {
+			if pj.Player != nil {
+				fmt.Printf("%s (%s) joined the game\n", pj.Player.Name, pj.Player.PlayerID.ID)
+			}
+		},
+		OnRoleAssigned: func(ra *web.RoleAssigned) {
+			if ra.Player == nil {
+				return
+			}
+			if ra.Player.Role.IsSpectator() {
+				fmt.Printf("%s is spectating\n", ra.Player.Name)
+				return
+			}
+			fmt.Printf("%s is now %s %s\n", ra.Player.Name, ra.Player.Team, ra.Player.Role)
+		},
+		OnTeamsLocked: func(tl *web.TeamsLocked) {
+			if tl.Locked {
+				fmt.Println("Teams are locked, only the game creator can assign roles")
+			} else {
+				fmt.Println("Teams are unlocked, pick your own role")
+			}
+		},
+		OnPlayerLeft: func(pl *web.PlayerLeft) {
+			fmt.Printf("%s left the game\n", pl.PlayerID.ID)
+		},
+		OnPlayerKicked: func(pk *web.PlayerKicked) {
+			if pk.PlayerID.ID == userID {
+				log.Fatal("You were kicked from the game")
+			}
+			fmt.Printf("%s was kicked from the game\n", pk.PlayerID.ID)
+		},
+		OnRematch: func(rm *web.Rematch) {
+			fmt.Printf("A rematch was started, join game %s to keep playing\n", rm.GameID)
+		},
 		OnEnd: func(ge *web.GameEnd) {
-			fmt.Printf("Game over, %q won!", ge.WinningTeam)
+			fmt.Printf("Game over, %q won!\n", ge.WinningTeam)
+			if ge.Game != nil {
+				printBoard(ge.Game.State.Board, ge.Game.State.BoardSpec())
+			}
+			if ge.Outcome != nil {
+				fmt.Print(ge.Outcome)
+			}
 		},
-	})
+	}, listenOpts)
 	if err != nil {
 		log.Fatalf("failed to listen for updates: %v", err)
 	}
@@ -154,6 +299,12 @@ func getAClue(reader *bufio.Reader) *codenames.Clue {
 
 func giveAGuess(c *client.Client, gameID codenames.GameID, board *codenames.Board, reader *bufio.Reader) error {
 	guess, confirmed := getAGuess(reader, board)
+	if guess == "pass" {
+		if err := c.Pass(gameID); err != nil {
+			return fmt.Errorf("failed to pass: %w", err)
+		}
+		return nil
+	}
 	if err := c.GiveGuess(gameID, guess, confirmed); err != nil {
 		return fmt.Errorf("failed to send guess: %w", err)
 	}
@@ -162,12 +313,15 @@ func giveAGuess(c *client.Client, gameID codenames.GameID, board *codenames.Boar
 
 func getAGuess(reader *bufio.Reader, board *codenames.Board) (string, bool) {
 	for {
-		fmt.Print("Enter a guess: ")
+		fmt.Print("Enter a guess, or 'pass' to end your turn: ")
 		guess, err := reader.ReadString('\n')
 		if err != nil {
 			log.Fatalf("failed to read guess: %v", err)
 		}
 		guess = strings.ToLower(strings.TrimSpace(guess))
+		if guess == "pass" {
+			return guess, true
+		}
 		if !guessInCards(guess, board.Cards) {
 			fmt.Println("guess was not found on board, please try again")
 			continue
@@ -194,20 +348,30 @@ func guessInCards(guess string, cards []codenames.Card) bool {
 	return false
 }
 
-func printBoard(b *codenames.Board) {
+func printBoard(b *codenames.Board, spec *codenames.BoardSpec) {
 	table := tablewriter.NewWriter(os.Stdout)
 
-	for i := 0; i < 5; i++ {
+	for i := 0; i < spec.Rows; i++ {
 		var row []string
 		var colors []tablewriter.Colors
-		for j := 0; j < 5; j++ {
-			card := b.Cards[i*5+j]
+		for j := 0; j < spec.Columns; j++ {
+			idx := i*spec.Columns + j
+			card := b.Cards[idx]
+			agent := card.Agent
+			if !card.Revealed && len(b.Keys) == 1 {
+				// In Duet, we only get our own key, show what's on it.
+				for _, key := range b.Keys {
+					agent = key[idx]
+				}
+			}
 			var c tablewriter.Colors
-			switch card.Agent {
+			switch agent {
 			case codenames.BlueAgent:
 				c = append(c, tablewriter.FgBlueColor)
 			case codenames.RedAgent:
 				c = append(c, tablewriter.FgHiRedColor)
+			case codenames.GreenAgent:
+				c = append(c, tablewriter.FgGreenColor)
 			case codenames.Assassin:
 				c = append(c, tablewriter.BgHiRedColor)
 			}
@@ -223,7 +387,58 @@ func printBoard(b *codenames.Board) {
 	table.Render()
 }
 
-func lobbyShell(reader *bufio.Reader, c *client.Client, gameID codenames.GameID) {
+func replay(reader *bufio.Reader, c *client.Client, gameID codenames.GameID) error {
+	steps, err := c.History(gameID)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	if len(steps) == 0 {
+		fmt.Printf("Game %q has no clues or guesses yet\n", gameID)
+		return nil
+	}
+
+	g, err := c.Game(gameID)
+	if err != nil {
+		return fmt.Errorf("failed to load game: %w", err)
+	}
+	spec := g.State.BoardSpec()
+
+	for i, step := range steps {
+		fmt.Printf("[%d/%d] %s\n", i+1, len(steps), describeEvent(step.Event))
+		printBoard(step.Board, spec)
+
+		if i < len(steps)-1 {
+			fmt.Print("Press enter for the next step")
+			if _, err := reader.ReadString('\n'); err != nil {
+				return fmt.Errorf("failed to read input: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func describeEvent(ev *codenames.Event) string {
+	switch ev.Type {
+	case codenames.EventClueGiven:
+		return fmt.Sprintf("%s spymaster gave the clue %q", ev.Team, ev.Clue)
+	case codenames.EventGuess:
+		if ev.Card == nil {
+			return fmt.Sprintf("%s guessed %q", ev.Team, ev.Guess)
+		}
+		return fmt.Sprintf("%s guessed %q, which was a %s", ev.Team, ev.Guess, ev.Card.Agent)
+	case codenames.EventPass:
+		return fmt.Sprintf("%s passed", ev.Team)
+	case codenames.EventTurnExpired:
+		return fmt.Sprintf("%s ran out of time", ev.Team)
+	case codenames.EventGameEnded:
+		return fmt.Sprintf("Game over, %s won!", ev.Winner)
+	default:
+		return string(ev.Type)
+	}
+}
+
+func lobbyShell(reader *bufio.Reader, c *client.Client, gameID codenames.GameID, strategy web.AssignmentStrategy) {
 	fmt.Println("Welcome to the pre-game lobby! Enter 'help' for help")
 	for {
 		txt, err := reader.ReadString('\n')
@@ -246,7 +461,7 @@ func lobbyShell(reader *bufio.Reader, c *client.Client, gameID codenames.GameID)
 			printPlayers(players)
 			continue
 		case txt == "start":
-			if err := c.StartGame(gameID); err != nil {
+			if err := c.StartGame(gameID, &client.StartOptions{AssignmentStrategy: strategy}); err != nil {
 				log.Printf("failed to start game: %v", err)
 				continue
 			}
@@ -259,6 +474,25 @@ func lobbyShell(reader *bufio.Reader, c *client.Client, gameID codenames.GameID)
 			}
 			fmt.Printf("AI added successfully, ID %q\n", rID)
 			continue
+		case txt == "lock" || txt == "unlock":
+			if err := c.LockTeams(gameID, txt == "lock"); err != nil {
+				log.Printf("failed to %s teams: %v", txt, err)
+			}
+			continue
+		case strings.HasPrefix(txt, "kick"):
+			ps := strings.Split(txt, " ")
+			if len(ps) != 2 {
+				log.Println("invalid args, expected 2")
+				continue
+			}
+			pID := codenames.PlayerID{
+				PlayerType: codenames.PlayerTypeHuman,
+				ID:         ps[1],
+			}
+			if err := c.KickPlayer(gameID, pID); err != nil {
+				log.Printf("failed to kick player: %v", err)
+			}
+			continue
 		case strings.HasPrefix(txt, "assign"):
 			ps := strings.Split(txt, " ")
 			if len(ps) != 4 {
@@ -296,6 +530,8 @@ func printHelp() {
 		"start\t\t\t\tTry to start the game",
 		"players\t\t\t\tList the players in the lobby",
 		"assign PLAYER TEAM ROLE\t\tAssign a player (by ID) to a given team/role",
+		"kick PLAYER\t\t\tRemove a player (by ID) from the game",
+		"lock/unlock\t\t\tStop or let players pick their own team/role",
 	}
 
 	fmt.Println()
@@ -99,7 +99,11 @@ func main() {
 		log.Fatalf("Failed to instantiate game: %v", err)
 	}
 
-	fmt.Println(g.Play())
+	outcome, err := g.Play()
+	if err != nil {
+		log.Fatalf("Failed to play game: %v", err)
+	}
+	fmt.Print(outcome)
 }
 
 func validColor(c string) error {
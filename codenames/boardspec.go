@@ -0,0 +1,111 @@
+package codenames
+
+import "fmt"
+
+const (
+	// MaxRows is the most rows a board can have.
+	MaxRows = 8
+	// MaxColumns is the most columns a board can have.
+	MaxColumns = 8
+)
+
+// BoardSpec describes the layout of a board, how big it is and how many of
+// each type of card are on it.
+type BoardSpec struct {
+	Rows    int `json:"rows"`
+	Columns int `json:"columns"`
+	// Agents is the number of agents each team has. The starting team gets
+	// StarterAgents more on top of that.
+	Agents        int `json:"agents"`
+	StarterAgents int `json:"starter_agents"`
+	Bystanders    int `json:"bystanders"`
+	Assassins     int `json:"assassins"`
+}
+
+var (
+	// StandardBoard is the board from the rulebook: 5x5, with 9 agents for the
+	// starting team, 8 for the other team, 7 bystanders, and the assassin.
+	StandardBoard = &BoardSpec{Rows: Rows, Columns: Columns, Agents: 8, StarterAgents: 1, Bystanders: 7, Assassins: 1}
+	// ThreeTeamBoard is the standard 5x5 board split between three teams.
+	ThreeTeamBoard = &BoardSpec{Rows: Rows, Columns: Columns, Agents: 6, StarterAgents: 1, Bystanders: 5, Assassins: 1}
+	// QuickBoard is a smaller 4x4 board, for short games.
+	QuickBoard = &BoardSpec{Rows: 4, Columns: 4, Agents: 5, StarterAgents: 1, Bystanders: 4, Assassins: 1}
+	// DeepBoard is a bigger 6x6 board with two assassins, for long games.
+	DeepBoard = &BoardSpec{Rows: 6, Columns: 6, Agents: 12, StarterAgents: 1, Bystanders: 9, Assassins: 2}
+	// DuetBoard is the layout of each side of the key card in a Duet game: 5x5,
+	// with 9 agents, 13 bystanders, and 3 assassins. Duet games can't use any
+	// other board.
+	DuetBoard = &BoardSpec{Rows: Rows, Columns: Columns, Agents: 9, Bystanders: 13, Assassins: 3}
+)
+
+// ToBoardSpec returns one of the preset board specs by name.
+func ToBoardSpec(name string) (*BoardSpec, bool) {
+	switch name {
+	case "STANDARD":
+		return StandardBoard.Clone(), true
+	case "QUICK":
+		return QuickBoard.Clone(), true
+	case "DEEP":
+		return DeepBoard.Clone(), true
+	default:
+		return nil, false
+	}
+}
+
+// DefaultBoardSpec returns the spec for a game with the given teams that
+// didn't ask for a specific board.
+func DefaultBoardSpec(teams []Team) *BoardSpec {
+	if len(teams) > 2 {
+		return ThreeTeamBoard
+	}
+	return StandardBoard
+}
+
+func (bs *BoardSpec) Clone() *BoardSpec {
+	if bs == nil {
+		return nil
+	}
+	out := *bs
+	return &out
+}
+
+// Size is the total number of cards on the board.
+func (bs *BoardSpec) Size() int {
+	return bs.Rows * bs.Columns
+}
+
+// Counts returns how many cards of each type should be on the board for a
+// game with the given teams, where starter goes first.
+func (bs *BoardSpec) Counts(teams []Team, starter Team) map[Agent]int {
+	counts := map[Agent]int{
+		Bystander: bs.Bystanders,
+		Assassin:  bs.Assassins,
+	}
+	for _, team := range teams {
+		counts[AgentForTeam(team)] = bs.Agents
+	}
+	counts[AgentForTeam(starter)] += bs.StarterAgents
+	return counts
+}
+
+// Validate checks that the spec makes a playable board for the given teams.
+func (bs *BoardSpec) Validate(teams []Team) error {
+	if bs.Rows < 1 || bs.Rows > MaxRows {
+		return fmt.Errorf("board must have between 1 and %d rows, had %d", MaxRows, bs.Rows)
+	}
+	if bs.Columns < 1 || bs.Columns > MaxColumns {
+		return fmt.Errorf("board must have between 1 and %d columns, had %d", MaxColumns, bs.Columns)
+	}
+	if bs.Agents < 1 {
+		return fmt.Errorf("each team needs at least one agent, had %d", bs.Agents)
+	}
+	if bs.StarterAgents < 0 || bs.Bystanders < 0 || bs.Assassins < 0 {
+		return fmt.Errorf("card counts can't be negative, had %d starter agents, %d bystanders, %d assassins", bs.StarterAgents, bs.Bystanders, bs.Assassins)
+	}
+
+	total := len(teams)*bs.Agents + bs.StarterAgents + bs.Bystanders + bs.Assassins
+	if total != bs.Size() {
+		return fmt.Errorf("a %dx%d board has %d cards, but the spec has %d", bs.Rows, bs.Columns, bs.Size(), total)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package codenames
+
+import "time"
+
+// ChatScope is who a chat message was sent to.
+type ChatScope string
+
+const (
+	// ChatScopeAll messages go to everyone in the game, including spectators.
+	ChatScopeAll = ChatScope("ALL")
+	// ChatScopeTeam messages only go to the operatives on the sender's team, so
+	// nobody can pass anything along to the spymasters or the other team. In
+	// Duet games, they go to everyone on the sender's side.
+	ChatScopeTeam = ChatScope("TEAM")
+)
+
+func ToChatScope(scope string) (ChatScope, bool) {
+	switch scope {
+	case "ALL":
+		return ChatScopeAll, true
+	case "TEAM":
+		return ChatScopeTeam, true
+	default:
+		return "", false
+	}
+}
+
+// ChatMessage is something a player said in a game.
+type ChatMessage struct {
+	PlayerID PlayerID  `json:"player_id"`
+	Scope    ChatScope `json:"scope"`
+	// Team is the team the message was sent to, it's only set for team chat.
+	Team      Team      `json:"team,omitempty"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (cm *ChatMessage) Clone() *ChatMessage {
+	if cm == nil {
+		return nil
+	}
+	out := *cm
+	return &out
+}
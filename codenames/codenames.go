@@ -7,11 +7,13 @@ import (
 )
 
 const (
-	// Rows is the number of rows of cards in Codenames.
+	// Rows is the number of rows of cards in a standard game of Codenames.
 	Rows = 5
-	// Columns is the number of columns of cards in Codenames.
+	// Columns is the number of columns of cards in a standard game of
+	// Codenames.
 	Columns = 5
-	// Size is the total number of cards on a Codenames board.
+	// Size is the total number of cards on a standard Codenames board. Games
+	// can use other sizes, see BoardSpec.
 	Size = Rows * Columns
 )
 
@@ -28,10 +30,14 @@ type Operative interface {
 
 // Board contains all of the information about a game of Codenames.
 type Board struct {
-	// Cards is a list of the 25 words on the board. The zeroth card corresponds
-	// to the top-left, the fourth to the top-right, and the twenty-fourth to the
-	// bottom-right.
+	// Cards is a list of the words on the board, row by row. On a standard 5x5
+	// board, the zeroth card corresponds to the top-left, the fourth to the
+	// top-right, and the twenty-fourth to the bottom-right.
 	Cards []Card `json:"cards"`
+	// Keys is only populated in Duet games, where each side has their own key
+	// card. Keys[team][i] is the agent for Cards[i] from that team's side. In
+	// Duet games, a card's Agent is UnknownAgent until it has been revealed.
+	Keys map[Team][]Agent `json:"keys,omitempty"`
 }
 
 func (b *Board) Clone() *Board {
@@ -42,16 +48,34 @@ func (b *Board) Clone() *Board {
 	cards := make([]Card, len(b.Cards))
 	copy(cards, b.Cards)
 
-	return &Board{Cards: cards}
+	return &Board{Cards: cards, Keys: cloneKeys(b.Keys)}
+}
+
+func cloneKeys(keys map[Team][]Agent) map[Team][]Agent {
+	if keys == nil {
+		return nil
+	}
+
+	out := make(map[Team][]Agent)
+	for team, key := range keys {
+		out[team] = append([]Agent(nil), key...)
+	}
+	return out
 }
 
 // Clue is a word and a count from the Spymaster.
 type Clue struct {
 	Word  string `json:"word"`
 	Count int    `json:"count"`
+	// Unlimited is true if the Spymaster gave an "∞" clue, meaning operatives can
+	// guess as many times as they like. Count is ignored when this is set.
+	Unlimited bool `json:"unlimited"`
 }
 
 func (c *Clue) String() string {
+	if c.Unlimited {
+		return c.Word + " ∞"
+	}
 	return c.Word + " " + strconv.Itoa(c.Count)
 }
 
@@ -66,7 +90,10 @@ type Card struct {
 	// shown to operatives.
 	Revealed bool `json:"revealed"`
 	// Revealed by is set to the team that chose this card to turnover. This is
-	// set to NoTeam unless Revealed is true.
+	// set to NoTeam unless Revealed is true. In Duet games, it's the team whose
+	// key the card was revealed with, and a bystander revealed by one team can
+	// still be guessed from the other team's key. Once a card is a bystander
+	// from both sides, it's set to CoopTeam.
 	RevealedBy Team `json:"revealed_by"`
 }
 
@@ -85,6 +112,8 @@ func (a Agent) String() string {
 		return "Bystander"
 	case Assassin:
 		return "Assassin"
+	case GreenAgent:
+		return "Green Agent"
 	}
 	return ""
 }
@@ -100,6 +129,9 @@ const (
 	Bystander
 	// Assassin means the codename belongs to the assassin.
 	Assassin
+	// GreenAgent means the codename belongs to an agent on the green team, in
+	// three team games.
+	GreenAgent
 )
 
 type Team string
@@ -110,6 +142,10 @@ func (t Team) String() string {
 		return "Red Team"
 	case BlueTeam:
 		return "Blue Team"
+	case GreenTeam:
+		return "Green Team"
+	case CoopTeam:
+		return "Everyone"
 	}
 	return ""
 }
@@ -119,19 +155,82 @@ const (
 	NoTeam   = Team("")
 	RedTeam  = Team("RED")
 	BlueTeam = Team("BLUE")
+	// GreenTeam is the third team, only used in three team games.
+	GreenTeam = Team("GREEN")
+	// CoopTeam is every player in a cooperative game, it's the winner of a Duet
+	// game that the players won. Players can't be assigned to it.
+	CoopTeam = Team("COOP")
+)
+
+// OtherTeam returns the opposing team in a two team game, or in a Duet game,
+// the other side.
+func OtherTeam(t Team) Team {
+	switch t {
+	case RedTeam:
+		return BlueTeam
+	case BlueTeam:
+		return RedTeam
+	default:
+		return NoTeam
+	}
+}
+
+// GameMode is the set of rules a game is being played with.
+type GameMode string
+
+const (
+	// ClassicMode is the standard competitive game, red vs. blue. Games with no
+	// mode set are classic games.
+	ClassicMode = GameMode("CLASSIC")
+	// DuetMode is the two player cooperative game. Each side has their own key
+	// card, and takes turns giving clues to the other side.
+	DuetMode = GameMode("DUET")
 )
 
+func ToGameMode(mode string) (GameMode, bool) {
+	switch mode {
+	case "CLASSIC":
+		return ClassicMode, true
+	case "DUET":
+		return DuetMode, true
+	default:
+		return "", false
+	}
+}
+
 func ToTeam(team string) (Team, bool) {
 	switch team {
 	case "RED":
 		return RedTeam, true
 	case "BLUE":
 		return BlueTeam, true
+	case "GREEN":
+		return GreenTeam, true
 	default:
 		return NoTeam, false
 	}
 }
 
+// DefaultTeams are the teams in a standard game.
+var DefaultTeams = []Team{RedTeam, BlueTeam}
+
+// ThreeTeams are the teams in a three team game.
+var ThreeTeams = []Team{RedTeam, BlueTeam, GreenTeam}
+
+// AgentForTeam returns the type of agent that belongs to the given team.
+func AgentForTeam(team Team) Agent {
+	switch team {
+	case RedTeam:
+		return RedAgent
+	case BlueTeam:
+		return BlueAgent
+	case GreenTeam:
+		return GreenAgent
+	default:
+		return UnknownAgent
+	}
+}
+
 // Unused returns a list of cards that haven't been assigned an Agent type yet.
 func Unused(cards []Card) []Card {
 	return Targets(cards, UnknownAgent)
@@ -161,7 +260,8 @@ func Unrevealed(cards []Card) []Card {
 }
 
 // Revealed takes in a fully-filled out Spymaster board, and returns a new
-// board where the card Agent is only populated for revealed cards.
+// board where the card Agent is only populated for revealed cards. Duet keys
+// are removed.
 func Revealed(b *Board) *Board {
 	out := make([]Card, len(b.Cards))
 	copy(out, b.Cards)
@@ -173,13 +273,23 @@ func Revealed(b *Board) *Board {
 	return &Board{Cards: out}
 }
 
+// KeyView returns the board as seen by the given team in a Duet game, which is
+// what has been revealed so far, plus only that team's key card.
+func KeyView(b *Board, team Team) *Board {
+	out := Revealed(b)
+	if key, ok := b.Keys[team]; ok {
+		out.Keys = map[Team][]Agent{team: append([]Agent(nil), key...)}
+	}
+	return out
+}
+
 // CloneBoard returns a deep copy of the given board.
 func CloneBoard(b *Board) *Board {
 	out := make([]Card, len(b.Cards))
 	for i, card := range b.Cards {
 		out[i] = card
 	}
-	return &Board{Cards: out}
+	return &Board{Cards: out, Keys: cloneKeys(b.Keys)}
 }
 
 func ParseClue(clue string) (*Clue, error) {
@@ -187,6 +297,10 @@ func ParseClue(clue string) (*Clue, error) {
 	if len(ps) != 2 {
 		return nil, fmt.Errorf("malformed clue %q", clue)
 	}
+	switch strings.ToLower(ps[1]) {
+	case "∞", "inf", "unlimited":
+		return &Clue{Word: ps[0], Unlimited: true}, nil
+	}
 	i, err := strconv.Atoi(ps[1])
 	if err != nil {
 		return nil, fmt.Errorf("malformed number of words in clue %q: %v", clue, err)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strings"
+	"time"
 )
 
 var (
@@ -13,6 +14,7 @@ var (
 	ErrUserNotFound            = errors.New("codenames: user not found")
 	ErrRobotNotFound           = errors.New("codenames: robot not found")
 	ErrGameNotFound            = errors.New("codenames: game not found")
+	ErrInvalidCursor           = errors.New("codenames: invalid cursor")
 )
 
 type PlayerType string
@@ -99,8 +101,23 @@ const (
 	NoRole        = Role("")
 	SpymasterRole = Role("SPYMASTER")
 	OperativeRole = Role("OPERATIVE")
+	// SpectatorRole is for players watching the game, who see what operatives
+	// see.
+	SpectatorRole = Role("SPECTATOR")
+	// SpymasterSpectatorRole is for players watching the game who want to see
+	// what spymasters see. They only get to once the game is over, or if the
+	// game creator allows it, and see what operatives see until then.
+	SpymasterSpectatorRole = Role("SPYMASTER_SPECTATOR")
 )
 
+// IsSpectator returns true if the role is for watching a game, not playing in
+// it.
+func (r Role) IsSpectator() bool {
+	return r == SpectatorRole || r == SpymasterSpectatorRole
+}
+
+// ToRole parses the roles that players can be assigned to on a team, which
+// doesn't include spectators.
 func ToRole(role string) (Role, bool) {
 	switch role {
 	case "SPYMASTER":
@@ -167,6 +184,17 @@ type Game struct {
 	CreatedBy UserID     `json:"created_by"`
 	Status    GameStatus `json:"status"`
 	State     *GameState `json:"state"`
+
+	// Winner, FinishedAt, and Outcome are only populated once the game is
+	// Finished.
+	Winner     Team       `json:"winner,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Outcome    *Outcome   `json:"outcome,omitempty"`
+
+	// RematchOf is the game this one is a rematch of, if any, and RematchID is
+	// the rematch of this game, once someone has asked for one.
+	RematchOf GameID `json:"rematch_of,omitempty"`
+	RematchID GameID `json:"rematch_id,omitempty"`
 }
 
 func (g *Game) Clone() *Game {
@@ -174,34 +202,171 @@ func (g *Game) Clone() *Game {
 		return nil
 	}
 
-	return &Game{
+	out := &Game{
 		ID:        g.ID,
 		CreatedBy: g.CreatedBy,
 		Status:    g.Status,
 		State:     g.State.Clone(),
+		Winner:    g.Winner,
+		Outcome:   g.Outcome.Clone(),
+		RematchOf: g.RematchOf,
+		RematchID: g.RematchID,
+	}
+	if g.FinishedAt != nil {
+		finishedAt := *g.FinishedAt
+		out.FinishedAt = &finishedAt
 	}
+	return out
 }
 
 type GameState struct {
-	ActiveTeam     Team   `json:"active_team"`
-	ActiveRole     Role   `json:"active_role"`
-	Board          *Board `json:"board"`
-	NumGuessesLeft int    `json:"num_guesses_left"`
-	StartingTeam   Team   `json:"starting_team"`
+	// Mode is the rules the game is played with, empty means ClassicMode.
+	Mode GameMode `json:"mode"`
+	// ActiveTeam is the team whose turn it is. In Duet games, that's the team
+	// giving the clue, the other team does the guessing.
+	ActiveTeam Team   `json:"active_team"`
+	ActiveRole Role   `json:"active_role"`
+	Board      *Board `json:"board"`
+	// NumGuessesLeft is how many more guesses the active operatives can make, or
+	// UnlimitedGuesses if there's no limit.
+	NumGuessesLeft int  `json:"num_guesses_left"`
+	StartingTeam   Team `json:"starting_team"`
+	// TurnsLeft is only used in Duet games, it's the number of timer tokens
+	// left. The players lose if they run out.
+	TurnsLeft int `json:"turns_left,omitempty"`
+	// Teams are the teams playing, in turn order. Empty means DefaultTeams.
+	Teams []Team `json:"teams,omitempty"`
+	// Spec is the layout of the board. Nil means the default for the teams
+	// playing, see DefaultBoardSpec. Duet games always use DuetBoard.
+	Spec *BoardSpec `json:"spec,omitempty"`
+	// Timers is how long each role gets to take their turn. Nil means turns
+	// aren't timed.
+	Timers *TurnTimers `json:"timers,omitempty"`
+	// Deadline is when the current turn runs out, if it's timed.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Votes are the confirmed votes of the guessing team's operatives this
+	// turn, see the consensus package.
+	Votes []*Vote `json:"votes,omitempty"`
+	// AllowSpymasterSpectators lets spectators who asked for it see the whole
+	// board while the game is in progress.
+	AllowSpymasterSpectators bool `json:"allow_spymaster_spectators,omitempty"`
+	// TeamsLocked means only the game creator can assign teams and roles,
+	// players can't pick their own.
+	TeamsLocked bool `json:"teams_locked,omitempty"`
+	// Unlisted games don't show up in the lobby, players can only join them
+	// by ID.
+	Unlisted bool `json:"unlisted,omitempty"`
+}
+
+// Vote is an operative's confirmed choice of what their team should do next.
+type Vote struct {
+	PlayerID PlayerID `json:"player_id"`
+	// Word is the codename voted for, or empty for a vote to pass.
+	Word string `json:"word"`
+}
+
+// TurnTimers configure how long each role gets to take their turn, in
+// seconds. Zero means that role's turns aren't timed.
+type TurnTimers struct {
+	SpymasterSecs int `json:"spymaster_secs,omitempty"`
+	OperativeSecs int `json:"operative_secs,omitempty"`
+}
+
+// For returns how long the given role gets for their turn, or zero if their
+// turns aren't timed.
+func (tt *TurnTimers) For(role Role) time.Duration {
+	if tt == nil {
+		return 0
+	}
+	switch role {
+	case SpymasterRole:
+		return time.Duration(tt.SpymasterSecs) * time.Second
+	case OperativeRole:
+		return time.Duration(tt.OperativeSecs) * time.Second
+	default:
+		return 0
+	}
 }
 
+func (tt *TurnTimers) Clone() *TurnTimers {
+	if tt == nil {
+		return nil
+	}
+	out := *tt
+	return &out
+}
+
+// BoardSpec returns the layout of the board for the game.
+func (gs *GameState) BoardSpec() *BoardSpec {
+	if gs.Mode == DuetMode {
+		return DuetBoard
+	}
+	if gs.Spec == nil {
+		return DefaultBoardSpec(gs.AllTeams())
+	}
+	return gs.Spec
+}
+
+// AllTeams returns the teams playing the game, in turn order.
+func (gs *GameState) AllTeams() []Team {
+	if len(gs.Teams) == 0 {
+		return DefaultTeams
+	}
+	return gs.Teams
+}
+
+// GuessingTeam returns the team that guesses in response to the active team's
+// clues.
+func (gs *GameState) GuessingTeam() Team {
+	if gs.Mode == DuetMode {
+		return OtherTeam(gs.ActiveTeam)
+	}
+	return gs.ActiveTeam
+}
+
+// ActingTeam returns the team that needs to make a move, which is the team
+// giving the clue or the team guessing, depending on the active role.
+func (gs *GameState) ActingTeam() Team {
+	if gs.ActiveRole == OperativeRole {
+		return gs.GuessingTeam()
+	}
+	return gs.ActiveTeam
+}
+
+// UnlimitedGuesses is the value of NumGuessesLeft when operatives can keep
+// guessing until they miss or pass.
+const UnlimitedGuesses = -1
+
 func (gs *GameState) Clone() *GameState {
 	if gs == nil {
 		return nil
 	}
 
-	return &GameState{
+	out := &GameState{
+		Mode:           gs.Mode,
 		ActiveTeam:     gs.ActiveTeam,
 		ActiveRole:     gs.ActiveRole,
 		Board:          gs.Board.Clone(),
 		NumGuessesLeft: gs.NumGuessesLeft,
 		StartingTeam:   gs.StartingTeam,
+		TurnsLeft:      gs.TurnsLeft,
+		Teams:          append([]Team(nil), gs.Teams...),
+		Spec:           gs.Spec.Clone(),
+		Timers:         gs.Timers.Clone(),
+
+		AllowSpymasterSpectators: gs.AllowSpymasterSpectators,
+		TeamsLocked:              gs.TeamsLocked,
+		Unlisted:                 gs.Unlisted,
 	}
+	if gs.Deadline != nil {
+		deadline := *gs.Deadline
+		out.Deadline = &deadline
+	}
+	for _, v := range gs.Votes {
+		vc := *v
+		out.Votes = append(out.Votes, &vc)
+	}
+	return out
 }
 
 type PlayerRole struct {
@@ -211,6 +376,154 @@ type PlayerRole struct {
 	RoleAssigned bool     `json:"role_assigned"`
 }
 
+// PlayerRecord summarizes how a player did in the games they've finished.
+type PlayerRecord struct {
+	Games int `json:"games"`
+	Wins  int `json:"wins"`
+	// SpymasterGames is how many of those games they were a spymaster for.
+	SpymasterGames int `json:"spymaster_games"`
+	SpymasterWins  int `json:"spymaster_wins"`
+	OperativeGames int `json:"operative_games"`
+	OperativeWins  int `json:"operative_wins"`
+	// LastSpymaster is when they last finished a game as a spymaster, or nil if
+	// they never have.
+	LastSpymaster *time.Time `json:"last_spymaster,omitempty"`
+
+	// Clues and Guesses are the number of clues the player's team gave, and
+	// guesses made in response, across all of their games.
+	Clues   int `json:"clues"`
+	Guesses int `json:"guesses"`
+	// AverageGuessesPerClue is Guesses / Clues, or zero if no clues were given.
+	AverageGuessesPerClue float64 `json:"average_guesses_per_clue"`
+	// AssassinHits is the number of games the player's team lost by finding
+	// the assassin.
+	AssassinHits int `json:"assassin_hits"`
+}
+
+// WinRate is the fraction of games the player won, smoothed so that players
+// who haven't played much are treated as about average. A nil record has a win
+// rate of one half.
+func (pr *PlayerRecord) WinRate() float64 {
+	if pr == nil {
+		return 0.5
+	}
+	return float64(pr.Wins+1) / float64(pr.Games+2)
+}
+
+// Add counts a finished game towards the player's record. Stats are how the
+// player's team did in the game, and may be nil if that isn't known.
+func (pr *PlayerRecord) Add(role Role, won bool, finishedAt time.Time, stats *TeamStats) {
+	pr.Games++
+	if won {
+		pr.Wins++
+	}
+	if stats != nil {
+		pr.Clues += stats.CluesGiven
+		pr.Guesses += stats.Guesses
+		if stats.AssassinHit {
+			pr.AssassinHits++
+		}
+		if pr.Clues > 0 {
+			pr.AverageGuessesPerClue = float64(pr.Guesses) / float64(pr.Clues)
+		}
+	}
+	if role != SpymasterRole {
+		pr.OperativeGames++
+		if won {
+			pr.OperativeWins++
+		}
+		return
+	}
+	pr.SpymasterGames++
+	if won {
+		pr.SpymasterWins++
+	}
+	if pr.LastSpymaster == nil || finishedAt.After(*pr.LastSpymaster) {
+		pr.LastSpymaster = &finishedAt
+	}
+}
+
+// PlayerGame is a game that a player is in, along with their place in it.
+type PlayerGame struct {
+	Game *Game       `json:"game"`
+	Role *PlayerRole `json:"role"`
+}
+
+func (pg *PlayerGame) Clone() *PlayerGame {
+	if pg == nil {
+		return nil
+	}
+	return &PlayerGame{
+		Game: pg.Game.Clone(),
+		Role: pg.Role.Clone(),
+	}
+}
+
+// ListedGame is a pending game, as it's listed in the lobby.
+type ListedGame struct {
+	Game      *Game         `json:"game"`
+	CreatedAt time.Time     `json:"created_at"`
+	Players   []*PlayerRole `json:"players"`
+}
+
+// OpenSpymasters returns the teams in the game that don't have a spymaster
+// yet.
+func (lg *ListedGame) OpenSpymasters() []Team {
+	taken := make(map[Team]bool)
+	for _, pr := range lg.Players {
+		if pr.RoleAssigned && pr.Role == SpymasterRole {
+			taken[pr.Team] = true
+		}
+	}
+
+	var out []Team
+	for _, t := range lg.Game.State.AllTeams() {
+		if !taken[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// HasRobots returns true if any AI players have joined the game.
+func (lg *ListedGame) HasRobots() bool {
+	for _, pr := range lg.Players {
+		if pr.PlayerID.PlayerType == PlayerTypeRobot {
+			return true
+		}
+	}
+	return false
+}
+
+// GameFilter narrows down the games listed in the lobby. The zero value
+// matches every game.
+type GameFilter struct {
+	// OpenSpymaster only matches games where a team still needs a spymaster.
+	OpenSpymaster bool
+	// HasRobots only matches games that AI players have joined.
+	HasRobots bool
+	// CreatedAfter only matches games created after the given time, if set.
+	CreatedAfter time.Time
+}
+
+// Matches returns true if the game should be listed. A nil filter matches
+// every game.
+func (f *GameFilter) Matches(lg *ListedGame) bool {
+	if f == nil {
+		return true
+	}
+	if f.OpenSpymaster && len(lg.OpenSpymasters()) == 0 {
+		return false
+	}
+	if f.HasRobots && !lg.HasRobots() {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && !lg.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	return true
+}
+
 func (pr *PlayerRole) Clone() *PlayerRole {
 	if pr == nil {
 		return nil
@@ -224,7 +537,23 @@ func (pr *PlayerRole) Clone() *PlayerRole {
 	}
 }
 
-func AllRolesFilled(prs []*PlayerRole) error {
+// AllRolesFilled checks that every team has one spymaster and at least one
+// operative. If no teams are given, DefaultTeams are checked.
+func AllRolesFilled(prs []*PlayerRole, teams ...Team) error {
+	if len(teams) == 0 {
+		teams = DefaultTeams
+	}
+	return allRolesFilled(prs, teams, true)
+}
+
+// AllDuetRolesFilled is like AllRolesFilled, but for Duet games, where each
+// side needs someone to give clues, but since the spymasters also guess for
+// the other side, operatives are optional.
+func AllDuetRolesFilled(prs []*PlayerRole) error {
+	return allRolesFilled(prs, DefaultTeams, false)
+}
+
+func allRolesFilled(prs []*PlayerRole, teams []Team, needOperatives bool) error {
 	roleCount := make(map[Team]map[Role]int)
 	for _, pr := range prs {
 		if !pr.RoleAssigned {
@@ -245,8 +574,6 @@ func AllRolesFilled(prs []*PlayerRole) error {
 		}
 		return rm[role]
 	}
-	teams := []Team{BlueTeam, RedTeam}
-
 	for _, t := range teams {
 		switch n := count(t, SpymasterRole); n {
 		case 0:
@@ -256,7 +583,7 @@ func AllRolesFilled(prs []*PlayerRole) error {
 		default:
 			return fmt.Errorf("team %q somehow has %d spymasters", t, n)
 		}
-		if count(t, OperativeRole) == 0 {
+		if needOperatives && count(t, OperativeRole) == 0 {
 			return fmt.Errorf("team %q had no operatives", t)
 		}
 	}
@@ -272,14 +599,69 @@ type DB interface {
 	NewGame(*Game) (GameID, error)
 	StartGame(gID GameID) error
 	PendingGames() ([]GameID, error)
+	// ListGames returns a page of the pending games that are listed in the
+	// lobby and match the filter, newest first. The cursor is where to pick up
+	// from, as returned with the previous page, or empty to start from the
+	// newest game. The returned cursor is empty on the last page. A limit of
+	// zero returns all of them.
+	ListGames(filter *GameFilter, cursor string, limit int) ([]*ListedGame, string, error)
+	// PlayingGames returns the games that have started, but haven't finished.
+	PlayingGames() ([]GameID, error)
 	Game(GameID) (*Game, error)
 	JoinGame(GameID, PlayerID) error
+	// LeaveGame removes a player from a game, whether they left on their own
+	// or were kicked.
+	LeaveGame(GameID, PlayerID) error
 	AssignRole(GameID, *PlayerRole) error
+	// SetGameCreator hands control of a game over to another user.
+	SetGameCreator(GameID, UserID) error
+	// SetRematch links a game to the new game created as its rematch. The
+	// rematch itself is linked back with Game.RematchOf when it's created.
+	SetRematch(gID, rematchID GameID) error
 
 	PlayersInGame(gID GameID) ([]*PlayerRole, error)
 	UpdateState(GameID, *GameState) error
+	// ClaimTurnTimer makes owner the one to expire the turn in a game that ends
+	// at deadline, so that when several servers share the DB, only one of them
+	// does. It reports whether owner has the claim, which it won't if someone
+	// else claimed the same turn first, unless their claim has lapsed. Claims
+	// on a game's earlier turns don't count. Claiming a turn again updates when
+	// the claim lapses.
+	ClaimTurnTimer(gID GameID, owner string, deadline, lapsesAt time.Time) (bool, error)
+	// FinishGame marks a game as finished, recording the winner, when the game
+	// ended, and how it played out.
+	FinishGame(gID GameID, winner Team, outcome *Outcome) error
 	BatchPlayerNames([]PlayerID) (map[PlayerID]string, error)
+	// PlayerRecords returns the record of each of the given players across
+	// their finished games. Players that haven't finished a game get an empty
+	// record.
+	PlayerRecords([]PlayerID) (map[PlayerID]*PlayerRecord, error)
+	// PlayerGames returns a page of the games a player is in, including ones
+	// they're spectating. Games in progress come first, then pending games,
+	// then finished games, most recently finished first. A limit of zero
+	// returns all of them.
+	PlayerGames(pID PlayerID, limit, offset int) ([]*PlayerGame, error)
+	// Ratings returns every rating the given players have, in any role.
+	// Players who haven't played a rated game in a role have no rating for it.
+	Ratings([]PlayerID) ([]*Rating, error)
+	// UpdateRatings saves the given ratings, replacing any existing rating for
+	// the same player and role.
+	UpdateRatings([]*Rating) error
+	// Leaderboard returns the highest rated players in a role, best first.
+	Leaderboard(role Role, limit int) ([]*Rating, error)
 	Player(id PlayerID) (string, error)
+
+	// RecordEvent appends an event to the history of a game.
+	RecordEvent(GameID, *Event) error
+	// GameHistory returns every event recorded for a game, in the order they
+	// were recorded.
+	GameHistory(GameID) ([]*Event, error)
+
+	// AddChatMessage saves a chat message sent in a game.
+	AddChatMessage(GameID, *ChatMessage) error
+	// ChatMessages returns every chat message sent in a game, oldest first,
+	// regardless of who it was sent to.
+	ChatMessages(GameID) ([]*ChatMessage, error)
 }
 
 func RandomGameID(r *rand.Rand) GameID {
@@ -0,0 +1,79 @@
+package codenames
+
+import "time"
+
+// EventType is the kind of thing that happened in a game.
+type EventType string
+
+const (
+	// NoEventType is an error case.
+	NoEventType = EventType("")
+	// EventRoleAssigned means a player was given a team and role in the lobby.
+	EventRoleAssigned = EventType("ROLE_ASSIGNED")
+	// EventPlayerLeft means a player left the game.
+	EventPlayerLeft = EventType("PLAYER_LEFT")
+	// EventPlayerKicked means the game creator removed a player from the game.
+	EventPlayerKicked = EventType("PLAYER_KICKED")
+	// EventGameStarted means the game creator started the game.
+	EventGameStarted = EventType("GAME_STARTED")
+	// EventClueGiven means a spymaster gave a clue to their team.
+	EventClueGiven = EventType("CLUE_GIVEN")
+	// EventVote means an operative voted (tentatively or not) for a card.
+	EventVote = EventType("VOTE")
+	// EventGuess means a team reached consensus and a card was revealed.
+	EventGuess = EventType("GUESS")
+	// EventPass means a team ended their turn without guessing further.
+	EventPass = EventType("PASS")
+	// EventTurnExpired means a team ran out of time on their turn, and it was
+	// passed or skipped.
+	EventTurnExpired = EventType("TURN_EXPIRED")
+	// EventGameEnded means the game was won by a team.
+	EventGameEnded = EventType("GAME_ENDED")
+)
+
+// Event is a single entry in the history of a game. Only the fields relevant
+// to the Type are populated.
+type Event struct {
+	Type      EventType `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// PlayerID is the player that took the action, or the player that had a
+	// role assigned to them for EventRoleAssigned, or was removed for
+	// EventPlayerKicked. It's empty for events that
+	// aren't associated with a single player, like a team reaching consensus.
+	PlayerID PlayerID `json:"player_id"`
+	Team     Team     `json:"team"`
+
+	// Only populated for EventRoleAssigned.
+	Role Role `json:"role,omitempty"`
+	// Only populated for EventClueGiven.
+	Clue *Clue `json:"clue,omitempty"`
+	// Only populated for EventVote and EventGuess. An EventVote with no Guess
+	// is a vote to pass.
+	Guess string `json:"guess,omitempty"`
+	// Only populated for EventVote.
+	Confirmed bool `json:"confirmed,omitempty"`
+	// Only populated for EventGuess, the card that was turned over.
+	Card *Card `json:"card,omitempty"`
+	// Only populated for EventGameEnded.
+	Winner Team `json:"winner,omitempty"`
+}
+
+// Clone returns a deep copy of the event, so callers can hold onto it without
+// worrying about it changing underneath them.
+func (e *Event) Clone() *Event {
+	if e == nil {
+		return nil
+	}
+
+	out := *e
+	if e.Clue != nil {
+		clue := *e.Clue
+		out.Clue = &clue
+	}
+	if e.Card != nil {
+		card := *e.Card
+		out.Card = &card
+	}
+	return &out
+}
@@ -0,0 +1,116 @@
+package codenames
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Outcome is the result of a game, along with some stats about how each team
+// played.
+type Outcome struct {
+	// Winner is the team that won, or NoTeam if the game isn't over yet.
+	Winner Team `json:"winner"`
+	// Teams holds the stats for each team that gave a clue.
+	Teams map[Team]*TeamStats `json:"teams"`
+	// Clues holds the stats for each clue, in the order they were given.
+	Clues []*ClueStats `json:"clues"`
+}
+
+func (o *Outcome) Clone() *Outcome {
+	if o == nil {
+		return nil
+	}
+
+	out := &Outcome{
+		Winner: o.Winner,
+		Teams:  make(map[Team]*TeamStats),
+	}
+	for team, ts := range o.Teams {
+		tsc := *ts
+		out.Teams[team] = &tsc
+	}
+	for _, cs := range o.Clues {
+		out.Clues = append(out.Clues, cs.Clone())
+	}
+	return out
+}
+
+// TeamStats summarizes how a team played over the course of a game.
+type TeamStats struct {
+	// TurnsTaken is the number of turns the team had, each of which starts with
+	// a clue.
+	TurnsTaken int `json:"turns_taken"`
+	CluesGiven int `json:"clues_given"`
+	// AverageClueCount is the mean count of the team's clues, not including
+	// unlimited clues.
+	AverageClueCount float64 `json:"average_clue_count"`
+
+	Guesses int `json:"guesses"`
+	// CorrectGuesses is the number of the team's own agents they revealed.
+	CorrectGuesses int `json:"correct_guesses"`
+	// Misses is the number of guesses that weren't the team's own agents.
+	Misses int `json:"misses"`
+	// OpponentReveals is the number of the other team's agents they revealed.
+	OpponentReveals int  `json:"opponent_reveals"`
+	BystandersHit   int  `json:"bystanders_hit"`
+	AssassinHit     bool `json:"assassin_hit"`
+	// Accuracy is the fraction of guesses that were correct, or zero if the
+	// team never guessed.
+	Accuracy float64 `json:"accuracy"`
+	Passes   int     `json:"passes"`
+}
+
+// ClueStats is how well a single clue worked out.
+type ClueStats struct {
+	Team Team  `json:"team"`
+	Clue *Clue `json:"clue"`
+	// Guesses are the cards that were revealed in response to this clue, in
+	// order.
+	Guesses        []Card `json:"guesses"`
+	CorrectGuesses int    `json:"correct_guesses"`
+}
+
+func (cs *ClueStats) Clone() *ClueStats {
+	if cs == nil {
+		return nil
+	}
+
+	out := &ClueStats{
+		Team:           cs.Team,
+		CorrectGuesses: cs.CorrectGuesses,
+		Guesses:        append([]Card(nil), cs.Guesses...),
+	}
+	if cs.Clue != nil {
+		clue := *cs.Clue
+		out.Clue = &clue
+	}
+	return out
+}
+
+// String returns a short, human-readable summary of the outcome.
+func (o *Outcome) String() string {
+	var sb strings.Builder
+	if o.Winner == NoTeam {
+		sb.WriteString("No winner yet\n")
+	} else {
+		fmt.Fprintf(&sb, "%s won!\n", o.Winner)
+	}
+
+	var teams []Team
+	for team := range o.Teams {
+		teams = append(teams, team)
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i] < teams[j] })
+
+	for _, team := range teams {
+		ts := o.Teams[team]
+		fmt.Fprintf(&sb, "%s: %d clues (avg %.1f), %d/%d correct guesses (%.0f%%), %d bystanders, %d opponent agents, %d passes",
+			team, ts.CluesGiven, ts.AverageClueCount, ts.CorrectGuesses, ts.Guesses, ts.Accuracy*100, ts.BystandersHit, ts.OpponentReveals, ts.Passes)
+		if ts.AssassinHit {
+			sb.WriteString(", hit the assassin")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,126 @@
+package codenames
+
+import "math"
+
+const (
+	// InitialRating is the rating every player starts at in each role.
+	InitialRating = 1500.0
+	// RatingK is the most a single game can move a player's rating.
+	RatingK = 32.0
+)
+
+// Rating is a player's Elo rating in one role. Players are rated separately as
+// spymasters and operatives, since giving clues and guessing are pretty
+// different skills.
+type Rating struct {
+	PlayerID PlayerID `json:"player_id"`
+	Role     Role     `json:"role"`
+	Rating   float64  `json:"rating"`
+	// Games and Wins are the number of rated games the player has played and
+	// won in this role.
+	Games int `json:"games"`
+	Wins  int `json:"wins"`
+}
+
+// NewRating returns the rating a player starts with before they've played a
+// rated game in the given role.
+func NewRating(pID PlayerID, role Role) *Rating {
+	return &Rating{
+		PlayerID: pID,
+		Role:     role,
+		Rating:   InitialRating,
+	}
+}
+
+func (r *Rating) Clone() *Rating {
+	if r == nil {
+		return nil
+	}
+	out := *r
+	return &out
+}
+
+// ExpectedScore is the chance, according to Elo, that a side rated a beats a
+// side rated b.
+func ExpectedScore(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+type ratingKey struct {
+	pID  PlayerID
+	role Role
+}
+
+// RateGame returns the updated ratings for everyone who played in a game won by
+// the given team. Current holds the ratings players had going into the game,
+// players without one for the role they played start at InitialRating.
+// Spectators and players who never got a role aren't rated.
+//
+// Each team is rated as the average of its players' ratings in the roles they
+// played. The winning team is scored as beating each other team, and every
+// player on a team has their rating moved by the same amount.
+func RateGame(prs []*PlayerRole, winner Team, current []*Rating) []*Rating {
+	cur := make(map[ratingKey]*Rating)
+	for _, r := range current {
+		cur[ratingKey{r.PlayerID, r.Role}] = r
+	}
+
+	var (
+		teams   []Team
+		members = make(map[Team][]*Rating)
+	)
+	for _, pr := range prs {
+		if !pr.RoleAssigned || pr.Role.IsSpectator() || pr.Team == NoTeam {
+			continue
+		}
+		r, ok := cur[ratingKey{pr.PlayerID, pr.Role}]
+		if ok {
+			r = r.Clone()
+		} else {
+			r = NewRating(pr.PlayerID, pr.Role)
+		}
+		if _, ok := members[pr.Team]; !ok {
+			teams = append(teams, pr.Team)
+		}
+		members[pr.Team] = append(members[pr.Team], r)
+	}
+
+	if _, ok := members[winner]; !ok || len(teams) < 2 {
+		// Either nobody won or nobody lost, so there's nothing to rate.
+		return nil
+	}
+
+	avg := make(map[Team]float64)
+	for _, t := range teams {
+		var sum float64
+		for _, r := range members[t] {
+			sum += r.Rating
+		}
+		avg[t] = sum / float64(len(members[t]))
+	}
+
+	var out []*Rating
+	for _, t := range teams {
+		var delta float64
+		for _, opp := range teams {
+			// Losing teams didn't beat each other, they only lost to the winner.
+			if opp == t || (t != winner && opp != winner) {
+				continue
+			}
+			score := 0.0
+			if t == winner {
+				score = 1
+			}
+			delta += RatingK * (score - ExpectedScore(avg[t], avg[opp]))
+		}
+		for _, r := range members[t] {
+			r.Rating += delta
+			r.Games++
+			if t == winner {
+				r.Wins++
+			}
+			out = append(out, r)
+		}
+	}
+	return out
+}
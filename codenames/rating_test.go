@@ -0,0 +1,112 @@
+package codenames
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestRateGame(t *testing.T) {
+	var (
+		alice = UserID("alice").AsPlayerID()
+		bob   = UserID("bob").AsPlayerID()
+		carol = UserID("carol").AsPlayerID()
+		dan   = UserID("dan").AsPlayerID()
+		eve   = UserID("eve").AsPlayerID()
+		robot = RobotID("robot").AsPlayerID()
+	)
+
+	prs := []*PlayerRole{
+		{PlayerID: alice, Team: RedTeam, Role: SpymasterRole, RoleAssigned: true},
+		{PlayerID: bob, Team: RedTeam, Role: OperativeRole, RoleAssigned: true},
+		{PlayerID: carol, Team: BlueTeam, Role: SpymasterRole, RoleAssigned: true},
+		{PlayerID: robot, Team: BlueTeam, Role: OperativeRole, RoleAssigned: true},
+		// Neither spectators nor players without a role get rated.
+		{PlayerID: dan, Role: SpectatorRole, RoleAssigned: true},
+		{PlayerID: eve},
+	}
+
+	tests := []struct {
+		desc    string
+		winner  Team
+		current []*Rating
+		want    []*Rating
+	}{
+		{
+			desc:   "even teams",
+			winner: RedTeam,
+			want: []*Rating{
+				{PlayerID: alice, Role: SpymasterRole, Rating: 1516, Games: 1, Wins: 1},
+				{PlayerID: bob, Role: OperativeRole, Rating: 1516, Games: 1, Wins: 1},
+				{PlayerID: carol, Role: SpymasterRole, Rating: 1484, Games: 1},
+				{PlayerID: robot, Role: OperativeRole, Rating: 1484, Games: 1},
+			},
+		},
+		{
+			desc:   "favorite wins",
+			winner: BlueTeam,
+			current: []*Rating{
+				{PlayerID: carol, Role: SpymasterRole, Rating: 1900, Games: 10, Wins: 9},
+				{PlayerID: robot, Role: OperativeRole, Rating: 1500, Games: 3, Wins: 1},
+				// Ratings for other roles aren't touched.
+				{PlayerID: alice, Role: OperativeRole, Rating: 2000, Games: 5, Wins: 5},
+			},
+			// Blue averages 1700 to red's 1500, so was expected to win about 76%
+			// of the time.
+			want: []*Rating{
+				{PlayerID: alice, Role: SpymasterRole, Rating: 1492.31, Games: 1},
+				{PlayerID: bob, Role: OperativeRole, Rating: 1492.31, Games: 1},
+				{PlayerID: carol, Role: SpymasterRole, Rating: 1907.69, Games: 11, Wins: 10},
+				{PlayerID: robot, Role: OperativeRole, Rating: 1507.69, Games: 4, Wins: 2},
+			},
+		},
+		{
+			desc:   "nobody won",
+			winner: NoTeam,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := RateGame(prs, test.winner, test.current)
+			opts := cmp.Options{
+				cmpopts.EquateApprox(0, 0.01),
+				cmpopts.EquateEmpty(),
+			}
+			if diff := cmp.Diff(test.want, got, opts); diff != "" {
+				t.Errorf("unexpected ratings (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRateGameThreeTeams(t *testing.T) {
+	var prs []*PlayerRole
+	for _, team := range []Team{RedTeam, BlueTeam, GreenTeam} {
+		prs = append(prs, &PlayerRole{
+			PlayerID:     UserID(team).AsPlayerID(),
+			Team:         team,
+			Role:         SpymasterRole,
+			RoleAssigned: true,
+		})
+	}
+
+	got := make(map[Team]float64)
+	for _, r := range RateGame(prs, GreenTeam, nil) {
+		got[Team(r.PlayerID.ID)] = r.Rating
+	}
+
+	// The winner beat both other teams, the losers only lost to the winner.
+	want := map[Team]float64{
+		RedTeam:   1484,
+		BlueTeam:  1484,
+		GreenTeam: 1532,
+	}
+	for team, w := range want {
+		if math.Abs(got[team]-w) > 0.01 {
+			t.Errorf("rating for %q = %v, want %v", team, got[team], w)
+		}
+	}
+}
@@ -1,51 +1,55 @@
 package consensus
 
 import (
-	"sync"
-
 	"github.com/bcspragu/Codenames/codenames"
 )
 
-func New() *Guesser {
-	return &Guesser{
-		guesses: make(map[codenames.GameID][]*Vote),
-	}
-}
-
-type Vote struct {
-	PlayerID codenames.PlayerID
-	Word     string
-}
-
-type Guesser struct {
-	mu      sync.Mutex
-	guesses map[codenames.GameID][]*Vote
-}
-
-func (g *Guesser) RecordVote(gID codenames.GameID, pID codenames.PlayerID, word string, totalVoters int) (string, bool) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
+// Pass is the word recorded for players who vote to end their turn instead of
+// guessing a card. Teams reach consensus on passing the same way they do on a
+// guess.
+const Pass = ""
 
-	for _, vote := range g.guesses[gID] {
+// RecordVote records a player's vote in the game state, replacing any vote
+// they already cast this turn, and checks if the votes are enough for
+// consensus among the totalVoters on the team.
+func RecordVote(gs *codenames.GameState, pID codenames.PlayerID, word string, totalVoters int) (string, bool) {
+	for _, vote := range gs.Votes {
 		if vote.PlayerID == pID {
 			// Update an existing player's vote.
 			vote.Word = word
-			return g.reachedConsensus(gID, totalVoters)
+			return reachedConsensus(gs, totalVoters)
 		}
 	}
 
 	// If we're here, this is a new vote.
-	g.guesses[gID] = append(g.guesses[gID], &Vote{
+	gs.Votes = append(gs.Votes, &codenames.Vote{
 		PlayerID: pID,
 		Word:     word,
 	})
 
-	return g.reachedConsensus(gID, totalVoters)
+	return reachedConsensus(gs, totalVoters)
 }
 
-func (g *Guesser) reachedConsensus(gID codenames.GameID, totalVoters int) (string, bool) {
+// RemoveVoter throws out the vote of a player that's no longer in the game, and
+// checks if the remaining votes are enough for consensus among the
+// totalVoters that are left.
+func RemoveVoter(gs *codenames.GameState, pID codenames.PlayerID, totalVoters int) (string, bool) {
+	for i, vote := range gs.Votes {
+		if vote.PlayerID == pID {
+			gs.Votes = append(gs.Votes[:i:i], gs.Votes[i+1:]...)
+			break
+		}
+	}
+
+	if len(gs.Votes) == 0 {
+		return "", false
+	}
+	return reachedConsensus(gs, totalVoters)
+}
+
+func reachedConsensus(gs *codenames.GameState, totalVoters int) (string, bool) {
 	votes := make(map[string]int)
-	for _, vote := range g.guesses[gID] {
+	for _, vote := range gs.Votes {
 		votes[vote.Word]++
 	}
 
@@ -65,9 +69,8 @@ func (g *Guesser) reachedConsensus(gID codenames.GameID, totalVoters int) (strin
 	return "", false
 }
 
-func (g *Guesser) Clear(gID codenames.GameID) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	delete(g.guesses, gID)
+// Clear throws out the votes in the game state, once the team has acted on
+// them or their turn is over.
+func Clear(gs *codenames.GameState) {
+	gs.Votes = nil
 }
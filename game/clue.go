@@ -0,0 +1,109 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/bcspragu/Codenames/codenames"
+	"github.com/bcspragu/Codenames/dict"
+)
+
+// ClueValidator checks that a clue is legal to give for a given board.
+type ClueValidator interface {
+	// ValidateClue returns an *InvalidClueError if the clue breaks the rules.
+	ValidateClue(*codenames.Board, *codenames.Clue) error
+}
+
+// InvalidClueError is returned when a spymaster gives a clue that isn't
+// allowed.
+type InvalidClueError struct {
+	Clue *codenames.Clue
+	// Reason is a human-readable explanation of what was wrong with the clue.
+	Reason string
+}
+
+func (e *InvalidClueError) Error() string {
+	return fmt.Sprintf("invalid clue %q: %s", e.Clue.Word, e.Reason)
+}
+
+// DefaultClueValidator enforces the standard clue rules: a clue must be a
+// single word, can't be or contain a codename that's still visible on the
+// board (or be one of the words in a multi-word codename), and can't be a
+// hyphenated or otherwise joined compound.
+type DefaultClueValidator struct {
+	// Dictionary, if set, additionally requires that clues are real words.
+	Dictionary *dict.Dictionary
+}
+
+func (v *DefaultClueValidator) ValidateClue(b *codenames.Board, clue *codenames.Clue) error {
+	invalid := func(format string, args ...interface{}) error {
+		return &InvalidClueError{Clue: clue, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	word := strings.ToLower(strings.TrimSpace(clue.Word))
+	if word == "" {
+		return invalid("clue can't be empty")
+	}
+
+	if len(strings.Fields(word)) != 1 {
+		return invalid("clue must be a single word")
+	}
+
+	for _, r := range word {
+		switch {
+		case r == '-' || r == '_':
+			return invalid("compound words can't be joined with %q", r)
+		case !unicode.IsLetter(r):
+			return invalid("clue can only contain letters")
+		}
+	}
+
+	// Once a card is revealed, it's covered up and its codename isn't in play
+	// anymore.
+	for _, card := range codenames.Unrevealed(b.Cards) {
+		codename := normalizeCodename(card.Codename)
+		switch {
+		case word == codename:
+			return invalid("%q is on the board", card.Codename)
+		case strings.Contains(word, codename):
+			return invalid("clue contains %q, which is on the board", card.Codename)
+		case isCodenameWord(card.Codename, word):
+			return invalid("clue is part of %q, which is on the board", card.Codename)
+		}
+	}
+
+	if v.Dictionary != nil && !v.Dictionary.Valid(word) {
+		return invalid("clue isn't in the dictionary")
+	}
+
+	return nil
+}
+
+// isCodenameWord returns whether the clue is one of the words in a multi-word
+// codename, like "ice" for "ice_cream". Clues that just happen to be inside a
+// codename, like "ring" for "spring", are fine.
+func isCodenameWord(codename, word string) bool {
+	parts := strings.FieldsFunc(strings.ToLower(codename), func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	})
+	for _, part := range parts {
+		if part == word {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCodename lowercases a codename and strips out the separators in
+// multi-word codenames like "ice_cream", so they can be compared against
+// clues.
+func normalizeCodename(codename string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', ' ':
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, codename)
+}
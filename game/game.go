@@ -18,6 +18,10 @@ import (
 type Game struct {
 	state *codenames.GameState
 	cfg   *Config
+
+	// history is every clue, guess, and pass made through this *Game, which is
+	// the whole game in Play() mode.
+	history []*codenames.Event
 }
 
 // Config holds configuration options for a game of Codenames.
@@ -27,47 +31,177 @@ type Config struct {
 
 	RedOperative  codenames.Operative
 	BlueOperative codenames.Operative
+
+	// GreenSpymaster and GreenOperative are only needed for three team games.
+	GreenSpymaster codenames.Spymaster
+	GreenOperative codenames.Operative
+
+	// Rules determines how many guesses each clue is worth. If nil,
+	// DefaultRules() is used.
+	Rules *Rules
+	// ClueValidator checks clues before they're given. If nil, a
+	// DefaultClueValidator without a dictionary is used.
+	ClueValidator ClueValidator
 }
 
-func NewForMove(state *codenames.GameState) *Game {
-	return &Game{state: state}
+// Rules configures how clues are turned into guesses.
+type Rules struct {
+	// BonusGuess gives operatives one more guess than the count of the clue, so
+	// they can go back for a word from an earlier clue.
+	BonusGuess bool
+	// UnlimitedZero lets operatives guess as many times as they like after a
+	// clue of zero. Otherwise, a zero clue only gets the bonus guess, if any.
+	UnlimitedZero bool
+	// AllowUnlimited lets spymasters give "∞" clues.
+	AllowUnlimited bool
+}
+
+// DefaultRules returns the rules from the official rulebook: operatives get
+// count + 1 guesses, and both zero and "∞" clues allow unlimited guesses.
+func DefaultRules() *Rules {
+	return &Rules{
+		BonusGuess:     true,
+		UnlimitedZero:  true,
+		AllowUnlimited: true,
+	}
+}
+
+// NewForMove loads a game in the given state for use with Move(). The cfg is
+// optional, and only the non-player options in it are used.
+func NewForMove(state *codenames.GameState, cfg *Config) *Game {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	return &Game{state: state, cfg: cfg}
 }
 
 // New validates and initializes a game of Codenames.
 func New(b *codenames.Board, startingTeam codenames.Team, cfg *Config) (*Game, error) {
-	if err := validateBoard(b, startingTeam); err != nil {
-		return nil, fmt.Errorf("invalid board given: %v", err)
+	return NewWithTeams(b, nil, startingTeam, cfg)
+}
+
+// NewWithTeams validates and initializes a game of Codenames with the given
+// teams, like codenames.ThreeTeams, which take turns in the order given. If no
+// teams are given, it's a standard red vs. blue game. The board should match
+// the number of teams, like the ones from boardgen.NewWithTeams.
+func NewWithTeams(b *codenames.Board, teams []codenames.Team, startingTeam codenames.Team, cfg *Config) (*Game, error) {
+	return NewWithSpec(b, nil, teams, startingTeam, cfg)
+}
+
+// NewWithSpec validates and initializes a game of Codenames on a board laid out
+// like the given spec, like the ones from boardgen.NewFromSpec. If the spec is
+// nil, the default board for the teams is used.
+func NewWithSpec(b *codenames.Board, spec *codenames.BoardSpec, teams []codenames.Team, startingTeam codenames.Team, cfg *Config) (*Game, error) {
+	state := &codenames.GameState{
+		Mode:         codenames.ClassicMode,
+		StartingTeam: startingTeam,
+		ActiveTeam:   startingTeam,
+		ActiveRole:   codenames.SpymasterRole,
+		Board:        b,
+		Teams:        teams,
+		Spec:         spec,
 	}
 
-	if cfg.RedSpymaster == nil {
-		return nil, fmt.Errorf("RedSpymaster cannot be nil")
+	if !hasTeam(state.AllTeams(), startingTeam) {
+		return nil, fmt.Errorf("starting team %q isn't playing", startingTeam)
 	}
-	if cfg.BlueSpymaster == nil {
-		return nil, fmt.Errorf("BlueSpymaster cannot be nil")
+
+	if err := state.BoardSpec().Validate(state.AllTeams()); err != nil {
+		return nil, fmt.Errorf("invalid board spec given: %v", err)
 	}
-	if cfg.RedOperative == nil {
-		return nil, fmt.Errorf("RedOperative cannot be nil")
+
+	if err := validateBoard(b, state.BoardSpec(), state.AllTeams(), startingTeam); err != nil {
+		return nil, fmt.Errorf("invalid board given: %v", err)
 	}
-	if cfg.BlueOperative == nil {
-		return nil, fmt.Errorf("BlueOperative cannot be nil")
+
+	if err := validateConfig(cfg, state.AllTeams()); err != nil {
+		return nil, err
+	}
+
+	return &Game{state: state, cfg: cfg}, nil
+}
+
+// DuetTurns is the number of timer tokens players start a Duet game with.
+const DuetTurns = 9
+
+// NewDuet validates and initializes a cooperative game of Codenames Duet. The
+// board should have a key for each team, like the ones from boardgen.NewDuet.
+// Each team's spymaster gives clues from their key to the other team's
+// operative.
+func NewDuet(b *codenames.Board, startingTeam codenames.Team, cfg *Config) (*Game, error) {
+	if err := validateDuetBoard(b); err != nil {
+		return nil, fmt.Errorf("invalid board given: %v", err)
+	}
+
+	if err := validateConfig(cfg, codenames.DefaultTeams); err != nil {
+		return nil, err
 	}
 
 	return &Game{
 		state: &codenames.GameState{
+			Mode:         codenames.DuetMode,
 			StartingTeam: startingTeam,
 			ActiveTeam:   startingTeam,
 			ActiveRole:   codenames.SpymasterRole,
 			Board:        b,
+			TurnsLeft:    DuetTurns,
 		},
 		cfg: cfg,
 	}, nil
 }
 
+func validateConfig(cfg *Config, teams []codenames.Team) error {
+	for _, team := range teams {
+		if cfg.spymaster(team) == nil {
+			return fmt.Errorf("spymaster for %q cannot be nil", team)
+		}
+		if cfg.operative(team) == nil {
+			return fmt.Errorf("operative for %q cannot be nil", team)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) spymaster(team codenames.Team) codenames.Spymaster {
+	switch team {
+	case codenames.RedTeam:
+		return cfg.RedSpymaster
+	case codenames.BlueTeam:
+		return cfg.BlueSpymaster
+	case codenames.GreenTeam:
+		return cfg.GreenSpymaster
+	default:
+		return nil
+	}
+}
+
+func (cfg *Config) operative(team codenames.Team) codenames.Operative {
+	switch team {
+	case codenames.RedTeam:
+		return cfg.RedOperative
+	case codenames.BlueTeam:
+		return cfg.BlueOperative
+	case codenames.GreenTeam:
+		return cfg.GreenOperative
+	default:
+		return nil
+	}
+}
+
+func hasTeam(teams []codenames.Team, team codenames.Team) bool {
+	for _, t := range teams {
+		if t == team {
+			return true
+		}
+	}
+	return false
+}
+
 // validateBoard validates that the board has the correct number of cards of
 // each type.
-func validateBoard(b *codenames.Board, starter codenames.Team) error {
-	if len(b.Cards) != codenames.Size {
-		return fmt.Errorf("board must contain %d codenames, found %d", codenames.Size, len(b.Cards))
+func validateBoard(b *codenames.Board, spec *codenames.BoardSpec, teams []codenames.Team, starter codenames.Team) error {
+	if len(b.Cards) != spec.Size() {
+		return fmt.Errorf("board must contain %d codenames, found %d", spec.Size(), len(b.Cards))
 	}
 
 	got := make(map[codenames.Agent]int)
@@ -75,7 +209,7 @@ func validateBoard(b *codenames.Board, starter codenames.Team) error {
 		got[cn.Agent]++
 	}
 
-	for ag, wc := range want(starter) {
+	for ag, wc := range spec.Counts(teams, starter) {
 		if gc := got[ag]; gc != wc {
 			return fmt.Errorf("got %d cards of type %q, want %d", gc, ag, wc)
 		}
@@ -84,23 +218,33 @@ func validateBoard(b *codenames.Board, starter codenames.Team) error {
 	return nil
 }
 
-func want(starter codenames.Team) map[codenames.Agent]int {
-	w := map[codenames.Agent]int{
-		codenames.RedAgent:  9,
-		codenames.BlueAgent: 8,
-		codenames.Bystander: 7,
-		codenames.Assassin:  1,
+// validateDuetBoard validates that each side of the Duet key has the correct
+// number of cards of each type, as laid out by codenames.DuetBoard.
+func validateDuetBoard(b *codenames.Board) error {
+	spec := codenames.DuetBoard
+	if len(b.Cards) != spec.Size() {
+		return fmt.Errorf("board must contain %d codenames, found %d", spec.Size(), len(b.Cards))
 	}
-	if starter == codenames.BlueTeam {
-		w[codenames.BlueAgent], w[codenames.RedAgent] = 9, 8
+
+	for _, team := range codenames.DefaultTeams {
+		key := b.Keys[team]
+		if len(key) != spec.Size() {
+			return fmt.Errorf("%q key must contain %d agents, found %d", team, spec.Size(), len(key))
+		}
+
+		got := make(map[codenames.Agent]int)
+		for _, ag := range key {
+			got[ag]++
+		}
+
+		for ag, wc := range spec.Counts([]codenames.Team{team}, team) {
+			if gc := got[ag]; gc != wc {
+				return fmt.Errorf("got %d cards of type %q on the %q key, want %d", gc, ag, team, wc)
+			}
+		}
 	}
-	return w
-}
 
-type Outcome struct {
-	Winner codenames.Team
-	// TODO: Add more stats, like correct guesses, misses, guesses for the other
-	// team, if anyone hit the assassin, etc.
+	return nil
 }
 
 type Action string
@@ -108,6 +252,10 @@ type Action string
 const (
 	ActionGiveClue = Action("GIVE_CLUE")
 	ActionGuess    = Action("GUESS")
+	ActionPass     = Action("PASS")
+	// ActionExpire ends the turn of whoever is acting because they ran out of
+	// time, either skipping the spymaster's clue or passing for the operatives.
+	ActionExpire = Action("EXPIRE")
 )
 
 type Move struct {
@@ -126,27 +274,51 @@ func (g *Game) Move(mv *Move) (*codenames.GameState, codenames.GameStatus, error
 		if g.state.ActiveRole != codenames.SpymasterRole {
 			return nil, "", fmt.Errorf("can't give a clue when %q %q should be acting", g.state.ActiveTeam, g.state.ActiveRole)
 		}
+		if mv.Team != g.state.ActiveTeam {
+			return nil, "", fmt.Errorf("%q can't give a clue on %q's turn", mv.Team, g.state.ActiveTeam)
+		}
 		if mv.GiveClue == nil {
 			return nil, "", errors.New("no clue was given")
 		}
-		g.handleGiveClue(mv.GiveClue)
+		if err := g.clueValidator().ValidateClue(g.state.Board, mv.GiveClue); err != nil {
+			return nil, "", err
+		}
+		if err := g.handleGiveClue(mv.GiveClue); err != nil {
+			return nil, "", fmt.Errorf("handleGiveClue(%q): %w", mv.GiveClue, err)
+		}
 	case ActionGuess:
 		if g.state.ActiveRole != codenames.OperativeRole {
 			return nil, "", fmt.Errorf("can't guess when %q %q should be acting", g.state.ActiveTeam, g.state.ActiveRole)
 		}
+		if guesser := g.state.GuessingTeam(); mv.Team != guesser {
+			return nil, "", fmt.Errorf("%q can't guess when %q should be guessing", mv.Team, guesser)
+		}
 		if mv.Guess == "" {
-			// This is passing.
-			g.endTurn()
+			// This is passing, kept around for operatives that don't use ActionPass.
+			g.pass()
 		} else {
 			if err := g.handleGuess(mv.Guess); err != nil {
 				return nil, "", fmt.Errorf("handleGuess(%q): %w", mv.Guess, err)
 			}
 		}
+	case ActionPass:
+		if g.state.ActiveRole != codenames.OperativeRole {
+			return nil, "", fmt.Errorf("can't pass when %q %q should be acting", g.state.ActiveTeam, g.state.ActiveRole)
+		}
+		if guesser := g.state.GuessingTeam(); mv.Team != guesser {
+			return nil, "", fmt.Errorf("%q can't pass when %q should be guessing", mv.Team, guesser)
+		}
+		g.pass()
+	case ActionExpire:
+		if acting := g.state.ActingTeam(); mv.Team != acting {
+			return nil, "", fmt.Errorf("%q can't run out of time when %q is acting", mv.Team, acting)
+		}
+		g.expire()
 	default:
 		return nil, "", fmt.Errorf("unknown action %q", mv.Action)
 	}
 
-	state := codenames.Pending
+	state := codenames.Playing
 	if over, _ := g.GameOver(); over {
 		state = codenames.Finished
 	}
@@ -154,23 +326,85 @@ func (g *Game) Move(mv *Move) (*codenames.GameState, codenames.GameStatus, error
 	return g.state, state, nil
 }
 
-func (g *Game) handleGiveClue(clue *codenames.Clue) {
-	numGuesses := clue.Count
-	if numGuesses == 0 {
-		numGuesses = -1
+func (g *Game) rules() *Rules {
+	if g.cfg == nil || g.cfg.Rules == nil {
+		return DefaultRules()
+	}
+	return g.cfg.Rules
+}
+
+func (g *Game) clueValidator() ClueValidator {
+	if g.cfg == nil || g.cfg.ClueValidator == nil {
+		return &DefaultClueValidator{}
+	}
+	return g.cfg.ClueValidator
+}
+
+func (g *Game) handleGiveClue(clue *codenames.Clue) error {
+	numGuesses, err := g.guessesForClue(clue)
+	if err != nil {
+		return err
 	}
 
 	g.state.NumGuessesLeft = numGuesses
 	g.state.ActiveRole = codenames.OperativeRole
+	g.history = append(g.history, &codenames.Event{
+		Type: codenames.EventClueGiven,
+		Team: g.state.ActiveTeam,
+		Clue: clue,
+	})
+	return nil
+}
+
+// guessesForClue validates the count on a clue and returns how many guesses
+// it's worth, or codenames.UnlimitedGuesses.
+func (g *Game) guessesForClue(clue *codenames.Clue) (int, error) {
+	rules := g.rules()
+
+	if clue.Unlimited {
+		if !rules.AllowUnlimited {
+			return 0, errors.New("unlimited clues aren't allowed in this game")
+		}
+		return codenames.UnlimitedGuesses, nil
+	}
+
+	if clue.Count < 0 {
+		return 0, fmt.Errorf("clue count can't be negative, was %d", clue.Count)
+	}
+
+	remaining := g.agentsLeft(g.state.ActiveTeam)
+	if clue.Count > remaining {
+		return 0, fmt.Errorf("clue count was %d, but %q only has %d agents left", clue.Count, g.state.ActiveTeam, remaining)
+	}
+
+	if clue.Count == 0 && rules.UnlimitedZero {
+		return codenames.UnlimitedGuesses, nil
+	}
+
+	if rules.BonusGuess {
+		return clue.Count + 1, nil
+	}
+	if clue.Count == 0 {
+		return 0, errors.New("a clue of zero doesn't allow any guesses in this game")
+	}
+	return clue.Count, nil
 }
 
 func (g *Game) handleGuess(guess string) error {
-	g.state.NumGuessesLeft--
+	if g.state.NumGuessesLeft != codenames.UnlimitedGuesses {
+		g.state.NumGuessesLeft--
+	}
 
 	c, err := g.reveal(guess)
 	if err != nil {
 		return fmt.Errorf("reveal(%q) on %q: %v", guess, g.state.ActiveTeam, err)
 	}
+	g.history = append(g.history, &codenames.Event{
+		Type:  codenames.EventGuess,
+		Team:  g.state.GuessingTeam(),
+		Guess: guess,
+		Card:  &c,
+	})
 
 	// Check if their guess ended the game.
 	if over, _ := g.GameOver(); over {
@@ -184,27 +418,86 @@ func (g *Game) handleGuess(guess string) error {
 	return nil
 }
 
+func (g *Game) pass() {
+	g.history = append(g.history, &codenames.Event{
+		Type: codenames.EventPass,
+		Team: g.state.GuessingTeam(),
+	})
+	g.endTurn()
+}
+
+func (g *Game) expire() {
+	g.history = append(g.history, &codenames.Event{
+		Type: codenames.EventTurnExpired,
+		Team: g.state.ActingTeam(),
+	})
+	g.endTurn()
+}
+
 func (g *Game) endTurn() {
-	curTeam := g.state.ActiveTeam
-	if curTeam == codenames.BlueTeam {
-		curTeam = codenames.RedTeam
-	} else {
-		curTeam = codenames.BlueTeam
+	next := g.nextTeam()
+	if g.state.Mode == codenames.DuetMode {
+		next = codenames.OtherTeam(g.state.ActiveTeam)
+		// Every turn in Duet costs a timer token, and if one side has found all
+		// of the agents on their partner's key, only the partner gives clues.
+		g.state.TurnsLeft--
+		if g.agentsLeft(next) == 0 {
+			next = g.state.ActiveTeam
+		}
 	}
 	g.state.NumGuessesLeft = 0
-	g.state.ActiveTeam = curTeam
+	g.state.ActiveTeam = next
 	g.state.ActiveRole = codenames.SpymasterRole
 }
 
-func (g *Game) Play() (*Outcome, error) {
+// nextTeam returns the team that goes after the active team, skipping over any
+// teams that have been eliminated.
+func (g *Game) nextTeam() codenames.Team {
+	return nextTeam(g.state.AllTeams(), g.state.ActiveTeam, g.eliminated)
+}
+
+// NextTeam returns the team that goes after the given one, in turn order.
+func NextTeam(teams []codenames.Team, team codenames.Team) codenames.Team {
+	return nextTeam(teams, team, func(codenames.Team) bool { return false })
+}
+
+// nextTeam returns the first team after the given one, in turn order, that
+// isn't skipped. If every other team is skipped, it's the given team again.
+func nextTeam(teams []codenames.Team, team codenames.Team, skip func(codenames.Team) bool) codenames.Team {
+	cur := 0
+	for i, t := range teams {
+		if t == team {
+			cur = i
+			break
+		}
+	}
+
+	for i := 1; i < len(teams); i++ {
+		t := teams[(cur+i)%len(teams)]
+		if !skip(t) {
+			return t
+		}
+	}
+	return team
+}
+
+// eliminated returns true if the given team has revealed an assassin.
+func (g *Game) eliminated(team codenames.Team) bool {
+	for _, card := range g.state.Board.Cards {
+		if card.Revealed && card.Agent == codenames.Assassin && card.RevealedBy == team {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) Play() (*codenames.Outcome, error) {
 	for {
 		// Let's play a round.
-		sm, op := g.cfg.RedSpymaster, g.cfg.RedOperative
-		if g.state.ActiveTeam == codenames.BlueTeam {
-			sm, op = g.cfg.BlueSpymaster, g.cfg.BlueOperative
-		}
+		sm := g.cfg.spymaster(g.state.ActiveTeam)
+		op := g.cfg.operative(g.state.GuessingTeam())
 
-		clue, err := sm.GiveClue(codenames.CloneBoard(g.state.Board), g.activeAgent())
+		clue, err := sm.GiveClue(g.spymasterBoard(), g.activeAgent())
 		if err != nil {
 			return nil, fmt.Errorf("GiveClue on %q: %w", g.state.ActiveTeam, err)
 		}
@@ -217,30 +510,86 @@ func (g *Game) Play() (*Outcome, error) {
 		}
 
 		for g.state.ActiveRole == codenames.OperativeRole {
-			guess, err := op.Guess(codenames.Revealed(g.state.Board), clue)
+			guess, err := op.Guess(g.operativeBoard(), clue)
 			if err != nil {
-				return nil, fmt.Errorf("Guess on %q: %v", g.state.ActiveTeam, err)
+				return nil, fmt.Errorf("Guess on %q: %v", g.state.GuessingTeam(), err)
 			}
-			if _, _, err = g.Move(&Move{
+			_, status, err := g.Move(&Move{
 				Action: ActionGuess,
-				Team:   g.state.ActiveTeam,
+				Team:   g.state.GuessingTeam(),
 				Guess:  guess,
-			}); err != nil {
-				return nil, fmt.Errorf("Guess on %q: %v", g.state.ActiveTeam, err)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("Guess on %q: %v", g.state.GuessingTeam(), err)
 			}
+			if status == codenames.Finished {
+				return g.Outcome(g.history), nil
+			}
+		}
+
+		// In Duet, running out of time can end the game at the end of a turn.
+		if over, _ := g.GameOver(); over {
+			return g.Outcome(g.history), nil
 		}
 	}
 }
 
-func (g *Game) activeAgent() codenames.Agent {
-	switch g.state.ActiveTeam {
-	case codenames.BlueTeam:
-		return codenames.BlueAgent
-	case codenames.RedTeam:
-		return codenames.RedAgent
-	default:
-		return codenames.UnknownAgent
+// spymasterBoard returns the board that the active spymaster gets to see. In
+// Duet games, that's the board with their key filled in.
+func (g *Game) spymasterBoard() *codenames.Board {
+	b := codenames.CloneBoard(g.state.Board)
+	if g.state.Mode != codenames.DuetMode {
+		return b
+	}
+
+	key := b.Keys[g.state.ActiveTeam]
+	for i := range b.Cards {
+		if g.inPlay(i, g.state.ActiveTeam) && i < len(key) {
+			b.Cards[i].Agent = key[i]
+		}
 	}
+	return b
+}
+
+// operativeBoard returns the board that the guessing operatives get to see.
+// In Duet games, they also get to see their own key.
+func (g *Game) operativeBoard() *codenames.Board {
+	if g.state.Mode == codenames.DuetMode {
+		return codenames.KeyView(g.state.Board, g.state.GuessingTeam())
+	}
+	return codenames.Revealed(g.state.Board)
+}
+
+// agentsLeft returns the number of agents the given team still needs to find.
+// In Duet games, that's the agents on their key that haven't been found.
+func (g *Game) agentsLeft(team codenames.Team) int {
+	agent := codenames.AgentForTeam(team)
+	if g.state.Mode != codenames.DuetMode {
+		return len(codenames.Unrevealed(codenames.Targets(g.state.Board.Cards, agent)))
+	}
+
+	n := 0
+	for i, ag := range g.state.Board.Keys[team] {
+		if ag == agent && g.inPlay(i, team) {
+			n++
+		}
+	}
+	return n
+}
+
+// inPlay returns true if the card at the given index can still be guessed
+// from the given team's key. In Duet games, a bystander that was revealed from
+// the other team's key can still be guessed.
+func (g *Game) inPlay(i int, team codenames.Team) bool {
+	card := g.state.Board.Cards[i]
+	if !card.Revealed {
+		return true
+	}
+	return g.state.Mode == codenames.DuetMode && card.Agent == codenames.Bystander && card.RevealedBy == codenames.OtherTeam(team)
+}
+
+func (g *Game) activeAgent() codenames.Agent {
+	return codenames.AgentForTeam(g.state.ActiveTeam)
 }
 
 func (g *Game) reveal(word string) (codenames.Card, error) {
@@ -249,30 +598,43 @@ func (g *Game) reveal(word string) (codenames.Card, error) {
 			continue
 		}
 
-		if g.state.Board.Cards[i].Revealed {
+		if !g.inPlay(i, g.state.ActiveTeam) {
 			return codenames.Card{}, fmt.Errorf("%q has already been guessed", word)
 		}
 
+		c := &g.state.Board.Cards[i]
+		if g.state.Mode == codenames.DuetMode {
+			// Duet cards don't have an agent until they're revealed, and it's
+			// whatever the clue giver's key says.
+			wasBystander := c.Revealed
+			c.Agent = g.state.Board.Keys[g.state.ActiveTeam][i]
+			if wasBystander && c.Agent == codenames.Bystander {
+				// Now it's a bystander from both sides.
+				c.RevealedBy = codenames.CoopTeam
+				return *c, nil
+			}
+		}
+
 		// If the card hasn't been reveal, reveal it.
-		g.state.Board.Cards[i].Revealed = true
-		g.state.Board.Cards[i].RevealedBy = g.state.ActiveTeam
-		return card, nil
+		c.Revealed = true
+		c.RevealedBy = g.state.ActiveTeam
+		return *c, nil
 	}
 	return codenames.Card{}, fmt.Errorf("no card found for guess %q", word)
 }
 
 func (g *Game) canKeepGuessing(card codenames.Card) bool {
-	targetAgent := codenames.RedAgent
-	if g.state.ActiveTeam == codenames.BlueTeam {
-		targetAgent = codenames.BlueAgent
-	}
-
 	// They can keep guessing if the card was for their team and they have
-	// guesses left.
-	return card.Agent == targetAgent && g.state.NumGuessesLeft != 0
+	// guesses left. In Duet, the card needs to be an agent from the clue
+	// giver's side.
+	return card.Agent == g.activeAgent() && g.state.NumGuessesLeft != 0
 }
 
 func (g *Game) GameOver() (bool, codenames.Team) {
+	if g.state.Mode == codenames.DuetMode {
+		return g.duetOver()
+	}
+
 	got := make(map[codenames.Agent]int)
 	for i, cn := range g.state.Board.Cards {
 		if g.state.Board.Cards[i].Revealed {
@@ -280,26 +642,56 @@ func (g *Game) GameOver() (bool, codenames.Team) {
 		}
 	}
 
-	for ag, wc := range want(g.state.StartingTeam) {
-		if gc := got[ag]; gc == wc {
-			switch ag {
-			case codenames.RedAgent:
-				// If we've revealed all the red cards, the red team has won.
-				return true, codenames.RedTeam
-			case codenames.BlueAgent:
-				// If we've revealed all the blue cards, the blue team has won.
-				return true, codenames.BlueTeam
-			case codenames.Assassin:
-				// If we've revealed the assassin, the not-active team wins.
-				switch g.state.ActiveTeam {
-				case codenames.BlueTeam:
-					return true, codenames.RedTeam
-				case codenames.RedTeam:
-					return true, codenames.BlueTeam
-				}
-			}
+	teams := g.state.AllTeams()
+	want := g.state.BoardSpec().Counts(teams, g.state.StartingTeam)
+	var remaining []codenames.Team
+	for _, team := range teams {
+		// A team that hit the assassin is out, even if the other teams go on to
+		// reveal all of their agents.
+		if g.eliminated(team) {
+			continue
+		}
+		// If we've revealed all of a team's cards, that team has won.
+		if ag := codenames.AgentForTeam(team); got[ag] == want[ag] {
+			return true, team
+		}
+		remaining = append(remaining, team)
+	}
+
+	// If everyone else has hit the assassin, the last team standing wins.
+	if len(remaining) == 1 {
+		return true, remaining[0]
+	}
+
+	return false, codenames.NoTeam
+}
+
+// duetOver checks if a Duet game is over. The players win together if they've
+// found every agent from both keys, and lose together if they hit an assassin
+// or run out of turns.
+func (g *Game) duetOver() (bool, codenames.Team) {
+	b := g.state.Board
+	found := true
+	for i, card := range b.Cards {
+		if card.Revealed && card.Agent == codenames.Assassin {
+			return true, codenames.NoTeam
+		}
+
+		isAgent := b.Keys[codenames.RedTeam][i] == codenames.RedAgent || b.Keys[codenames.BlueTeam][i] == codenames.BlueAgent
+		if isAgent && !(card.Revealed && isAgentCard(card.Agent)) {
+			found = false
 		}
 	}
 
+	if found {
+		return true, codenames.CoopTeam
+	}
+	if g.state.TurnsLeft <= 0 {
+		return true, codenames.NoTeam
+	}
 	return false, codenames.NoTeam
 }
+
+func isAgentCard(a codenames.Agent) bool {
+	return a == codenames.RedAgent || a == codenames.BlueAgent || a == codenames.GreenAgent
+}
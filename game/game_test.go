@@ -0,0 +1,563 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/bcspragu/Codenames/boardgen"
+	"github.com/bcspragu/Codenames/codenames"
+)
+
+func TestGiveClue(t *testing.T) {
+	noExtras := &Rules{}
+
+	tests := []struct {
+		desc    string
+		clue    *codenames.Clue
+		rules   *Rules
+		want    int
+		wantErr bool
+	}{
+		{
+			desc: "count plus one",
+			clue: &codenames.Clue{Word: "animal", Count: 2},
+			want: 3,
+		},
+		{
+			desc: "zero is unlimited",
+			clue: &codenames.Clue{Word: "animal", Count: 0},
+			want: codenames.UnlimitedGuesses,
+		},
+		{
+			desc: "infinity is unlimited",
+			clue: &codenames.Clue{Word: "animal", Unlimited: true},
+			want: codenames.UnlimitedGuesses,
+		},
+		{
+			desc: "every remaining agent",
+			clue: &codenames.Clue{Word: "animal", Count: 9},
+			want: 10,
+		},
+		{
+			desc:    "negative count",
+			clue:    &codenames.Clue{Word: "animal", Count: -1},
+			wantErr: true,
+		},
+		{
+			desc:    "more than the remaining agents",
+			clue:    &codenames.Clue{Word: "animal", Count: 10},
+			wantErr: true,
+		},
+		{
+			desc:  "no bonus guess",
+			clue:  &codenames.Clue{Word: "animal", Count: 2},
+			rules: noExtras,
+			want:  2,
+		},
+		{
+			desc:    "zero without bonus or unlimited",
+			clue:    &codenames.Clue{Word: "animal", Count: 0},
+			rules:   noExtras,
+			wantErr: true,
+		},
+		{
+			desc:    "unlimited not allowed",
+			clue:    &codenames.Clue{Word: "animal", Unlimited: true},
+			rules:   noExtras,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			state := &codenames.GameState{
+				ActiveTeam:   codenames.RedTeam,
+				ActiveRole:   codenames.SpymasterRole,
+				StartingTeam: codenames.RedTeam,
+				Board:        boardgen.New(codenames.RedTeam, rand.New(rand.NewSource(0))),
+			}
+
+			newState, _, err := NewForMove(state, &Config{Rules: test.rules}).Move(&Move{
+				Action:   ActionGiveClue,
+				Team:     codenames.RedTeam,
+				GiveClue: test.clue,
+			})
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("wanted an error giving clue, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Move: %v", err)
+			}
+
+			if newState.NumGuessesLeft != test.want {
+				t.Errorf("NumGuessesLeft = %d, want %d", newState.NumGuessesLeft, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateClue(t *testing.T) {
+	board := &codenames.Board{
+		Cards: []codenames.Card{
+			{Codename: "horse"},
+			{Codename: "ice_cream"},
+			{Codename: "lemon"},
+			{Codename: "spring"},
+			{Codename: "catch"},
+			{Codename: "ship", Revealed: true},
+		},
+	}
+
+	tests := []struct {
+		word    string
+		wantErr bool
+	}{
+		{word: "animal"},
+		{word: "Saddle"},
+		// Revealed cards are covered up, so they're fair game.
+		{word: "ship"},
+		{word: "", wantErr: true},
+		{word: "two words", wantErr: true},
+		{word: "sea-horse", wantErr: true},
+		{word: "sea_monster", wantErr: true},
+		{word: "r2d2", wantErr: true},
+		{word: "HORSE", wantErr: true},
+		{word: "horseshoe", wantErr: true},
+		{word: "icecream", wantErr: true},
+		{word: "ice", wantErr: true},
+		{word: "Cream", wantErr: true},
+		// Short clues that happen to be inside a codename are fine.
+		{word: "lemo"},
+		{word: "ring"},
+		{word: "cat"},
+		{word: "on"},
+		{word: "a"},
+	}
+
+	v := &DefaultClueValidator{}
+	for _, test := range tests {
+		err := v.ValidateClue(board, &codenames.Clue{Word: test.word, Count: 1})
+		if !test.wantErr {
+			if err != nil {
+				t.Errorf("ValidateClue(%q): %v", test.word, err)
+			}
+			continue
+		}
+
+		var icErr *InvalidClueError
+		if !errors.As(err, &icErr) {
+			t.Errorf("ValidateClue(%q) = %v, want an *InvalidClueError", test.word, err)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	state := &codenames.GameState{
+		ActiveTeam:   codenames.RedTeam,
+		ActiveRole:   codenames.SpymasterRole,
+		StartingTeam: codenames.RedTeam,
+		Board: &codenames.Board{
+			Cards: []codenames.Card{
+				{Codename: "horse", Agent: codenames.RedAgent},
+				{Codename: "lemon", Agent: codenames.RedAgent},
+				{Codename: "ship", Agent: codenames.BlueAgent},
+				{Codename: "ghost", Agent: codenames.Bystander},
+				{Codename: "bomb", Agent: codenames.Assassin},
+			},
+		},
+	}
+
+	g := NewForMove(state, nil)
+	moves := []*Move{
+		{Action: ActionGiveClue, Team: codenames.RedTeam, GiveClue: &codenames.Clue{Word: "animal", Count: 1}},
+		{Action: ActionGuess, Team: codenames.RedTeam, Guess: "horse"},
+		{Action: ActionGuess, Team: codenames.RedTeam, Guess: "ghost"},
+		{Action: ActionGiveClue, Team: codenames.BlueTeam, GiveClue: &codenames.Clue{Word: "boat", Count: 1}},
+		{Action: ActionGuess, Team: codenames.BlueTeam, Guess: "bomb"},
+	}
+	var history []*codenames.Event
+	for _, mv := range moves {
+		if _, _, err := g.Move(mv); err != nil {
+			t.Fatalf("Move(%+v): %v", mv, err)
+		}
+		history = append(history, g.history[len(g.history)-1])
+	}
+
+	out := g.Outcome(history)
+	if out.Winner != codenames.RedTeam {
+		t.Errorf("Winner = %q, want %q", out.Winner, codenames.RedTeam)
+	}
+
+	red, blue := out.Teams[codenames.RedTeam], out.Teams[codenames.BlueTeam]
+	if red == nil || blue == nil {
+		t.Fatalf("missing team stats, got %+v", out.Teams)
+	}
+	if red.Guesses != 2 || red.CorrectGuesses != 1 || red.BystandersHit != 1 || red.Accuracy != 0.5 {
+		t.Errorf("unexpected red stats %+v", red)
+	}
+	if !blue.AssassinHit || blue.Misses != 1 {
+		t.Errorf("unexpected blue stats %+v", blue)
+	}
+	if len(out.Clues) != 2 || out.Clues[0].CorrectGuesses != 1 || len(out.Clues[0].Guesses) != 2 {
+		t.Errorf("unexpected clue stats %+v", out.Clues)
+	}
+}
+
+func TestDuet(t *testing.T) {
+	board := boardgen.NewDuet(rand.New(rand.NewSource(0)))
+	red, blue := board.Keys[codenames.RedTeam], board.Keys[codenames.BlueTeam]
+
+	// find returns the codename of the first card with the given agents on the
+	// red and blue keys.
+	find := func(redAg, blueAg codenames.Agent) string {
+		for i, card := range board.Cards {
+			if red[i] == redAg && blue[i] == blueAg {
+				return card.Codename
+			}
+		}
+		t.Fatalf("no card on board was %q for red and %q for blue", redAg, blueAg)
+		return ""
+	}
+	redOnly := find(codenames.RedAgent, codenames.Bystander)
+	blueOnly := find(codenames.Bystander, codenames.BlueAgent)
+	blueAssassin := find(codenames.Bystander, codenames.Assassin)
+
+	state := &codenames.GameState{
+		Mode:         codenames.DuetMode,
+		ActiveTeam:   codenames.RedTeam,
+		ActiveRole:   codenames.SpymasterRole,
+		StartingTeam: codenames.RedTeam,
+		Board:        board,
+		TurnsLeft:    DuetTurns,
+	}
+	if err := validateDuetBoard(board); err != nil {
+		t.Fatalf("validateDuetBoard: %v", err)
+	}
+	bad := board.Clone()
+	for i, ag := range bad.Keys[codenames.BlueTeam] {
+		if ag == codenames.Bystander {
+			bad.Keys[codenames.BlueTeam][i] = codenames.Assassin
+			break
+		}
+	}
+	if err := validateDuetBoard(bad); err == nil {
+		t.Error("validateDuetBoard accepted a key with an extra assassin")
+	}
+
+	g := NewForMove(state, nil)
+
+	move := func(mv *Move) codenames.GameStatus {
+		t.Helper()
+		_, status, err := g.Move(mv)
+		if err != nil {
+			t.Fatalf("Move(%+v): %v", mv, err)
+		}
+		return status
+	}
+
+	// Red gives clues from their key, and blue guesses.
+	move(&Move{Action: ActionGiveClue, Team: codenames.RedTeam, GiveClue: &codenames.Clue{Word: "thing", Count: 1}})
+	if _, _, err := g.Move(&Move{Action: ActionGuess, Team: codenames.RedTeam, Guess: redOnly}); err == nil {
+		t.Error("red was able to guess their own clue")
+	}
+	move(&Move{Action: ActionGuess, Team: codenames.BlueTeam, Guess: redOnly})
+	if state.ActiveRole != codenames.OperativeRole {
+		t.Fatalf("blue should be able to keep guessing after finding an agent")
+	}
+
+	// A bystander from red's side ends the turn and uses up a timer token...
+	move(&Move{Action: ActionGuess, Team: codenames.BlueTeam, Guess: blueOnly})
+	if state.ActiveTeam != codenames.BlueTeam || state.ActiveRole != codenames.SpymasterRole {
+		t.Errorf("after missing, %q %q was active, want %q %q", state.ActiveTeam, state.ActiveRole, codenames.BlueTeam, codenames.SpymasterRole)
+	}
+	if state.TurnsLeft != DuetTurns-1 {
+		t.Errorf("TurnsLeft = %d, want %d", state.TurnsLeft, DuetTurns-1)
+	}
+
+	// ...but it's still an agent from blue's side, so red can find it.
+	move(&Move{Action: ActionGiveClue, Team: codenames.BlueTeam, GiveClue: &codenames.Clue{Word: "thing", Count: 2}})
+	move(&Move{Action: ActionGuess, Team: codenames.RedTeam, Guess: blueOnly})
+	if card, _ := findCard(state.Board, blueOnly); card.Agent != codenames.BlueAgent {
+		t.Errorf("%q was revealed as %q, want %q", blueOnly, card.Agent, codenames.BlueAgent)
+	}
+
+	if status := move(&Move{Action: ActionGuess, Team: codenames.RedTeam, Guess: blueAssassin}); status != codenames.Finished {
+		t.Fatalf("game status after hitting the assassin was %q, want %q", status, codenames.Finished)
+	}
+	if over, winner := g.GameOver(); !over || winner != codenames.NoTeam {
+		t.Errorf("GameOver() = %t, %q, want true, no winner", over, winner)
+	}
+}
+
+func TestDuetWin(t *testing.T) {
+	board := boardgen.NewDuet(rand.New(rand.NewSource(0)))
+
+	// Reveal every agent but one.
+	var last string
+	for i := range board.Cards {
+		switch {
+		case board.Keys[codenames.RedTeam][i] == codenames.RedAgent:
+			if last == "" {
+				last = board.Cards[i].Codename
+				continue
+			}
+			board.Cards[i].Agent = codenames.RedAgent
+		case board.Keys[codenames.BlueTeam][i] == codenames.BlueAgent:
+			board.Cards[i].Agent = codenames.BlueAgent
+		default:
+			continue
+		}
+		board.Cards[i].Revealed = true
+	}
+
+	g := NewForMove(&codenames.GameState{
+		Mode:       codenames.DuetMode,
+		ActiveTeam: codenames.RedTeam,
+		ActiveRole: codenames.OperativeRole,
+		Board:      board,
+		TurnsLeft:  1,
+	}, nil)
+
+	_, status, err := g.Move(&Move{Action: ActionGuess, Team: codenames.BlueTeam, Guess: last})
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if status != codenames.Finished {
+		t.Fatalf("game status after finding every agent was %q, want %q", status, codenames.Finished)
+	}
+	if _, winner := g.GameOver(); winner != codenames.CoopTeam {
+		t.Errorf("winner was %q, want %q", winner, codenames.CoopTeam)
+	}
+}
+
+func TestThreeTeams(t *testing.T) {
+	board := boardgen.NewWithTeams(codenames.ThreeTeams, codenames.RedTeam, rand.New(rand.NewSource(0)))
+
+	// find returns the codename of the first unrevealed card of the given type.
+	find := func(agent codenames.Agent) string {
+		for _, card := range board.Cards {
+			if card.Agent == agent && !card.Revealed {
+				return card.Codename
+			}
+		}
+		t.Fatalf("no unrevealed %q on the board", agent)
+		return ""
+	}
+
+	state := &codenames.GameState{
+		Mode:         codenames.ClassicMode,
+		ActiveTeam:   codenames.RedTeam,
+		ActiveRole:   codenames.SpymasterRole,
+		StartingTeam: codenames.RedTeam,
+		Board:        board,
+		Teams:        codenames.ThreeTeams,
+	}
+	g := NewForMove(state, nil)
+
+	move := func(mv *Move) codenames.GameStatus {
+		t.Helper()
+		_, status, err := g.Move(mv)
+		if err != nil {
+			t.Fatalf("Move(%+v): %v", mv, err)
+		}
+		return status
+	}
+	turn := func(team codenames.Team, guess string) codenames.GameStatus {
+		t.Helper()
+		move(&Move{Action: ActionGiveClue, Team: team, GiveClue: &codenames.Clue{Word: "thing", Count: 1}})
+		return move(&Move{Action: ActionGuess, Team: team, Guess: guess})
+	}
+
+	// Turns go red, blue, green, and back to red.
+	for _, team := range []codenames.Team{codenames.RedTeam, codenames.BlueTeam, codenames.GreenTeam} {
+		if state.ActiveTeam != team {
+			t.Fatalf("active team was %q, want %q", state.ActiveTeam, team)
+		}
+		turn(team, find(codenames.Bystander))
+	}
+	if state.ActiveTeam != codenames.RedTeam {
+		t.Fatalf("active team was %q, want %q", state.ActiveTeam, codenames.RedTeam)
+	}
+
+	// Red hits the assassin, which knocks them out, but the game goes on.
+	if status := turn(codenames.RedTeam, find(codenames.Assassin)); status != codenames.Playing {
+		t.Fatalf("game status after red hit the assassin was %q, want %q", status, codenames.Playing)
+	}
+	if state.ActiveTeam != codenames.BlueTeam {
+		t.Errorf("active team was %q, want %q", state.ActiveTeam, codenames.BlueTeam)
+	}
+
+	// Red gets skipped from now on.
+	turn(codenames.BlueTeam, find(codenames.Bystander))
+	move(&Move{Action: ActionGiveClue, Team: codenames.GreenTeam, GiveClue: &codenames.Clue{Word: "thing", Count: 1}})
+	move(&Move{Action: ActionPass, Team: codenames.GreenTeam})
+	if state.ActiveTeam != codenames.BlueTeam {
+		t.Errorf("active team was %q, want %q", state.ActiveTeam, codenames.BlueTeam)
+	}
+
+	// Blue finds all of their agents and wins.
+	move(&Move{Action: ActionGiveClue, Team: codenames.BlueTeam, GiveClue: &codenames.Clue{Word: "thing", Unlimited: true}})
+	var status codenames.GameStatus
+	for status != codenames.Finished {
+		status = move(&Move{Action: ActionGuess, Team: codenames.BlueTeam, Guess: find(codenames.BlueAgent)})
+	}
+	if _, winner := g.GameOver(); winner != codenames.BlueTeam {
+		t.Errorf("winner was %q, want %q", winner, codenames.BlueTeam)
+	}
+}
+
+func TestNextTeam(t *testing.T) {
+	tests := []struct {
+		teams []codenames.Team
+		team  codenames.Team
+		want  codenames.Team
+	}{
+		{codenames.DefaultTeams, codenames.RedTeam, codenames.BlueTeam},
+		{codenames.DefaultTeams, codenames.BlueTeam, codenames.RedTeam},
+		{codenames.ThreeTeams, codenames.BlueTeam, codenames.GreenTeam},
+		{codenames.ThreeTeams, codenames.GreenTeam, codenames.RedTeam},
+	}
+
+	for _, test := range tests {
+		if got := NextTeam(test.teams, test.team); got != test.want {
+			t.Errorf("NextTeam(%v, %q) = %q, want %q", test.teams, test.team, got, test.want)
+		}
+	}
+}
+
+func TestValidateBoard(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	tests := []struct {
+		desc    string
+		board   *codenames.Board
+		spec    *codenames.BoardSpec
+		wantErr bool
+	}{
+		{
+			desc:  "standard",
+			board: boardgen.New(codenames.RedTeam, r),
+			spec:  codenames.StandardBoard,
+		},
+		{
+			desc:  "quick",
+			board: boardgen.NewFromSpec(codenames.QuickBoard, codenames.DefaultTeams, codenames.RedTeam, r),
+			spec:  codenames.QuickBoard,
+		},
+		{
+			desc:  "deep",
+			board: boardgen.NewFromSpec(codenames.DeepBoard, codenames.DefaultTeams, codenames.RedTeam, r),
+			spec:  codenames.DeepBoard,
+		},
+		{
+			desc:    "wrong size",
+			board:   boardgen.New(codenames.RedTeam, r),
+			spec:    codenames.QuickBoard,
+			wantErr: true,
+		},
+		{
+			desc:    "wrong starter",
+			board:   boardgen.NewFromSpec(codenames.DeepBoard, codenames.DefaultTeams, codenames.BlueTeam, r),
+			spec:    codenames.DeepBoard,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := validateBoard(test.board, test.spec, codenames.DefaultTeams, codenames.RedTeam)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("wanted an error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateBoard: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeepBoard(t *testing.T) {
+	board := boardgen.NewFromSpec(codenames.DeepBoard, codenames.DefaultTeams, codenames.RedTeam, rand.New(rand.NewSource(0)))
+
+	state := &codenames.GameState{
+		Mode:         codenames.ClassicMode,
+		ActiveTeam:   codenames.RedTeam,
+		ActiveRole:   codenames.SpymasterRole,
+		StartingTeam: codenames.RedTeam,
+		Board:        board,
+		Spec:         codenames.DeepBoard,
+	}
+	g := NewForMove(state, nil)
+
+	// Red has 13 agents to find on a deep board.
+	if _, _, err := g.Move(&Move{Action: ActionGiveClue, Team: codenames.RedTeam, GiveClue: &codenames.Clue{Word: "thing", Count: 13}}); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+
+	var assassin string
+	for _, card := range board.Cards {
+		if card.Agent == codenames.Assassin {
+			assassin = card.Codename
+			break
+		}
+	}
+	_, status, err := g.Move(&Move{Action: ActionGuess, Team: codenames.RedTeam, Guess: assassin})
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if status != codenames.Finished {
+		t.Fatalf("game status after hitting an assassin was %q, want %q", status, codenames.Finished)
+	}
+	if _, winner := g.GameOver(); winner != codenames.BlueTeam {
+		t.Errorf("winner was %q, want %q", winner, codenames.BlueTeam)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	state := &codenames.GameState{
+		Mode:         codenames.ClassicMode,
+		ActiveTeam:   codenames.RedTeam,
+		ActiveRole:   codenames.SpymasterRole,
+		StartingTeam: codenames.RedTeam,
+		Board:        boardgen.New(codenames.RedTeam, rand.New(rand.NewSource(0))),
+	}
+	g := NewForMove(state, nil)
+
+	if _, _, err := g.Move(&Move{Action: ActionExpire, Team: codenames.BlueTeam}); err == nil {
+		t.Error("blue was able to run out of time on red's turn")
+	}
+
+	// Red's spymaster runs out of time, so their turn is skipped.
+	if _, _, err := g.Move(&Move{Action: ActionExpire, Team: codenames.RedTeam}); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if state.ActiveTeam != codenames.BlueTeam || state.ActiveRole != codenames.SpymasterRole {
+		t.Errorf("after red's spymaster expired, %q %q was active, want %q %q", state.ActiveTeam, state.ActiveRole, codenames.BlueTeam, codenames.SpymasterRole)
+	}
+
+	// Blue's operatives run out of time, so their turn is passed.
+	if _, _, err := g.Move(&Move{Action: ActionGiveClue, Team: codenames.BlueTeam, GiveClue: &codenames.Clue{Word: "thing", Count: 1}}); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if _, _, err := g.Move(&Move{Action: ActionExpire, Team: codenames.BlueTeam}); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if state.ActiveTeam != codenames.RedTeam || state.ActiveRole != codenames.SpymasterRole {
+		t.Errorf("after blue's operatives expired, %q %q was active, want %q %q", state.ActiveTeam, state.ActiveRole, codenames.RedTeam, codenames.SpymasterRole)
+	}
+}
+
+func findCard(b *codenames.Board, codename string) (codenames.Card, bool) {
+	for _, card := range b.Cards {
+		if card.Codename == codename {
+			return card, true
+		}
+	}
+	return codenames.Card{}, false
+}
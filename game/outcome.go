@@ -0,0 +1,90 @@
+package game
+
+import "github.com/bcspragu/Codenames/codenames"
+
+// Outcome tallies up the stats for the game from the given history, which
+// should be every clue, guess and pass made in the game so far. Other event
+// types are ignored. Games being played with Play() track their own history,
+// games being played with Move() should pass in the history recorded by the
+// caller.
+func (g *Game) Outcome(history []*codenames.Event) *codenames.Outcome {
+	out := &codenames.Outcome{
+		Teams: make(map[codenames.Team]*codenames.TeamStats),
+	}
+	if over, winner := g.GameOver(); over {
+		out.Winner = winner
+	}
+
+	stats := func(team codenames.Team) *codenames.TeamStats {
+		ts, ok := out.Teams[team]
+		if !ok {
+			ts = &codenames.TeamStats{}
+			out.Teams[team] = ts
+		}
+		return ts
+	}
+
+	// Unlimited clues don't have a count, so they're left out of the average.
+	countTotals, numCounted := make(map[codenames.Team]int), make(map[codenames.Team]int)
+	// In Duet, one team guesses for the other team's clues, and any agent is a
+	// correct guess.
+	duet := g.state.Mode == codenames.DuetMode
+	var curClue *codenames.ClueStats
+	for _, ev := range history {
+		switch ev.Type {
+		case codenames.EventClueGiven:
+			ts := stats(ev.Team)
+			ts.TurnsTaken++
+			ts.CluesGiven++
+			if ev.Clue != nil && !ev.Clue.Unlimited {
+				countTotals[ev.Team] += ev.Clue.Count
+				numCounted[ev.Team]++
+			}
+
+			curClue = &codenames.ClueStats{Team: ev.Team, Clue: ev.Clue}
+			out.Clues = append(out.Clues, curClue)
+		case codenames.EventGuess:
+			if ev.Card == nil {
+				continue
+			}
+			ts := stats(ev.Team)
+			ts.Guesses++
+
+			correct := false
+			switch {
+			case ev.Card.Agent == codenames.AgentForTeam(ev.Team), duet && isAgentCard(ev.Card.Agent):
+				correct = true
+				ts.CorrectGuesses++
+			case ev.Card.Agent == codenames.Bystander:
+				ts.BystandersHit++
+			case ev.Card.Agent == codenames.Assassin:
+				ts.AssassinHit = true
+			default:
+				ts.OpponentReveals++
+			}
+			if !correct {
+				ts.Misses++
+			}
+
+			if curClue != nil && (curClue.Team == ev.Team || duet) {
+				curClue.Guesses = append(curClue.Guesses, *ev.Card)
+				if correct {
+					curClue.CorrectGuesses++
+				}
+			}
+		case codenames.EventPass:
+			stats(ev.Team).Passes++
+		}
+	}
+
+	for team, ts := range out.Teams {
+		if n := numCounted[team]; n > 0 {
+			ts.AverageClueCount = float64(countTotals[team]) / float64(n)
+		}
+		if ts.Guesses > 0 {
+			ts.Accuracy = float64(ts.CorrectGuesses) / float64(ts.Guesses)
+		}
+	}
+
+	return out
+}
@@ -50,10 +50,18 @@ func Unauthorized(format string, args ...interface{}) *Error {
 	return newError(http.StatusUnauthorized, format, args...)
 }
 
+func NotFound(format string, args ...interface{}) *Error {
+	return newError(http.StatusNotFound, format, args...)
+}
+
 func Forbidden(format string, args ...interface{}) *Error {
 	return newError(http.StatusForbidden, format, args...)
 }
 
+func TooManyRequests(format string, args ...interface{}) *Error {
+	return newError(http.StatusTooManyRequests, format, args...)
+}
+
 func Teapot(format string, args ...interface{}) *Error {
 	return newError(http.StatusTeapot, format, args...)
 }
@@ -0,0 +1,124 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/bcspragu/Codenames/codenames"
+)
+
+// Backend carries messages between hubs, so players connected to different
+// server processes all get the messages sent to their game.
+//
+// Everything published is delivered to every subscriber, including the hub
+// that published it, in the order it was published.
+type Backend interface {
+	// Publish sends a message to every hub sharing the backend.
+	Publish(msg []byte) error
+	// Subscribe returns the messages published to the backend. It's called
+	// once, when the hub is created.
+	Subscribe() <-chan []byte
+	// Close stops delivering messages, and closes the subscription.
+	Close() error
+}
+
+var errBackendClosed = errors.New("backend is closed")
+
+// envelope is what hubs send each other through the backend.
+type envelope struct {
+	// From is the ID of the hub that sent the message.
+	From string `json:"from"`
+
+	// GameID and Msg are set for messages to a game. PlayerID is set if the
+	// message is only for one player.
+	GameID   codenames.GameID    `json:"game_id,omitempty"`
+	PlayerID *codenames.PlayerID `json:"player_id,omitempty"`
+	Msg      json.RawMessage     `json:"msg,omitempty"`
+	// Disconnect is set, along with GameID and PlayerID, to close all of the
+	// player's connections to the game.
+	Disconnect bool `json:"disconnect,omitempty"`
+
+	// Presence is set for updates about who is connected to the hub.
+	Presence *presenceUpdate `json:"presence,omitempty"`
+}
+
+// memoryBackend is a Backend for a single hub. It's what hubs use if they
+// aren't given one, so they work on their own.
+type memoryBackend struct {
+	mu     sync.RWMutex
+	closed bool
+	msgs   chan []byte
+}
+
+// NewMemoryBackend returns a Backend that only delivers messages within the
+// process, to a single hub.
+func NewMemoryBackend() Backend {
+	return &memoryBackend{msgs: make(chan []byte)}
+}
+
+func (mb *memoryBackend) Publish(msg []byte) error {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	if mb.closed {
+		return errBackendClosed
+	}
+	mb.msgs <- msg
+	return nil
+}
+
+func (mb *memoryBackend) Subscribe() <-chan []byte {
+	return mb.msgs
+}
+
+func (mb *memoryBackend) Close() error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if !mb.closed {
+		mb.closed = true
+		close(mb.msgs)
+	}
+	return nil
+}
+
+// outbox publishes messages to a backend in the order they were pushed, without
+// making the caller wait on the backend.
+type outbox struct {
+	backend Backend
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	msgs [][]byte
+}
+
+func newOutbox(b Backend) *outbox {
+	o := &outbox{backend: b}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+func (o *outbox) push(msg []byte) {
+	o.mu.Lock()
+	o.msgs = append(o.msgs, msg)
+	o.mu.Unlock()
+	o.cond.Signal()
+}
+
+func (o *outbox) run() {
+	for {
+		o.mu.Lock()
+		for len(o.msgs) == 0 {
+			o.cond.Wait()
+		}
+		msgs := o.msgs
+		o.msgs = nil
+		o.mu.Unlock()
+
+		for _, msg := range msgs {
+			if err := o.backend.Publish(msg); err != nil {
+				log.Printf("failed to publish to hub backend: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package hub
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/bcspragu/Codenames/codenames"
+)
+
+// backlog is the most recent messages sent to a game.
+type backlog struct {
+	// epoch identifies this backlog, so sequence numbers from a different one
+	// (e.g. one that was thrown out, or one on another hub) aren't mistaken
+	// for ours.
+	epoch string
+	// seq is the sequence number of the last message sent to the game.
+	seq      uint64
+	msgs     []*loggedMsg
+	lastUsed time.Time
+}
+
+type loggedMsg struct {
+	seq uint64
+	// playerID is who the message was sent to, or nil if it was sent to
+	// everyone in the game.
+	playerID *codenames.PlayerID
+	msg      []byte
+}
+
+func newBacklog() *backlog {
+	return &backlog{epoch: randomID()}
+}
+
+// add gives the message the next sequence number and adds it to the backlog,
+// returning the message with the sequence number in it.
+func (b *backlog) add(msg []byte, pID *codenames.PlayerID) []byte {
+	b.seq++
+	msg = withSeq(msg, b.seq)
+	if len(b.msgs) == backlogSize {
+		copy(b.msgs, b.msgs[1:])
+		b.msgs = b.msgs[:len(b.msgs)-1]
+	}
+	b.msgs = append(b.msgs, &loggedMsg{seq: b.seq, playerID: pID, msg: msg})
+	return msg
+}
+
+// since returns the messages for the given player sent after the given
+// sequence number in the given epoch, or false if some of them aren't in the
+// backlog anymore.
+func (b *backlog) since(epoch string, seq uint64, pID codenames.PlayerID) ([][]byte, bool) {
+	if epoch != b.epoch {
+		// They're from before the backlog was thrown out, or another hub.
+		return nil, false
+	}
+	if seq > b.seq {
+		// They made up a number.
+		return nil, false
+	}
+	if seq < b.seq && (len(b.msgs) == 0 || b.msgs[0].seq > seq+1) {
+		return nil, false
+	}
+
+	var out [][]byte
+	for _, m := range b.msgs {
+		if m.seq <= seq {
+			continue
+		}
+		if m.playerID != nil && *m.playerID != pID {
+			continue
+		}
+		out = append(out, m.msg)
+	}
+	return out, true
+}
+
+// withSeq adds the sequence number to an encoded message. Every message we
+// send is a JSON object, so the number is spliced in as the first field.
+func withSeq(msg []byte, seq uint64) []byte {
+	trimmed := bytes.TrimSpace(msg)
+	if len(trimmed) < 2 || trimmed[0] != '{' {
+		return msg
+	}
+	rest := bytes.TrimSpace(trimmed[1:])
+
+	out := make([]byte, 0, len(msg)+32)
+	out = append(out, `{"seq":`...)
+	out = strconv.AppendUint(out, seq, 10)
+	if rest[0] != '}' {
+		out = append(out, ',')
+	}
+	out = append(out, rest...)
+	return append(out, '\n')
+}
+
+// randomID returns an ID that's unique across processes, unlike the ones from
+// math/rand, which every process seeds the same way by default.
+func randomID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// This should never happen, crypto/rand doesn't fail on any platform
+		// we run on.
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Connected is the first message sent on every connection. Epoch and Seq say
+// where the game's messages were at when the player connected, and are what
+// they should give to Reconnect if the connection drops before they get any
+// other messages.
+type Connected struct {
+	Epoch string `json:"epoch"`
+	Seq   uint64 `json:"seq"`
+}
+
+func (c *Connected) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Epoch  string `json:"epoch"`
+		Seq    uint64 `json:"seq"`
+		Action string `json:"action"`
+	}{c.Epoch, c.Seq, "CONNECTED"})
+}
+
+// Resync is sent instead of the missed messages to a player who reconnects
+// after the messages they missed were dropped from the backlog, or who
+// reconnects to a different hub. They should reload the game, and can
+// reconnect from Epoch and Seq afterwards.
+type Resync struct {
+	Epoch string `json:"epoch"`
+	Seq   uint64 `json:"seq"`
+}
+
+func (r *Resync) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Epoch  string `json:"epoch"`
+		Seq    uint64 `json:"seq"`
+		Action string `json:"action"`
+	}{r.Epoch, r.Seq, "RESYNC"})
+}
@@ -20,7 +20,7 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 
 	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+	maxMessageSize = 4096
 )
 
 // connection is an middleman between the websocket connection and the hub.
@@ -30,11 +30,21 @@ type connection struct {
 	// What room this connection is associated with.
 	gameID   codenames.GameID
 	playerID codenames.PlayerID
-	// The websocket connection.
+	// The websocket connection, or nil for listeners, which read from send
+	// themselves.
 	ws *websocket.Conn
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// Handles messages sent by the player, if set.
+	onMessage MessageHandler
+
+	// If replay is set, the player is sent the messages after since in the
+	// given epoch when the connection is registered.
+	replay bool
+	epoch  string
+	since  uint64
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -50,12 +60,27 @@ func (c *connection) readPump() {
 		return nil
 	})
 	for {
-		_, _, err := c.ws.ReadMessage()
+		mt, msg, err := c.ws.ReadMessage()
 		if err != nil {
 			log.Printf("failed to read WebSocket message from client: %v", err)
 			break
 		}
+		if mt != websocket.TextMessage || c.onMessage == nil {
+			continue
+		}
+		// Messages are handled one at a time, in the order they were sent.
+		c.onMessage(msg, c.reply)
+	}
+}
+
+// reply sends a message back to just this connection.
+func (c *connection) reply(msg interface{}) error {
+	dat, err := encode(msg)
+	if err != nil {
+		return err
 	}
+	c.h.direct <- &directMsg{conn: c, msg: dat}
+	return nil
 }
 
 // write writes a message with the given message type and payload.
@@ -4,139 +4,528 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"log"
 	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/bcspragu/Codenames/codenames"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// backlogSize is how many of a game's most recent messages are kept around
+	// for players who reconnect. It's smaller than a connection's send buffer,
+	// so replaying the whole backlog can't overflow it.
+	backlogSize = 200
+
+	// backlogTTL is how long a game's backlog is kept once nobody is connected
+	// to it and nothing has been sent to it.
+	backlogTTL = 10 * time.Minute
+
+	// sweepPeriod is how often idle backlogs and silent hubs are cleaned up.
+	sweepPeriod = time.Minute
+)
+
 // Hub maintains the set of active connections and broadcasts messages to the
 // connections.
+//
+// Messages sent with ToGame and ToPlayer go through the hub's Backend, which
+// delivers them to every hub sharing it, so players get them regardless of
+// which server process they're connected to. By default, the backend is in
+// memory, and only this hub gets them.
+//
+// Every message sent to a game with ToGame or ToPlayer gets a sequence number,
+// which is added to the message as a "seq" field. Sequence numbers go up by
+// one for each message sent to a game, so players will see gaps for messages
+// that went to other players. The most recent messages for each game are kept
+// in a backlog, so players whose connections drop can Reconnect and get the
+// messages they missed. Each backlog has a random epoch, which players are
+// sent in a Connected message when they connect, and have to give back when
+// they reconnect. Sequence numbers are only meaningful to the hub that gave
+// them out, so that way, players that reconnect to a different hub (or after
+// the backlog was thrown out) get a Resync instead of the wrong messages.
 type Hub struct {
+	// id identifies this hub to other hubs sharing the backend.
+	id string
+
+	backend Backend
+	// incoming is what the hub receives from the backend, including the
+	// messages it sent itself.
+	incoming <-chan []byte
+	// outbox holds presence updates for the backend, which are published in
+	// order in the background so the run loop never waits on the backend.
+	outbox *outbox
+
 	// Registered connections.
 	connections map[codenames.GameID][]*connection
 
-	// Messages to send to everyone in a game.
-	broadcast chan *broadcastMsg
+	// Recent messages sent to each game.
+	backlogs map[codenames.GameID]*backlog
 
-	// Messages to send to a single player in a game.
-	player chan *playerMsg
+	// Who is connected to each hub sharing the backend.
+	presence *presence
+
+	// Messages to send to a single connection.
+	direct chan *directMsg
 
 	// Register requests from the connections.
 	register chan *connection
 
 	// Unregister requests from connections.
 	unregister chan *connection
+
+	// Requests for who is connected to a game.
+	online chan *onlineReq
+
+	// Makes the messages sent when players come and go, if set.
+	presenceMsg PresenceFunc
+}
+
+// Option configures optional behavior of the hub.
+type Option func(*Hub)
+
+// PresenceFunc returns the message to send to everyone in a game when a player
+// comes online, meaning their first connection to the game opened, or goes
+// offline, meaning their last one closed.
+type PresenceFunc func(gID codenames.GameID, pID codenames.PlayerID, online bool) interface{}
+
+// WithPresence announces when players come and go from a game, using the
+// messages returned by fn.
+func WithPresence(fn PresenceFunc) Option {
+	return func(h *Hub) {
+		h.presenceMsg = fn
+	}
+}
+
+// WithBackend sends messages through the given backend, instead of only to
+// connections to this hub.
+func WithBackend(b Backend) Option {
+	return func(h *Hub) {
+		h.backend = b
+	}
 }
 
 // New creates a new Hub and starts it in a background Go routine.
-func New() *Hub {
+func New(opts ...Option) *Hub {
 	h := &Hub{
-		broadcast:   make(chan *broadcastMsg),
-		player:      make(chan *playerMsg),
+		id:          randomID(),
+		direct:      make(chan *directMsg),
 		register:    make(chan *connection),
 		unregister:  make(chan *connection),
+		online:      make(chan *onlineReq),
 		connections: make(map[codenames.GameID][]*connection),
+		backlogs:    make(map[codenames.GameID]*backlog),
+		presence:    newPresence(),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	if h.backend == nil {
+		h.backend = NewMemoryBackend()
+	}
+
+	h.incoming = h.backend.Subscribe()
+	h.outbox = newOutbox(h.backend)
+
+	go h.outbox.run()
 	go h.run()
 	return h
 }
 
 func (h *Hub) run() {
+	sweep := time.NewTicker(sweepPeriod)
+	defer sweep.Stop()
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+
 	for {
 		select {
 		case c := <-h.register:
 			conns := h.connections[c.gameID]
 			h.connections[c.gameID] = append(conns, c)
+			h.greet(c)
+			if !h.connectedHere(c.gameID, c.playerID, c) {
+				h.updatePresence(&presenceUpdate{Online: []gamePlayer{{c.gameID, c.playerID}}})
+			}
 		case c := <-h.unregister:
 			h.deleteConn(c)
-		case m := <-h.broadcast:
-			for _, c := range h.connections[m.gameID] {
-				select {
-				case c.send <- m.msg:
-				default:
-					h.deleteConn(c)
-				}
+		case dat, ok := <-h.incoming:
+			if !ok {
+				log.Print("hub backend closed, no more messages will be received")
+				h.incoming = nil
+				continue
 			}
-		case m := <-h.player:
-			for _, c := range h.connections[m.gameID] {
-				if c.playerID == m.playerID {
-					select {
-					case c.send <- m.msg:
-					default:
-						h.deleteConn(c)
-					}
-				}
+			h.receive(dat)
+		case m := <-h.direct:
+			h.send(m.conn, m.msg)
+		case req := <-h.online:
+			req.resp <- h.presence.online(req.gameID)
+		case now := <-heartbeat.C:
+			h.publishPresence(h.snapshot())
+			h.presence.expire(h.id, now, h.announce)
+		case now := <-sweep.C:
+			h.sweep(now)
+		}
+	}
+}
+
+// receive handles an envelope from the backend.
+func (h *Hub) receive(dat []byte) {
+	var env envelope
+	if err := json.Unmarshal(dat, &env); err != nil {
+		log.Printf("failed to decode message from hub backend: %v", err)
+		return
+	}
+
+	if env.Presence != nil {
+		// Our own updates were applied when we sent them.
+		if env.From != h.id {
+			h.presence.update(env.From, env.Presence, time.Now(), h.announce)
+		}
+		return
+	}
+
+	if env.PlayerID == nil {
+		h.toGame(env.GameID, env.Msg)
+		return
+	}
+
+	if env.Disconnect {
+		for _, c := range h.conns(env.GameID) {
+			if c.playerID == *env.PlayerID {
+				h.deleteConn(c)
+			}
+		}
+		return
+	}
+
+	msg := h.backlog(env.GameID).add(env.Msg, env.PlayerID)
+	for _, c := range h.conns(env.GameID) {
+		if c.playerID == *env.PlayerID {
+			h.send(c, msg)
+		}
+	}
+}
+
+// conns returns a copy of the connections to a game, since sending to them
+// can drop connections that have fallen behind.
+func (h *Hub) conns(gID codenames.GameID) []*connection {
+	return append([]*connection(nil), h.connections[gID]...)
+}
+
+func (h *Hub) toGame(gID codenames.GameID, msg []byte) {
+	msg = h.backlog(gID).add(msg, nil)
+	for _, c := range h.conns(gID) {
+		h.send(c, msg)
+	}
+}
+
+// send queues a message to be written to the connection, dropping the
+// connection if it has fallen too far behind.
+func (h *Hub) send(c *connection, msg []byte) {
+	// The connection might have gone away since the message was sent, or been
+	// dropped while sending to the connections before it.
+	if !h.registered(c) {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		h.deleteConn(c)
+	}
+}
+
+// greet tells a new connection where the game's messages are at, and sends a
+// reconnected player the messages they missed, or a Resync if they missed more
+// than we kept around.
+func (h *Hub) greet(c *connection) {
+	b := h.backlog(c.gameID)
+	var (
+		msgs [][]byte
+		ok   = true
+	)
+	if c.replay {
+		msgs, ok = b.since(c.epoch, c.since, c.playerID)
+	}
+
+	var greeting interface{} = &Connected{Epoch: b.epoch, Seq: b.seq}
+	if !ok {
+		greeting = &Resync{Epoch: b.epoch, Seq: b.seq}
+	}
+	dat, err := encode(greeting)
+	if err != nil {
+		// This should never happen, it's our own message.
+		panic(err)
+	}
+	h.send(c, dat)
+
+	for _, msg := range msgs {
+		h.send(c, msg)
+	}
+}
+
+// announce tells everyone in the game connected to this hub that a player came
+// online or went offline. Every hub sees the same presence updates, so they
+// each tell their own connections.
+func (h *Hub) announce(gID codenames.GameID, pID codenames.PlayerID, online bool) {
+	if h.presenceMsg == nil {
+		return
+	}
+	dat, err := encode(h.presenceMsg(gID, pID, online))
+	if err != nil {
+		log.Printf("failed to encode presence message for player %q in game %q: %v", pID, gID, err)
+		return
+	}
+	h.toGame(gID, dat)
+}
+
+// updatePresence applies a change to who is connected to this hub, and tells
+// the other hubs about it.
+func (h *Hub) updatePresence(pu *presenceUpdate) {
+	h.presence.update(h.id, pu, time.Now(), h.announce)
+	h.publishPresence(pu)
+}
+
+func (h *Hub) publishPresence(pu *presenceUpdate) {
+	dat, err := json.Marshal(&envelope{From: h.id, Presence: pu})
+	if err != nil {
+		log.Printf("failed to encode presence update: %v", err)
+		return
+	}
+	h.outbox.push(dat)
+}
+
+// snapshot returns everyone connected to this hub.
+func (h *Hub) snapshot() *presenceUpdate {
+	pu := &presenceUpdate{Snapshot: true}
+	for gID, conns := range h.connections {
+		seen := make(map[codenames.PlayerID]bool)
+		for _, c := range conns {
+			if seen[c.playerID] {
+				continue
 			}
+			seen[c.playerID] = true
+			pu.Online = append(pu.Online, gamePlayer{gID, c.playerID})
+		}
+	}
+	return pu
+}
+
+// connectedHere returns whether the player has a connection to this hub, other
+// than the given one.
+func (h *Hub) connectedHere(gID codenames.GameID, pID codenames.PlayerID, except *connection) bool {
+	for _, c := range h.connections[gID] {
+		if c.playerID == pID && c.id != except.id {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hub) backlog(gID codenames.GameID) *backlog {
+	b, ok := h.backlogs[gID]
+	if !ok {
+		b = newBacklog()
+		h.backlogs[gID] = b
+	}
+	b.lastUsed = time.Now()
+	return b
+}
+
+// sweep throws out the backlogs for games nobody has been connected to or sent
+// anything to for a while.
+func (h *Hub) sweep(now time.Time) {
+	for gID, b := range h.backlogs {
+		if len(h.connections[gID]) > 0 {
+			b.lastUsed = now
+			continue
+		}
+		if now.Sub(b.lastUsed) > backlogTTL {
+			delete(h.backlogs, gID)
+		}
+	}
+}
+
+func (h *Hub) registered(c *connection) bool {
+	for _, rconn := range h.connections[c.gameID] {
+		if rconn.id == c.id {
+			return true
 		}
 	}
+	return false
 }
 
 func (h *Hub) deleteConn(c *connection) {
-	close(c.send)
 	rconns := h.connections[c.gameID]
 	for i, rconn := range rconns {
 		if rconn.id == c.id {
-			// Remove the connection.
+			// Remove the connection. It's only closed here, since a connection
+			// that fell behind can be deleted before it unregisters itself.
+			close(c.send)
 			copy(rconns[i:], rconns[i+1:])
 			rconns[len(rconns)-1] = nil
 			h.connections[c.gameID] = rconns[:len(rconns)-1]
+			if !h.connectedHere(c.gameID, c.playerID, c) {
+				h.updatePresence(&presenceUpdate{Offline: []gamePlayer{{c.gameID, c.playerID}}})
+			}
 			return
 		}
 	}
 }
 
-type broadcastMsg struct {
-	gameID codenames.GameID
-	msg    []byte
+type directMsg struct {
+	conn *connection
+	msg  []byte
 }
 
-// ToGame sends a message to everyone in a game.
-func (h *Hub) ToGame(gID codenames.GameID, msg interface{}) error {
+// MessageHandler handles a message that a player sent over their connection
+// to a game. Reply sends a message back over the same connection.
+type MessageHandler func(msg []byte, reply func(interface{}) error)
+
+func encode(msg interface{}) ([]byte, error) {
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(msg); err != nil {
-		return fmt.Errorf("failed to encode message: %w", err)
+		return nil, fmt.Errorf("failed to encode message: %w", err)
 	}
+	return buf.Bytes(), nil
+}
 
-	h.broadcast <- &broadcastMsg{
-		gameID: gID,
-		msg:    buf.Bytes(),
-	}
+// ToGame sends a message to everyone in a game.
+func (h *Hub) ToGame(gID codenames.GameID, msg interface{}) error {
+	return h.publish(gID, nil, msg)
+}
 
-	return nil
+// ToPlayer sends a message to a single player in a game, on every connection
+// they have to it.
+func (h *Hub) ToPlayer(gID codenames.GameID, pID codenames.PlayerID, msg interface{}) error {
+	return h.publish(gID, &pID, msg)
 }
 
-type playerMsg struct {
-	gameID   codenames.GameID
-	playerID codenames.PlayerID
-	msg      []byte
+// Disconnect closes every connection a player has to a game, on any hub
+// sharing the backend, e.g. because they were kicked out of it. Messages sent
+// to the game before Disconnect was called are still delivered first.
+func (h *Hub) Disconnect(gID codenames.GameID, pID codenames.PlayerID) error {
+	return h.publishEnvelope(&envelope{
+		From:       h.id,
+		GameID:     gID,
+		PlayerID:   &pID,
+		Disconnect: true,
+	})
 }
 
-func (h *Hub) ToPlayer(gID codenames.GameID, pID codenames.PlayerID, msg interface{}) error {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(msg); err != nil {
-		return fmt.Errorf("failed to encode message: %w", err)
+func (h *Hub) publish(gID codenames.GameID, pID *codenames.PlayerID, msg interface{}) error {
+	dat, err := encode(msg)
+	if err != nil {
+		return err
 	}
 
-	h.player <- &playerMsg{
-		gameID:   gID,
-		playerID: pID,
-		msg:      buf.Bytes(),
+	return h.publishEnvelope(&envelope{
+		From:     h.id,
+		GameID:   gID,
+		PlayerID: pID,
+		Msg:      dat,
+	})
+}
+
+func (h *Hub) publishEnvelope(e *envelope) error {
+	env, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode envelope: %w", err)
 	}
 
+	if err := h.backend.Publish(env); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
 	return nil
 }
 
-// Register associates a connection with the hub and a given game.
-func (h *Hub) Register(ws *websocket.Conn, gID codenames.GameID, pID codenames.PlayerID) {
-	conn := &connection{
+type onlineReq struct {
+	gameID codenames.GameID
+	resp   chan []codenames.PlayerID
+}
+
+// Online returns the players with at least one open connection to a game, on
+// any hub sharing the backend.
+func (h *Hub) Online(gID codenames.GameID) []codenames.PlayerID {
+	req := &onlineReq{gameID: gID, resp: make(chan []codenames.PlayerID, 1)}
+	h.online <- req
+	return <-req.resp
+}
+
+// Register associates a connection with the hub and a given game. Messages
+// the player sends over the connection are passed to onMessage, if it isn't
+// nil.
+func (h *Hub) Register(ws *websocket.Conn, gID codenames.GameID, pID codenames.PlayerID, onMessage MessageHandler) {
+	h.addConn(&connection{
+		id:        newID(gID),
+		h:         h,
+		gameID:    gID,
+		playerID:  pID,
+		send:      make(chan []byte, 256),
+		ws:        ws,
+		onMessage: onMessage,
+	})
+}
+
+// Reconnect is like Register, but first sends the player any messages for the
+// game sent after the given sequence number in the given epoch. If some of
+// those aren't in the backlog anymore, or the epoch doesn't match, the player
+// is sent a Resync instead.
+func (h *Hub) Reconnect(ws *websocket.Conn, gID codenames.GameID, pID codenames.PlayerID, epoch string, since uint64, onMessage MessageHandler) {
+	h.addConn(&connection{
+		id:        newID(gID),
+		h:         h,
+		gameID:    gID,
+		playerID:  pID,
+		send:      make(chan []byte, 256),
+		ws:        ws,
+		onMessage: onMessage,
+		replay:    true,
+		epoch:     epoch,
+		since:     since,
+	})
+}
+
+// Listen registers a player with a game like Register does, but for
+// connections that aren't WebSockets and only receive messages, like a
+// Server-Sent Events stream. Messages for the player are sent on the returned
+// channel, which is closed if the listener falls too far behind. Calling stop
+// unregisters the listener.
+func (h *Hub) Listen(gID codenames.GameID, pID codenames.PlayerID) (msgs <-chan []byte, stop func()) {
+	return h.listen(&connection{
 		id:       newID(gID),
 		h:        h,
 		gameID:   gID,
 		playerID: pID,
 		send:     make(chan []byte, 256),
-		ws:       ws,
+	})
+}
+
+// ListenSince is like Listen, but first sends the messages after the given
+// sequence number in the given epoch, like Reconnect does.
+func (h *Hub) ListenSince(gID codenames.GameID, pID codenames.PlayerID, epoch string, since uint64) (msgs <-chan []byte, stop func()) {
+	return h.listen(&connection{
+		id:       newID(gID),
+		h:        h,
+		gameID:   gID,
+		playerID: pID,
+		send:     make(chan []byte, 256),
+		replay:   true,
+		epoch:    epoch,
+		since:    since,
+	})
+}
+
+func (h *Hub) listen(conn *connection) (<-chan []byte, func()) {
+	h.register <- conn
+	var once sync.Once
+	return conn.send, func() {
+		once.Do(func() { h.unregister <- conn })
 	}
+}
+
+func (h *Hub) addConn(conn *connection) {
 	h.register <- conn
 	go conn.writePump()
 	go conn.readPump()
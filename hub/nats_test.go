@@ -0,0 +1,293 @@
+package hub
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/Codenames/codenames"
+	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestNATSBackend(t *testing.T) {
+	srv := runNATSServer(t, &server.Options{})
+	defer srv.Shutdown()
+
+	a, err := NewNATSBackend(srv.ClientURL(), "codenames", nats.ReconnectWait(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewNATSBackend: %v", err)
+	}
+	defer a.Close()
+	b, err := NewNATSBackend(srv.ClientURL(), "codenames", nats.ReconnectWait(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewNATSBackend: %v", err)
+	}
+	defer b.Close()
+
+	// Messages go to every subscriber, including whoever sent them.
+	if err := a.Publish([]byte("hello\r\nthere")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	for _, be := range []Backend{a, b} {
+		if got := recv(t, be); got != "hello\r\nthere" {
+			t.Errorf("got message %q, want %q", got, "hello\r\nthere")
+		}
+	}
+
+	// If the server goes away, we reconnect and resubscribe when it comes back.
+	port := srv.Addr().(*net.TCPAddr).Port
+	srv.Shutdown()
+	srv = runNATSServer(t, &server.Options{Port: port})
+	defer srv.Shutdown()
+	for _, be := range []Backend{a, b} {
+		nc := be.(*natsBackend).nc
+		deadline := time.Now().Add(5 * time.Second)
+		for !nc.IsConnected() {
+			if time.Now().After(deadline) {
+				t.Fatal("backend never reconnected")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := nc.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+	if err := b.Publish([]byte("back")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	for _, be := range []Backend{a, b} {
+		if got := recv(t, be); got != "back" {
+			t.Errorf("got message %q, want %q", got, "back")
+		}
+	}
+
+	// Once closed, the subscription ends.
+	a.Close()
+	select {
+	case _, ok := <-a.Subscribe():
+		if ok {
+			t.Error("got message after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("subscription wasn't closed")
+	}
+	if err := a.Publish([]byte("gone")); err == nil {
+		t.Error("Publish after Close succeeded")
+	}
+}
+
+func TestNATSBackendLimits(t *testing.T) {
+	srv := runNATSServer(t, &server.Options{Username: "hub", Password: "secret", MaxPayload: 1024})
+	defer srv.Shutdown()
+	addr := srv.Addr().String()
+
+	if be, err := NewNATSBackend("nats://hub:wrong@"+addr, "codenames"); err == nil {
+		be.Close()
+		t.Error("connected with the wrong password")
+	}
+
+	be, err := NewNATSBackend("nats://hub:secret@"+addr, "codenames")
+	if err != nil {
+		t.Fatalf("NewNATSBackend: %v", err)
+	}
+	defer be.Close()
+
+	// Messages bigger than the server allows are rejected up front, instead of
+	// getting us disconnected.
+	if err := be.Publish(make([]byte, 2048)); err == nil {
+		t.Error("Publish of a message over the max payload succeeded")
+	}
+	if err := be.Publish([]byte("small")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if got := recv(t, be); got != "small" {
+		t.Errorf("got message %q, want %q", got, "small")
+	}
+}
+
+func TestHubsSharingBackend(t *testing.T) {
+	srv := runNATSServer(t, &server.Options{})
+	defer srv.Shutdown()
+
+	var (
+		gID   = codenames.GameID("game")
+		alice = codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: "alice"}
+		bob   = codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: "bob"}
+	)
+
+	var backends []Backend
+	defer func() {
+		for _, be := range backends {
+			be.Close()
+		}
+	}()
+
+	newHub := func() (*Hub, *httptest.Server) {
+		be, err := NewNATSBackend(srv.ClientURL(), "codenames")
+		if err != nil {
+			t.Fatalf("NewNATSBackend: %v", err)
+		}
+		backends = append(backends, be)
+		h := New(WithBackend(be), WithPresence(func(gID codenames.GameID, pID codenames.PlayerID, online bool) interface{} {
+			return &testMsg{Action: "PRESENCE", Player: pID.ID, Online: online}
+		}))
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("Upgrade: %v", err)
+				return
+			}
+			pID := codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: r.URL.Query().Get("player")}
+			h.Register(ws, gID, pID, nil)
+		}))
+		return h, ts
+	}
+	hubA, tsA := newHub()
+	defer tsA.Close()
+	hubB, tsB := newHub()
+	defer tsB.Close()
+
+	aliceConn := dialHub(t, tsA, "alice")
+	defer aliceConn.Close()
+	if msg := readTestMsg(t, aliceConn); msg.Action != "CONNECTED" {
+		t.Fatalf("first message was %q, want CONNECTED", msg.Action)
+	}
+	if msg := readTestMsg(t, aliceConn); msg.Action != "PRESENCE" || msg.Player != "alice" || !msg.Online {
+		t.Fatalf("got %+v, want alice coming online", msg)
+	}
+	waitForHubOnline(t, hubB, gID, alice, true)
+
+	// Bob connects to the other hub, and Alice hears about it.
+	bobConn := dialHub(t, tsB, "bob")
+	if msg := readTestMsg(t, aliceConn); msg.Action != "PRESENCE" || msg.Player != "bob" || !msg.Online {
+		t.Fatalf("got %+v, want bob coming online", msg)
+	}
+	waitForHubOnline(t, hubA, gID, bob, true)
+
+	// Messages sent on either hub reach players on both.
+	if err := hubB.ToGame(gID, &testMsg{Action: "HELLO"}); err != nil {
+		t.Fatalf("ToGame: %v", err)
+	}
+	msg := readTestMsg(t, aliceConn)
+	if msg.Action != "HELLO" {
+		t.Fatalf("got %+v, want HELLO", msg)
+	}
+	if msg.Seq == 0 {
+		t.Error("HELLO had no sequence number")
+	}
+
+	if err := hubA.ToPlayer(gID, bob, &testMsg{Action: "JUST_BOB"}); err != nil {
+		t.Fatalf("ToPlayer: %v", err)
+	}
+	for {
+		msg := readTestMsg(t, bobConn)
+		if msg.Action == "JUST_BOB" {
+			break
+		}
+		// Bob also gets his greeting, and everything that went to the game.
+		if msg.Action == "CONNECTED" || msg.Action == "PRESENCE" || msg.Action == "HELLO" {
+			continue
+		}
+		t.Fatalf("got %+v, want JUST_BOB", msg)
+	}
+
+	// When Bob leaves, Alice hears about that too.
+	bobConn.Close()
+	if msg := readTestMsg(t, aliceConn); msg.Action != "PRESENCE" || msg.Player != "bob" || msg.Online {
+		t.Fatalf("got %+v, want bob going offline", msg)
+	}
+	waitForHubOnline(t, hubA, gID, bob, false)
+	if got := hubA.Online(gID); len(got) != 1 || got[0] != alice {
+		t.Errorf("Online = %v, want just alice", got)
+	}
+}
+
+type testMsg struct {
+	Action string `json:"action"`
+	Seq    uint64 `json:"seq,omitempty"`
+	Player string `json:"player,omitempty"`
+	Online bool   `json:"online,omitempty"`
+}
+
+func recv(t *testing.T, be Backend) string {
+	t.Helper()
+	select {
+	case msg := <-be.Subscribe():
+		return string(msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+		return ""
+	}
+}
+
+func dialHub(t *testing.T, ts *httptest.Server, player string) *websocket.Conn {
+	t.Helper()
+	addr := "ws" + strings.TrimPrefix(ts.URL, "http") + "/?player=" + player
+	conn, _, err := websocket.DefaultDialer.Dial(addr, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to hub: %v", err)
+	}
+	return conn
+}
+
+func readTestMsg(t *testing.T, conn *websocket.Conn) *testMsg {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, dat, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	var msg testMsg
+	if err := json.Unmarshal(dat, &msg); err != nil {
+		t.Fatalf("failed to decode message %q: %v", dat, err)
+	}
+	return &msg
+}
+
+func waitForHubOnline(t *testing.T, h *Hub, gID codenames.GameID, pID codenames.PlayerID, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := false
+		for _, id := range h.Online(gID) {
+			if id == pID {
+				got = true
+			}
+		}
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%q online = %t, want %t", pID.ID, got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// runNATSServer starts a NATS server on localhost for the test to talk to. It
+// listens on a random port, unless opts gives one.
+func runNATSServer(t *testing.T, opts *server.Options) *server.Server {
+	t.Helper()
+	opts.Host = "127.0.0.1"
+	if opts.Port == 0 {
+		opts.Port = server.RANDOM_PORT
+	}
+	opts.NoLog = true
+	opts.NoSigs = true
+
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatalf("failed to create NATS server: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server didn't start")
+	}
+	return srv
+}
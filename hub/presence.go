@@ -0,0 +1,148 @@
+package hub
+
+import (
+	"time"
+
+	"github.com/bcspragu/Codenames/codenames"
+)
+
+const (
+	// heartbeatPeriod is how often each hub tells the others everyone who is
+	// connected to it.
+	heartbeatPeriod = 10 * time.Second
+
+	// presenceTTL is how long a hub's connections are considered online after
+	// we last heard from it, so players on a hub that died go offline.
+	presenceTTL = 3 * heartbeatPeriod
+)
+
+type gamePlayer struct {
+	GameID   codenames.GameID   `json:"game_id"`
+	PlayerID codenames.PlayerID `json:"player_id"`
+}
+
+// presenceUpdate is what a hub sends the others when players connect to or
+// disconnect from it. If Snapshot is set, Online is everyone connected to the
+// hub, and anyone else is offline.
+type presenceUpdate struct {
+	Snapshot bool         `json:"snapshot,omitempty"`
+	Online   []gamePlayer `json:"online,omitempty"`
+	Offline  []gamePlayer `json:"offline,omitempty"`
+}
+
+// presence keeps track of who is connected to each hub sharing a backend. A
+// player is online if they're connected to any of them.
+type presence struct {
+	hubs map[string]*hubPresence
+	// games is who is online in each game, in the order they came online.
+	games map[codenames.GameID][]codenames.PlayerID
+}
+
+type hubPresence struct {
+	players  map[gamePlayer]bool
+	lastSeen time.Time
+}
+
+func newPresence() *presence {
+	return &presence{
+		hubs:  make(map[string]*hubPresence),
+		games: make(map[codenames.GameID][]codenames.PlayerID),
+	}
+}
+
+// announceFunc is called when a player comes online or goes offline across
+// all hubs.
+type announceFunc func(gID codenames.GameID, pID codenames.PlayerID, online bool)
+
+// update applies an update from the given hub, calling announce for players
+// that came online or went offline because of it.
+func (p *presence) update(hubID string, pu *presenceUpdate, now time.Time, announce announceFunc) {
+	hp, ok := p.hubs[hubID]
+	if !ok {
+		hp = &hubPresence{players: make(map[gamePlayer]bool)}
+		p.hubs[hubID] = hp
+	}
+	hp.lastSeen = now
+
+	if pu.Snapshot {
+		online := make(map[gamePlayer]bool)
+		for _, gp := range pu.Online {
+			online[gp] = true
+		}
+		for gp := range hp.players {
+			if !online[gp] {
+				pu.Offline = append(pu.Offline, gp)
+			}
+		}
+	}
+
+	for _, gp := range pu.Online {
+		if hp.players[gp] {
+			continue
+		}
+		hp.players[gp] = true
+		p.changed(gp, announce)
+	}
+	for _, gp := range pu.Offline {
+		if !hp.players[gp] {
+			continue
+		}
+		delete(hp.players, gp)
+		p.changed(gp, announce)
+	}
+}
+
+// expire forgets about hubs we haven't heard from in a while, except for the
+// one given, which is our own.
+func (p *presence) expire(self string, now time.Time, announce announceFunc) {
+	for hubID, hp := range p.hubs {
+		if hubID == self || now.Sub(hp.lastSeen) <= presenceTTL {
+			continue
+		}
+		delete(p.hubs, hubID)
+		for gp := range hp.players {
+			p.changed(gp, announce)
+		}
+	}
+}
+
+// changed brings the player's status in line with the hubs, after one of them
+// gained or lost the player.
+func (p *presence) changed(gp gamePlayer, announce announceFunc) {
+	online := false
+	for _, hp := range p.hubs {
+		if hp.players[gp] {
+			online = true
+			break
+		}
+	}
+
+	pIDs := p.games[gp.GameID]
+	idx := -1
+	for i, pID := range pIDs {
+		if pID == gp.PlayerID {
+			idx = i
+			break
+		}
+	}
+
+	switch {
+	case online && idx == -1:
+		p.games[gp.GameID] = append(pIDs, gp.PlayerID)
+		announce(gp.GameID, gp.PlayerID, true)
+	case !online && idx != -1:
+		pIDs = append(pIDs[:idx], pIDs[idx+1:]...)
+		if len(pIDs) == 0 {
+			delete(p.games, gp.GameID)
+		} else {
+			p.games[gp.GameID] = pIDs
+		}
+		announce(gp.GameID, gp.PlayerID, false)
+	}
+}
+
+// online returns the players connected to a game on any hub, in the order they
+// came online.
+func (p *presence) online(gID codenames.GameID) []codenames.PlayerID {
+	return append([]codenames.PlayerID(nil), p.games[gID]...)
+}
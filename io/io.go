@@ -16,6 +16,9 @@ type Spymaster struct {
 	In io.Reader
 	// out is where the prompts should be written out to.
 	Out io.Writer
+	// Spec is the layout of the board, used to print it out. If nil, it's
+	// assumed to be a codenames.StandardBoard.
+	Spec *codenames.BoardSpec
 }
 
 func agentStr(a codenames.Agent) string {
@@ -24,6 +27,8 @@ func agentStr(a codenames.Agent) string {
 		return "Blue"
 	case codenames.RedAgent:
 		return "Red"
+	case codenames.GreenAgent:
+		return "Green"
 	default:
 		return "Unknown"
 	}
@@ -42,17 +47,24 @@ func (s *Spymaster) GiveClue(b *codenames.Board, agent codenames.Agent) (*codena
 func (s *Spymaster) printBoard(b *codenames.Board) {
 	table := tablewriter.NewWriter(s.Out)
 
-	for i := 0; i < 5; i++ {
+	spec := s.Spec
+	if spec == nil {
+		spec = codenames.StandardBoard
+	}
+
+	for i := 0; i < spec.Rows; i++ {
 		var row []string
 		var colors []tablewriter.Colors
-		for j := 0; j < 5; j++ {
-			card := b.Cards[i*5+j]
+		for j := 0; j < spec.Columns; j++ {
+			card := b.Cards[i*spec.Columns+j]
 			var c tablewriter.Colors
 			switch card.Agent {
 			case codenames.BlueAgent:
 				c = append(c, tablewriter.FgBlueColor)
 			case codenames.RedAgent:
 				c = append(c, tablewriter.FgHiRedColor)
+			case codenames.GreenAgent:
+				c = append(c, tablewriter.FgGreenColor)
 			case codenames.Assassin:
 				c = append(c, tablewriter.BgHiRedColor)
 			}
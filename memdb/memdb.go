@@ -2,6 +2,10 @@ package memdb
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/bcspragu/Codenames/codenames"
 )
@@ -14,12 +18,40 @@ const (
 	robotID = idNamespace("robot")
 )
 
+// DB is an in-memory codenames.DB, which is safe for concurrent use.
 type DB struct {
+	// mu guards everything below.
+	mu sync.RWMutex
+
 	ids         map[idNamespace]int
 	games       map[codenames.GameID]*codenames.Game
 	users       map[codenames.UserID]*codenames.User
 	robots      map[codenames.RobotID]*codenames.Robot
 	playerRoles map[codenames.GameID][]*codenames.PlayerRole
+	history     map[codenames.GameID][]*codenames.Event
+	chat        map[codenames.GameID][]*codenames.ChatMessage
+	ratings     map[ratingKey]*codenames.Rating
+	turnClaims  map[codenames.GameID]*turnClaim
+
+	// created holds every game in the order they were created, for listing
+	// them in the lobby.
+	created []*createdGame
+}
+
+type createdGame struct {
+	id codenames.GameID
+	at time.Time
+}
+
+type turnClaim struct {
+	owner    string
+	deadline time.Time
+	lapsesAt time.Time
+}
+
+type ratingKey struct {
+	pID  codenames.PlayerID
+	role codenames.Role
 }
 
 func New() *DB {
@@ -29,10 +61,17 @@ func New() *DB {
 		users:       make(map[codenames.UserID]*codenames.User),
 		robots:      make(map[codenames.RobotID]*codenames.Robot),
 		playerRoles: make(map[codenames.GameID][]*codenames.PlayerRole),
+		history:     make(map[codenames.GameID][]*codenames.Event),
+		chat:        make(map[codenames.GameID][]*codenames.ChatMessage),
+		ratings:     make(map[ratingKey]*codenames.Rating),
+		turnClaims:  make(map[codenames.GameID]*turnClaim),
 	}
 }
 
 func (db *DB) NewGame(g *codenames.Game) (codenames.GameID, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	gID := codenames.GameID(db.newID(gameID))
 
 	gc := g.Clone()
@@ -40,11 +79,15 @@ func (db *DB) NewGame(g *codenames.Game) (codenames.GameID, error) {
 	gc.Status = codenames.Pending
 	db.games[gID] = gc
 	db.playerRoles[gID] = []*codenames.PlayerRole{}
+	db.created = append(db.created, &createdGame{id: gID, at: time.Now()})
 
 	return gID, nil
 }
 
 func (db *DB) Game(gID codenames.GameID) (*codenames.Game, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	g, ok := db.games[gID]
 	if !ok {
 		return nil, codenames.ErrGameNotFound
@@ -54,6 +97,9 @@ func (db *DB) Game(gID codenames.GameID) (*codenames.Game, error) {
 }
 
 func (db *DB) NewUser(name string) (codenames.UserID, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	uID := codenames.UserID(db.newID(userID))
 
 	u := &codenames.User{ID: uID, Name: name}
@@ -64,6 +110,9 @@ func (db *DB) NewUser(name string) (codenames.UserID, error) {
 }
 
 func (db *DB) User(uID codenames.UserID) (*codenames.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	u, ok := db.users[uID]
 	if !ok {
 		return nil, codenames.ErrUserNotFound
@@ -73,6 +122,9 @@ func (db *DB) User(uID codenames.UserID) (*codenames.User, error) {
 }
 
 func (db *DB) NewRobot(name string) (codenames.RobotID, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	rID := codenames.RobotID(db.newID(robotID))
 
 	r := &codenames.Robot{ID: rID, Name: name}
@@ -83,6 +135,9 @@ func (db *DB) NewRobot(name string) (codenames.RobotID, error) {
 }
 
 func (db *DB) Robot(rID codenames.RobotID) (*codenames.Robot, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	r, ok := db.robots[rID]
 	if !ok {
 		return nil, codenames.ErrRobotNotFound
@@ -92,6 +147,9 @@ func (db *DB) Robot(rID codenames.RobotID) (*codenames.Robot, error) {
 }
 
 func (db *DB) PendingGames() ([]codenames.GameID, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	var pending []codenames.GameID
 	for _, g := range db.games {
 		if g.Status == codenames.Pending {
@@ -101,7 +159,71 @@ func (db *DB) PendingGames() ([]codenames.GameID, error) {
 	return pending, nil
 }
 
+func (db *DB) PlayingGames() ([]codenames.GameID, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var playing []codenames.GameID
+	for _, g := range db.games {
+		if g.Status == codenames.Playing {
+			playing = append(playing, g.ID)
+		}
+	}
+	return playing, nil
+}
+
+func (db *DB) ListGames(filter *codenames.GameFilter, cursor string, limit int) ([]*codenames.ListedGame, string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// The cursor is the index in db.created to pick up before.
+	before := len(db.created)
+	if cursor != "" {
+		c, err := strconv.Atoi(cursor)
+		if err != nil || c < 0 || c > len(db.created) {
+			return nil, "", fmt.Errorf("malformed cursor %q: %w", cursor, codenames.ErrInvalidCursor)
+		}
+		before = c
+	}
+
+	var (
+		out  []*codenames.ListedGame
+		last int
+	)
+	for i := before - 1; i >= 0; i-- {
+		cg := db.created[i]
+		g := db.games[cg.id]
+		if g.Status != codenames.Pending || g.State.Unlisted {
+			continue
+		}
+		if filter != nil && !filter.CreatedAfter.IsZero() && !cg.at.After(filter.CreatedAfter) {
+			// Games are in the order they were created, so the rest are too old.
+			break
+		}
+
+		lg := &codenames.ListedGame{
+			Game:      g.Clone(),
+			CreatedAt: cg.at,
+			Players:   clonePRs(db.playerRoles[cg.id]),
+		}
+		if !filter.Matches(lg) {
+			continue
+		}
+		if limit > 0 && len(out) == limit {
+			// There's at least one more game, so there's another page.
+			return out, strconv.Itoa(last), nil
+		}
+		out = append(out, lg)
+		last = i
+	}
+
+	return out, "", nil
+}
+
 func (db *DB) PlayersInGame(gID codenames.GameID) ([]*codenames.PlayerRole, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	prs, ok := db.playerRoles[gID]
 	if !ok {
 		return nil, codenames.ErrGameNotFound
@@ -111,6 +233,9 @@ func (db *DB) PlayersInGame(gID codenames.GameID) ([]*codenames.PlayerRole, erro
 }
 
 func (db *DB) Player(pID codenames.PlayerID) (string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	if pID.PlayerType != codenames.PlayerTypeHuman {
 		return "", fmt.Errorf("player type %q not supported for memdb, only humans for now", pID.PlayerType)
 	}
@@ -133,6 +258,9 @@ func clonePRs(prs []*codenames.PlayerRole) []*codenames.PlayerRole {
 }
 
 func (db *DB) JoinGame(gID codenames.GameID, pID codenames.PlayerID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	prs, ok := db.playerRoles[gID]
 	if !ok {
 		return codenames.ErrGameNotFound
@@ -155,7 +283,29 @@ func (db *DB) JoinGame(gID codenames.GameID, pID codenames.PlayerID) error {
 	return nil
 }
 
+func (db *DB) LeaveGame(gID codenames.GameID, pID codenames.PlayerID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	prs, ok := db.playerRoles[gID]
+	if !ok {
+		return codenames.ErrGameNotFound
+	}
+
+	for i, pr := range prs {
+		if pr.PlayerID == pID {
+			db.playerRoles[gID] = append(prs[:i:i], prs[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("player %+v was not found in game %q", pID, gID)
+}
+
 func (db *DB) AssignRole(gID codenames.GameID, req *codenames.PlayerRole) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	prs, ok := db.playerRoles[gID]
 	if !ok {
 		return codenames.ErrGameNotFound
@@ -174,35 +324,278 @@ func (db *DB) AssignRole(gID codenames.GameID, req *codenames.PlayerRole) error
 }
 
 func (db *DB) BatchPlayerNames(pIDs []codenames.PlayerID) (map[codenames.PlayerID]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	out := make(map[codenames.PlayerID]string)
 	for _, pID := range pIDs {
-		if pID.PlayerType != codenames.PlayerTypeHuman {
-			return nil, fmt.Errorf("player type %q not supported for memdb, only humans for now", pID.PlayerType)
+		switch pID.PlayerType {
+		case codenames.PlayerTypeHuman:
+			u, ok := db.users[codenames.UserID(pID.ID)]
+			if !ok {
+				return nil, fmt.Errorf("player %q was not found: %w", pID.ID, codenames.ErrUserNotFound)
+			}
+			out[pID] = u.Name
+		case codenames.PlayerTypeRobot:
+			r, ok := db.robots[codenames.RobotID(pID.ID)]
+			if !ok {
+				return nil, fmt.Errorf("player %q was not found: %w", pID.ID, codenames.ErrRobotNotFound)
+			}
+			out[pID] = r.Name
+		default:
+			return nil, fmt.Errorf("unknown player type %q", pID.PlayerType)
 		}
+	}
+
+	return out, nil
+}
 
-		u, ok := db.users[codenames.UserID(pID.ID)]
-		if !ok {
-			return nil, fmt.Errorf("player %q was not found: %w", pID.ID, codenames.ErrUserNotFound)
+func (db *DB) PlayerRecords(pIDs []codenames.PlayerID) (map[codenames.PlayerID]*codenames.PlayerRecord, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	out := make(map[codenames.PlayerID]*codenames.PlayerRecord)
+	for _, pID := range pIDs {
+		out[pID] = &codenames.PlayerRecord{}
+	}
+
+	for gID, g := range db.games {
+		if g.Status != codenames.Finished {
+			continue
+		}
+		for _, pr := range db.playerRoles[gID] {
+			rec, ok := out[pr.PlayerID]
+			if !ok || !pr.RoleAssigned || pr.Role.IsSpectator() {
+				continue
+			}
+			var finishedAt time.Time
+			if g.FinishedAt != nil {
+				finishedAt = *g.FinishedAt
+			}
+			var stats *codenames.TeamStats
+			if g.Outcome != nil {
+				stats = g.Outcome.Teams[pr.Team]
+			}
+			rec.Add(pr.Role, pr.Team == g.Winner, finishedAt, stats)
+		}
+	}
+
+	return out, nil
+}
+
+func (db *DB) PlayerGames(pID codenames.PlayerID, limit, offset int) ([]*codenames.PlayerGame, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var out []*codenames.PlayerGame
+	for gID, prs := range db.playerRoles {
+		for _, pr := range prs {
+			if pr.PlayerID == pID {
+				out = append(out, &codenames.PlayerGame{
+					Game: db.games[gID].Clone(),
+					Role: pr.Clone(),
+				})
+			}
 		}
+	}
 
-		out[pID] = u.Name
+	statusOrder := map[codenames.GameStatus]int{
+		codenames.Playing:  0,
+		codenames.Pending:  1,
+		codenames.Finished: 2,
 	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].Game, out[j].Game
+		if statusOrder[a.Status] != statusOrder[b.Status] {
+			return statusOrder[a.Status] < statusOrder[b.Status]
+		}
+		if a.FinishedAt != nil && b.FinishedAt != nil && !a.FinishedAt.Equal(*b.FinishedAt) {
+			return a.FinishedAt.After(*b.FinishedAt)
+		}
+		return a.ID < b.ID
+	})
 
+	if offset >= len(out) {
+		return nil, nil
+	}
+	out = out[offset:]
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (db *DB) Ratings(pIDs []codenames.PlayerID) ([]*codenames.Rating, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var out []*codenames.Rating
+	for _, pID := range pIDs {
+		for _, role := range []codenames.Role{codenames.OperativeRole, codenames.SpymasterRole} {
+			if r, ok := db.ratings[ratingKey{pID, role}]; ok {
+				out = append(out, r.Clone())
+			}
+		}
+	}
+	return out, nil
+}
+
+func (db *DB) UpdateRatings(rs []*codenames.Rating) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, r := range rs {
+		db.ratings[ratingKey{r.PlayerID, r.Role}] = r.Clone()
+	}
+	return nil
+}
+
+func (db *DB) Leaderboard(role codenames.Role, limit int) ([]*codenames.Rating, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var out []*codenames.Rating
+	for _, r := range db.ratings {
+		if r.Role == role {
+			out = append(out, r.Clone())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rating != out[j].Rating {
+			return out[i].Rating > out[j].Rating
+		}
+		if out[i].Games != out[j].Games {
+			return out[i].Games > out[j].Games
+		}
+		return out[i].PlayerID.String() < out[j].PlayerID.String()
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
 	return out, nil
 }
 
 func (db *DB) StartGame(gID codenames.GameID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	return db.updateGame(gID, func(g *codenames.Game) {
 		g.Status = codenames.Playing
 	})
 }
 
+func (db *DB) SetGameCreator(gID codenames.GameID, uID codenames.UserID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.updateGame(gID, func(g *codenames.Game) {
+		g.CreatedBy = uID
+	})
+}
+
+func (db *DB) SetRematch(gID, rematchID codenames.GameID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.updateGame(gID, func(g *codenames.Game) {
+		g.RematchID = rematchID
+	})
+}
+
 func (db *DB) UpdateState(gID codenames.GameID, gs *codenames.GameState) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	return db.updateGame(gID, func(g *codenames.Game) {
 		g.State = gs.Clone()
 	})
 }
 
+func (db *DB) ClaimTurnTimer(gID codenames.GameID, owner string, deadline, lapsesAt time.Time) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.games[gID]; !ok {
+		return false, codenames.ErrGameNotFound
+	}
+
+	c, ok := db.turnClaims[gID]
+	if ok && c.owner != owner && c.deadline.Equal(deadline) && time.Now().Before(c.lapsesAt) {
+		return false, nil
+	}
+	db.turnClaims[gID] = &turnClaim{owner: owner, deadline: deadline, lapsesAt: lapsesAt}
+	return true, nil
+}
+
+func (db *DB) FinishGame(gID codenames.GameID, winner codenames.Team, outcome *codenames.Outcome) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.updateGame(gID, func(g *codenames.Game) {
+		now := time.Now()
+		g.Status = codenames.Finished
+		g.Winner = winner
+		g.FinishedAt = &now
+		g.Outcome = outcome.Clone()
+	})
+}
+
+func (db *DB) RecordEvent(gID codenames.GameID, ev *codenames.Event) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.games[gID]; !ok {
+		return codenames.ErrGameNotFound
+	}
+
+	db.history[gID] = append(db.history[gID], ev.Clone())
+	return nil
+}
+
+func (db *DB) GameHistory(gID codenames.GameID) ([]*codenames.Event, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if _, ok := db.games[gID]; !ok {
+		return nil, codenames.ErrGameNotFound
+	}
+
+	evs := db.history[gID]
+	out := make([]*codenames.Event, len(evs))
+	for i, ev := range evs {
+		out[i] = ev.Clone()
+	}
+	return out, nil
+}
+
+func (db *DB) AddChatMessage(gID codenames.GameID, cm *codenames.ChatMessage) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.games[gID]; !ok {
+		return codenames.ErrGameNotFound
+	}
+
+	db.chat[gID] = append(db.chat[gID], cm.Clone())
+	return nil
+}
+
+func (db *DB) ChatMessages(gID codenames.GameID) ([]*codenames.ChatMessage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if _, ok := db.games[gID]; !ok {
+		return nil, codenames.ErrGameNotFound
+	}
+
+	cms := db.chat[gID]
+	out := make([]*codenames.ChatMessage, len(cms))
+	for i, cm := range cms {
+		out[i] = cm.Clone()
+	}
+	return out, nil
+}
+
 func (db *DB) updateGame(gID codenames.GameID, update func(*codenames.Game)) error {
 	g, ok := db.games[gID]
 	if !ok {
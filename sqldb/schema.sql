@@ -1,3 +1,7 @@
+-- Keep this in sync with schemaVersion in sqldb.go, and add a migration there
+-- when changing the schema.
+PRAGMA user_version = 2;
+
 CREATE TABLE Users (
     id TEXT NOT NULL,  -- Based on the user's cookie
     display_name TEXT NOT NULL,  -- Arbitary, user specified
@@ -9,7 +13,15 @@ CREATE TABLE Games (
     status TEXT NOT NULL,  -- Enum: PENDING, PLAYING, FINISHED
     state BLOB NOT NULL,
     creator_id TEXT NOT NULL,
+    winner TEXT,  -- Enum: RED, BLUE, only set once the game is FINISHED
+    finished_at DATETIME,
+    outcome BLOB,  -- A gob-encoded codenames.Outcome
+    rematch_of TEXT,  -- The game this one is a rematch of, if any
+    rematch_id TEXT,  -- The rematch of this game, once someone asks for one
+    created_at DATETIME,
     FOREIGN KEY (creator_id) REFERENCES Users(id),
+    FOREIGN KEY (rematch_of) REFERENCES Games(id),
+    FOREIGN KEY (rematch_id) REFERENCES Games(id),
     PRIMARY KEY (id)
 );
 
@@ -41,9 +53,40 @@ CREATE TABLE AIs (
 );
 
 CREATE TABLE GameHistory (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,  -- Orders events, many can share a timestamp
     game_id TEXT NOT NULL,
     event_timestamp DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
-    event BLOB NOT NULL,
+    event BLOB NOT NULL,  -- A gob-encoded codenames.Event
+    FOREIGN KEY (game_id) REFERENCES Games(id)
+);
+
+CREATE TABLE ChatMessages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,  -- Orders messages, many can share a timestamp
+    game_id TEXT NOT NULL,
+    player_type TEXT NOT NULL,  -- Enum: HUMAN, ROBOT
+    player_id TEXT NOT NULL,  -- A user or AI ID, depending on player_type
+    scope TEXT NOT NULL,  -- Enum: ALL, TEAM
+    team TEXT NOT NULL,  -- The team a TEAM message went to, empty for ALL
+    message TEXT NOT NULL,
+    sent_at DATETIME NOT NULL,
+    FOREIGN KEY (game_id) REFERENCES Games(id)
+);
+
+CREATE TABLE Ratings (
+    player_type TEXT NOT NULL,  -- Enum: HUMAN, ROBOT
+    player_id TEXT NOT NULL,  -- A user or AI ID, depending on player_type
+    role TEXT NOT NULL,  -- Enum: SPYMASTER, OPERATIVE
+    rating REAL NOT NULL,
+    games INTEGER NOT NULL,
+    wins INTEGER NOT NULL,
+    PRIMARY KEY (player_type, player_id, role)
+);
+
+CREATE TABLE TurnTimers (
+    game_id TEXT NOT NULL,
+    owner TEXT NOT NULL,  -- The server that expires the turn
+    deadline INTEGER NOT NULL,  -- When the turn ends, in Unix nanoseconds
+    lapses_at INTEGER NOT NULL,  -- When other servers can take over, in Unix nanoseconds
     FOREIGN KEY (game_id) REFERENCES Games(id),
-    PRIMARY KEY (game_id, event_timestamp)
+    PRIMARY KEY (game_id)
 );
@@ -6,22 +6,37 @@ import (
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bcspragu/Codenames/codenames"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// gameColumns are the columns needed to load a game, see scanGame.
+const gameColumns = `Games.id, Games.status, Games.creator_id, Games.state, Games.winner, Games.finished_at, Games.outcome, Games.rematch_of, Games.rematch_id`
+
 var (
 	// Game statements
-	createGameStmt      = `INSERT INTO Games (id, status, creator_id, state) VALUES (?, ?, ?, ?)`
+	createGameStmt      = `INSERT INTO Games (id, status, creator_id, state, rematch_of, created_at) VALUES (?, ?, ?, ?, ?, ?)`
 	gameExistsStmt      = `SELECT EXISTS(SELECT 1 FROM Games WHERE id = ?)`
-	getGameStmt         = `SELECT id, status, creator_id, state FROM Games WHERE id = ?`
+	getGameStmt         = `SELECT ` + gameColumns + ` FROM Games WHERE id = ?`
 	getPendingGamesStmt = `SELECT id FROM Games WHERE status = 'PENDING' ORDER BY id`
-	startGameStmt       = `
+	getPlayingGamesStmt = `SELECT id FROM Games WHERE status = 'PLAYING' ORDER BY id`
+	// Games are listed newest first, and the rowid doubles as the cursor, since
+	// it goes up as games are created.
+	listGamesStmt = `
+SELECT ` + gameColumns + `, Games.created_at, Games.rowid
+FROM Games
+WHERE status = 'PENDING'
+	AND rowid < ?
+ORDER BY rowid DESC`
+	startGameStmt = `
 UPDATE Games
 SET status = 'PLAYING'
 WHERE id = ?`
@@ -29,6 +44,28 @@ WHERE id = ?`
 UPDATE Games
 SET state = ?
 WHERE id = ?`
+	setGameCreatorStmt = `
+UPDATE Games
+SET creator_id = ?
+WHERE id = ?`
+	setRematchStmt = `
+UPDATE Games
+SET rematch_id = ?
+WHERE id = ?`
+	finishGameStmt = `
+UPDATE Games
+SET status = 'FINISHED', winner = ?, finished_at = ?, outcome = ?
+WHERE id = ?`
+
+	// Turn timer statements. A claim only goes through if nobody else has an
+	// unlapsed claim on the same turn.
+	claimTurnTimerStmt = `
+INSERT INTO TurnTimers (game_id, owner, deadline, lapses_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (game_id) DO UPDATE
+SET owner = excluded.owner, deadline = excluded.deadline, lapses_at = excluded.lapses_at
+WHERE TurnTimers.owner = excluded.owner
+	OR TurnTimers.deadline != excluded.deadline
+	OR TurnTimers.lapses_at <= ?`
 
 	// User statements
 	createUserStmt = `INSERT INTO Users (id, display_name) VALUES (?, ?)`
@@ -49,6 +86,11 @@ INSERT INTO GamePlayers
 (game_id, player_id, role_assigned) VALUES
 (?, ?, 0)`
 
+	leaveGameStmt = `
+DELETE FROM GamePlayers
+WHERE game_id = ?
+	AND player_id = ?`
+
 	assignRoleStmt = `
 UPDATE GamePlayers
 SET role_assigned = 1,
@@ -63,8 +105,84 @@ JOIN Players
 	ON GamePlayers.player_id = Players.id
 WHERE GamePlayers.game_id = ?`
 
-	// Game history statements (currently unused)
-	updateGameHistoryStmt = `INSERT INTO GameHistory (game_id, event) VALUES (?, ?)`
+	// Player record statements
+	getUserRecordStmt = `
+SELECT GamePlayers.team, GamePlayers.role, Games.winner, Games.finished_at, Games.outcome
+FROM GamePlayers
+JOIN Players
+	ON GamePlayers.player_id = Players.id
+JOIN Games
+	ON GamePlayers.game_id = Games.id
+WHERE Games.status = 'FINISHED'
+	AND GamePlayers.role_assigned = 1
+	AND Players.user_id = ?`
+	getAIRecordStmt = `
+SELECT GamePlayers.team, GamePlayers.role, Games.winner, Games.finished_at, Games.outcome
+FROM GamePlayers
+JOIN Players
+	ON GamePlayers.player_id = Players.id
+JOIN Games
+	ON GamePlayers.game_id = Games.id
+WHERE Games.status = 'FINISHED'
+	AND GamePlayers.role_assigned = 1
+	AND Players.ai_id = ?`
+
+	// Player game statements, in progress games first, then pending ones, then
+	// finished ones, most recent first.
+	getUserGamesStmt = `
+SELECT ` + gameColumns + `, GamePlayers.role_assigned, GamePlayers.role, GamePlayers.team
+FROM GamePlayers
+JOIN Players
+	ON GamePlayers.player_id = Players.id
+JOIN Games
+	ON GamePlayers.game_id = Games.id
+WHERE Players.user_id = ?
+ORDER BY CASE Games.status WHEN 'PLAYING' THEN 0 WHEN 'PENDING' THEN 1 ELSE 2 END,
+	Games.finished_at DESC,
+	Games.id
+LIMIT ? OFFSET ?`
+	getAIGamesStmt = `
+SELECT ` + gameColumns + `, GamePlayers.role_assigned, GamePlayers.role, GamePlayers.team
+FROM GamePlayers
+JOIN Players
+	ON GamePlayers.player_id = Players.id
+JOIN Games
+	ON GamePlayers.game_id = Games.id
+WHERE Players.ai_id = ?
+ORDER BY CASE Games.status WHEN 'PLAYING' THEN 0 WHEN 'PENDING' THEN 1 ELSE 2 END,
+	Games.finished_at DESC,
+	Games.id
+LIMIT ? OFFSET ?`
+
+	// Rating statements
+	getRatingsStmt = `
+SELECT player_type, player_id, role, rating, games, wins
+FROM Ratings
+WHERE player_type = ? AND player_id = ?
+ORDER BY role`
+	updateRatingStmt = `
+INSERT OR REPLACE INTO Ratings (player_type, player_id, role, rating, games, wins)
+VALUES (?, ?, ?, ?, ?, ?)`
+	getLeaderboardStmt = `
+SELECT player_type, player_id, role, rating, games, wins
+FROM Ratings
+WHERE role = ?
+ORDER BY rating DESC, games DESC
+LIMIT ?`
+
+	// Game history statements
+	updateGameHistoryStmt = `INSERT INTO GameHistory (game_id, event_timestamp, event) VALUES (?, ?, ?)`
+	getGameHistoryStmt    = `SELECT event FROM GameHistory WHERE game_id = ? ORDER BY id`
+
+	// Chat statements
+	addChatMessageStmt = `
+INSERT INTO ChatMessages (game_id, player_type, player_id, scope, team, message, sent_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+	getChatMessagesStmt = `
+SELECT player_type, player_id, scope, team, message, sent_at
+FROM ChatMessages
+WHERE game_id = ?
+ORDER BY id`
 )
 
 // DB implements the Codenames database API, backed by a SQLite database.
@@ -88,6 +206,11 @@ func New(fn string, r *rand.Rand) (*DB, error) {
 		return nil, err
 	}
 
+	if err := migrate(sdb); err != nil {
+		sdb.Close()
+		return nil, fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
 	db := &DB{
 		dbChan:   make(chan func(*sql.DB)),
 		doneChan: make(chan struct{}),
@@ -100,6 +223,111 @@ func New(fn string, r *rand.Rand) (*DB, error) {
 	return db, nil
 }
 
+// schemaVersion is the version of schema.sql, which is stored in the DB's
+// user_version. DBs at an older version are brought up to date by migrations
+// when they're opened.
+const schemaVersion = 2
+
+// migrations[i] upgrades a DB from version i to version i+1.
+var migrations = []string{
+	// Version 0 is the original schema, from before games had results,
+	// rematches, chat, or ratings. Its GameHistory table was never written to,
+	// so it's replaced outright.
+	`
+ALTER TABLE Games ADD COLUMN winner TEXT;
+ALTER TABLE Games ADD COLUMN finished_at DATETIME;
+ALTER TABLE Games ADD COLUMN outcome BLOB;
+ALTER TABLE Games ADD COLUMN rematch_of TEXT REFERENCES Games(id);
+ALTER TABLE Games ADD COLUMN rematch_id TEXT REFERENCES Games(id);
+ALTER TABLE Games ADD COLUMN created_at DATETIME;
+
+DROP TABLE GameHistory;
+CREATE TABLE GameHistory (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    game_id TEXT NOT NULL,
+    event_timestamp DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    event BLOB NOT NULL,
+    FOREIGN KEY (game_id) REFERENCES Games(id)
+);
+
+CREATE TABLE ChatMessages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    game_id TEXT NOT NULL,
+    player_type TEXT NOT NULL,
+    player_id TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    team TEXT NOT NULL,
+    message TEXT NOT NULL,
+    sent_at DATETIME NOT NULL,
+    FOREIGN KEY (game_id) REFERENCES Games(id)
+);
+
+CREATE TABLE Ratings (
+    player_type TEXT NOT NULL,
+    player_id TEXT NOT NULL,
+    role TEXT NOT NULL,
+    rating REAL NOT NULL,
+    games INTEGER NOT NULL,
+    wins INTEGER NOT NULL,
+    PRIMARY KEY (player_type, player_id, role)
+);`,
+	// Version 1 didn't keep track of which server runs each game's turn timer.
+	`
+CREATE TABLE TurnTimers (
+    game_id TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    deadline INTEGER NOT NULL,
+    lapses_at INTEGER NOT NULL,
+    FOREIGN KEY (game_id) REFERENCES Games(id),
+    PRIMARY KEY (game_id)
+);`,
+}
+
+// migrate brings the DB up to schemaVersion.
+func migrate(sdb *sql.DB) error {
+	var version int
+	if err := sdb.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to load schema version: %w", err)
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("DB has schema version %d, but we only know up to %d", version, schemaVersion)
+	}
+
+	if version == 0 {
+		// DBs created from schema.sql before it set the version already have the
+		// version 1 tables, which we can tell by the new GameHistory layout.
+		var current bool
+		if err := sdb.QueryRow(`SELECT EXISTS(SELECT 1 FROM pragma_table_info('GameHistory') WHERE name = 'id')`).Scan(&current); err != nil {
+			return fmt.Errorf("failed to inspect GameHistory table: %w", err)
+		}
+		if current {
+			version = 1
+			if _, err := sdb.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version)); err != nil {
+				return fmt.Errorf("failed to set schema version: %w", err)
+			}
+		}
+	}
+
+	for ; version < schemaVersion; version++ {
+		tx, err := sdb.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to start migration to version %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate to version %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to set schema version to %d: %w", version+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration to version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
 // run handles all database calls, and ensures that only one thing is happening
 // against the database at a time.
 func (s *DB) run(sdb *sql.DB) {
@@ -145,7 +373,12 @@ func (s *DB) NewGame(g *codenames.Game) (codenames.GameID, error) {
 			return
 		}
 
-		_, err = tx.Exec(createGameStmt, string(id), codenames.Pending, string(g.CreatedBy), gsb)
+		var rematchOf sql.NullString
+		if g.RematchOf != "" {
+			rematchOf = sql.NullString{String: string(g.RematchOf), Valid: true}
+		}
+
+		_, err = tx.Exec(createGameStmt, string(id), codenames.Pending, string(g.CreatedBy), gsb, rematchOf, time.Now())
 		if err != nil {
 			resChan <- &result{err: err}
 			return
@@ -180,11 +413,8 @@ func (s *DB) Game(gID codenames.GameID) (*codenames.Game, error) {
 		}
 		defer tx.Rollback()
 
-		var (
-			g   codenames.Game
-			gsb []byte
-		)
-		if err := tx.QueryRow(getGameStmt, string(gID)).Scan(&g.ID, &g.Status, &g.CreatedBy, &gsb); err != nil {
+		g, err := scanGame(tx.QueryRow(getGameStmt, string(gID)))
+		if err != nil {
 			resChan <- &result{err: err}
 			return
 		}
@@ -193,12 +423,7 @@ func (s *DB) Game(gID codenames.GameID) (*codenames.Game, error) {
 			resChan <- &result{err: err}
 			return
 		}
-
-		if g.State, err = gameStateFromBytes(gsb); err != nil {
-			resChan <- &result{err: err}
-			return
-		}
-		resChan <- &result{game: &g}
+		resChan <- &result{game: g}
 	}
 
 	res := <-resChan
@@ -208,6 +433,45 @@ func (s *DB) Game(gID codenames.GameID) (*codenames.Game, error) {
 	return res.game, nil
 }
 
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGame loads a game from a row that starts with gameColumns. Any extra
+// columns after those are scanned into extra.
+func scanGame(row scanner, extra ...interface{}) (*codenames.Game, error) {
+	var (
+		g          codenames.Game
+		gsb, ob    []byte
+		winner     sql.NullString
+		finishedAt sql.NullTime
+		rematchOf  sql.NullString
+		rematchID  sql.NullString
+	)
+	dest := []interface{}{&g.ID, &g.Status, &g.CreatedBy, &gsb, &winner, &finishedAt, &ob, &rematchOf, &rematchID}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if g.State, err = gameStateFromBytes(gsb); err != nil {
+		return nil, err
+	}
+
+	g.Winner = codenames.Team(winner.String)
+	g.RematchOf = codenames.GameID(rematchOf.String)
+	g.RematchID = codenames.GameID(rematchID.String)
+	if finishedAt.Valid {
+		g.FinishedAt = &finishedAt.Time
+	}
+	if ob != nil {
+		if g.Outcome, err = outcomeFromBytes(ob); err != nil {
+			return nil, err
+		}
+	}
+	return &g, nil
+}
+
 func (s *DB) NewUser(name string) (codenames.UserID, error) {
 	type result struct {
 		id  codenames.UserID
@@ -314,7 +578,96 @@ func (s *DB) Robot(id codenames.RobotID) (*codenames.Robot, error) {
 	return res.robot, nil
 }
 
+func (s *DB) ListGames(filter *codenames.GameFilter, cursor string, limit int) ([]*codenames.ListedGame, string, error) {
+	before := int64(math.MaxInt64)
+	if cursor != "" {
+		c, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("malformed cursor %q: %v: %w", cursor, err, codenames.ErrInvalidCursor)
+		}
+		before = c
+	}
+
+	type result struct {
+		games []*codenames.ListedGame
+		next  string
+		err   error
+	}
+
+	resChan := make(chan *result)
+	s.dbChan <- func(sdb *sql.DB) {
+		games, next, err := listGames(sdb, filter, before, limit)
+		resChan <- &result{games: games, next: next, err: err}
+	}
+
+	res := <-resChan
+	if res.err != nil {
+		return nil, "", res.err
+	}
+	return res.games, res.next, nil
+}
+
+func listGames(sdb *sql.DB, filter *codenames.GameFilter, before int64, limit int) ([]*codenames.ListedGame, string, error) {
+	rows, err := sdb.Query(listGamesStmt, before)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for games: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		out    []*codenames.ListedGame
+		lastID int64
+	)
+	for rows.Next() {
+		var (
+			createdAt sql.NullTime
+			rowID     int64
+		)
+		g, err := scanGame(rows, &createdAt, &rowID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan game: %w", err)
+		}
+		if g.State.Unlisted {
+			continue
+		}
+		if filter != nil && !filter.CreatedAfter.IsZero() && !createdAt.Time.After(filter.CreatedAfter) {
+			// Games are in the order they were created, so the rest are too old.
+			break
+		}
+
+		prs, err := playersInGame(sdb, g.ID)
+		if err != nil {
+			return nil, "", err
+		}
+		lg := &codenames.ListedGame{Game: g, CreatedAt: createdAt.Time, Players: prs}
+		if !filter.Matches(lg) {
+			continue
+		}
+		if limit > 0 && len(out) == limit {
+			// There's at least one more game, so there's another page.
+			return out, strconv.FormatInt(lastID, 10), nil
+		}
+		out = append(out, lg)
+		lastID = rowID
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error scanning rows: %w", err)
+	}
+
+	return out, "", nil
+}
+
 func (s *DB) PendingGames() ([]codenames.GameID, error) {
+	return s.gameIDs(getPendingGamesStmt)
+}
+
+func (s *DB) PlayingGames() ([]codenames.GameID, error) {
+	return s.gameIDs(getPlayingGamesStmt)
+}
+
+// gameIDs runs a query that returns a list of game IDs.
+func (s *DB) gameIDs(stmt string) ([]codenames.GameID, error) {
 	type result struct {
 		ids []codenames.GameID
 		err error
@@ -322,7 +675,7 @@ func (s *DB) PendingGames() ([]codenames.GameID, error) {
 
 	resChan := make(chan *result)
 	s.dbChan <- func(sdb *sql.DB) {
-		rows, err := sdb.Query(getPendingGamesStmt)
+		rows, err := sdb.Query(stmt)
 		if err != nil {
 			resChan <- &result{err: err}
 			return
@@ -362,69 +715,68 @@ func (s *DB) PlayersInGame(gID codenames.GameID) ([]*codenames.PlayerRole, error
 	resChan := make(chan *result)
 
 	s.dbChan <- func(sdb *sql.DB) {
-		rows, err := sdb.Query(getGamePlayers, gID)
-		if err != nil {
-			resChan <- &result{err: fmt.Errorf("failed to query for game players: %w", err)}
-			return
-		}
-		defer rows.Close()
+		prs, err := playersInGame(sdb, gID)
+		resChan <- &result{prs: prs, err: err}
+	}
+	res := <-resChan
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.prs, nil
+}
 
-		var prs []*codenames.PlayerRole
-		for rows.Next() {
-			var (
-				pr codenames.PlayerRole
+func playersInGame(sdb *sql.DB, gID codenames.GameID) ([]*codenames.PlayerRole, error) {
+	rows, err := sdb.Query(getGamePlayers, gID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query for game players: %w", err)
+	}
+	defer rows.Close()
 
-				role   sql.NullString
-				team   sql.NullString
-				userID sql.NullString
-				aiID   sql.NullString
-			)
-			if err := rows.Scan(&userID, &aiID, &role, &team, &pr.RoleAssigned); err != nil {
-				resChan <- &result{err: fmt.Errorf("failed to scan game player: %w", err)}
-				return
-			}
-			if role.Valid {
-				pr.Role = codenames.Role(role.String)
-			}
-			if team.Valid {
-				pr.Team = codenames.Team(team.String)
-			}
-			if userID.Valid && aiID.Valid {
-				resChan <- &result{err: fmt.Errorf("both user_id and ai_id were set: %q, %q", userID.String, aiID.String)}
-				return
-			}
-			if !userID.Valid && !aiID.Valid {
-				resChan <- &result{err: errors.New("neither of user_id or ai_id were set")}
-				return
-			}
-			if userID.Valid {
-				pr.PlayerID = codenames.PlayerID{
-					PlayerType: codenames.PlayerTypeHuman,
-					ID:         userID.String,
-				}
-			}
-			if aiID.Valid {
-				pr.PlayerID = codenames.PlayerID{
-					PlayerType: codenames.PlayerTypeRobot,
-					ID:         aiID.String,
-				}
+	var prs []*codenames.PlayerRole
+	for rows.Next() {
+		var (
+			pr codenames.PlayerRole
+
+			role   sql.NullString
+			team   sql.NullString
+			userID sql.NullString
+			aiID   sql.NullString
+		)
+		if err := rows.Scan(&userID, &aiID, &role, &team, &pr.RoleAssigned); err != nil {
+			return nil, fmt.Errorf("failed to scan game player: %w", err)
+		}
+		if role.Valid {
+			pr.Role = codenames.Role(role.String)
+		}
+		if team.Valid {
+			pr.Team = codenames.Team(team.String)
+		}
+		if userID.Valid && aiID.Valid {
+			return nil, fmt.Errorf("both user_id and ai_id were set: %q, %q", userID.String, aiID.String)
+		}
+		if !userID.Valid && !aiID.Valid {
+			return nil, errors.New("neither of user_id or ai_id were set")
+		}
+		if userID.Valid {
+			pr.PlayerID = codenames.PlayerID{
+				PlayerType: codenames.PlayerTypeHuman,
+				ID:         userID.String,
 			}
-			prs = append(prs, &pr)
 		}
-
-		if err := rows.Err(); err != nil {
-			resChan <- &result{err: fmt.Errorf("error scanning rows: %w", err)}
-			return
+		if aiID.Valid {
+			pr.PlayerID = codenames.PlayerID{
+				PlayerType: codenames.PlayerTypeRobot,
+				ID:         aiID.String,
+			}
 		}
-
-		resChan <- &result{prs: prs}
-		return
+		prs = append(prs, &pr)
 	}
-	res := <-resChan
-	if res.err != nil {
-		return nil, res.err
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning rows: %w", err)
 	}
-	return res.prs, nil
+
+	return prs, nil
 }
 
 func (s *DB) JoinGame(gID codenames.GameID, pID codenames.PlayerID) error {
@@ -452,6 +804,37 @@ func (s *DB) JoinGame(gID codenames.GameID, pID codenames.PlayerID) error {
 	return nil
 }
 
+func (s *DB) LeaveGame(gID codenames.GameID, pID codenames.PlayerID) error {
+	entityID, err := s.Player(pID)
+	if err != nil {
+		return fmt.Errorf("failed to load player: %w", err)
+	}
+
+	resChan := make(chan error)
+	s.dbChan <- func(sdb *sql.DB) {
+		res, err := sdb.Exec(leaveGameStmt, gID, entityID)
+		if err != nil {
+			resChan <- fmt.Errorf("failed to leave game: %w", err)
+			return
+		}
+		numRows, err := res.RowsAffected()
+		if err != nil {
+			resChan <- fmt.Errorf("failed to get the number of affected rows: %w", err)
+			return
+		}
+		if numRows != 1 {
+			resChan <- fmt.Errorf("%d rows affected, expected exactly 1", numRows)
+			return
+		}
+		resChan <- nil
+	}
+
+	if err := <-resChan; err != nil {
+		return err
+	}
+	return nil
+}
+
 func (s *DB) AssignRole(gID codenames.GameID, req *codenames.PlayerRole) error {
 	// First, see if a player entity already exists for this player.
 	pID, err := s.Player(req.PlayerID)
@@ -553,6 +936,245 @@ func (s *DB) Player(id codenames.PlayerID) (string, error) {
 	return res.id, nil
 }
 
+func (s *DB) PlayerRecords(pIDs []codenames.PlayerID) (map[codenames.PlayerID]*codenames.PlayerRecord, error) {
+	type result struct {
+		records map[codenames.PlayerID]*codenames.PlayerRecord
+		err     error
+	}
+
+	resChan := make(chan *result)
+	s.dbChan <- func(sdb *sql.DB) {
+		out := make(map[codenames.PlayerID]*codenames.PlayerRecord)
+		for _, pID := range pIDs {
+			var stmt string
+			switch pID.PlayerType {
+			case codenames.PlayerTypeHuman:
+				stmt = getUserRecordStmt
+			case codenames.PlayerTypeRobot:
+				stmt = getAIRecordStmt
+			default:
+				resChan <- &result{err: fmt.Errorf("unknown player type %q", pID.PlayerType)}
+				return
+			}
+
+			rec, err := playerRecord(sdb, stmt, pID.ID)
+			if err != nil {
+				resChan <- &result{err: fmt.Errorf("failed to load record for %q: %w", pID, err)}
+				return
+			}
+			out[pID] = rec
+		}
+		resChan <- &result{records: out}
+	}
+
+	res := <-resChan
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.records, nil
+}
+
+func playerRecord(sdb *sql.DB, stmt, id string) (*codenames.PlayerRecord, error) {
+	rows, err := sdb.Query(stmt, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query games: %w", err)
+	}
+	defer rows.Close()
+
+	rec := &codenames.PlayerRecord{}
+	for rows.Next() {
+		var (
+			team, role, winner sql.NullString
+			finishedAt         sql.NullTime
+			ob                 []byte
+		)
+		if err := rows.Scan(&team, &role, &winner, &finishedAt, &ob); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		if codenames.Role(role.String).IsSpectator() {
+			continue
+		}
+		var stats *codenames.TeamStats
+		if ob != nil {
+			outcome, err := outcomeFromBytes(ob)
+			if err != nil {
+				return nil, err
+			}
+			stats = outcome.Teams[codenames.Team(team.String)]
+		}
+		won := team.Valid && team.String == winner.String
+		rec.Add(codenames.Role(role.String), won, finishedAt.Time, stats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning rows: %w", err)
+	}
+	return rec, nil
+}
+
+func (s *DB) PlayerGames(pID codenames.PlayerID, limit, offset int) ([]*codenames.PlayerGame, error) {
+	var stmt string
+	switch pID.PlayerType {
+	case codenames.PlayerTypeHuman:
+		stmt = getUserGamesStmt
+	case codenames.PlayerTypeRobot:
+		stmt = getAIGamesStmt
+	default:
+		return nil, fmt.Errorf("unknown player type %q", pID.PlayerType)
+	}
+	if limit <= 0 {
+		// A negative limit means no limit to SQLite.
+		limit = -1
+	}
+
+	type result struct {
+		games []*codenames.PlayerGame
+		err   error
+	}
+
+	resChan := make(chan *result)
+	s.dbChan <- func(sdb *sql.DB) {
+		rows, err := sdb.Query(stmt, pID.ID, limit, offset)
+		if err != nil {
+			resChan <- &result{err: fmt.Errorf("failed to query for player games: %w", err)}
+			return
+		}
+		defer rows.Close()
+
+		var out []*codenames.PlayerGame
+		for rows.Next() {
+			var (
+				pr         = &codenames.PlayerRole{PlayerID: pID}
+				role, team sql.NullString
+			)
+			g, err := scanGame(rows, &pr.RoleAssigned, &role, &team)
+			if err != nil {
+				resChan <- &result{err: fmt.Errorf("failed to scan player game: %w", err)}
+				return
+			}
+			pr.Role = codenames.Role(role.String)
+			pr.Team = codenames.Team(team.String)
+			out = append(out, &codenames.PlayerGame{Game: g, Role: pr})
+		}
+
+		if err := rows.Err(); err != nil {
+			resChan <- &result{err: fmt.Errorf("error scanning rows: %w", err)}
+			return
+		}
+
+		resChan <- &result{games: out}
+	}
+
+	res := <-resChan
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.games, nil
+}
+
+func (s *DB) Ratings(pIDs []codenames.PlayerID) ([]*codenames.Rating, error) {
+	type result struct {
+		ratings []*codenames.Rating
+		err     error
+	}
+
+	resChan := make(chan *result)
+	s.dbChan <- func(sdb *sql.DB) {
+		var out []*codenames.Rating
+		for _, pID := range pIDs {
+			rs, err := queryRatings(sdb, getRatingsStmt, pID.PlayerType, pID.ID)
+			if err != nil {
+				resChan <- &result{err: fmt.Errorf("failed to load ratings for %q: %w", pID, err)}
+				return
+			}
+			out = append(out, rs...)
+		}
+		resChan <- &result{ratings: out}
+	}
+
+	res := <-resChan
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.ratings, nil
+}
+
+func (s *DB) UpdateRatings(rs []*codenames.Rating) error {
+	resChan := make(chan error)
+	s.dbChan <- func(sdb *sql.DB) {
+		tx, err := sdb.Begin()
+		if err != nil {
+			resChan <- err
+			return
+		}
+		defer tx.Rollback()
+
+		for _, r := range rs {
+			_, err := tx.Exec(updateRatingStmt, r.PlayerID.PlayerType, r.PlayerID.ID, r.Role, r.Rating, r.Games, r.Wins)
+			if err != nil {
+				resChan <- fmt.Errorf("failed to save %s rating for %q: %w", r.Role, r.PlayerID, err)
+				return
+			}
+		}
+
+		resChan <- tx.Commit()
+	}
+
+	if err := <-resChan; err != nil {
+		return fmt.Errorf("failed to update ratings: %w", err)
+	}
+	return nil
+}
+
+func (s *DB) Leaderboard(role codenames.Role, limit int) ([]*codenames.Rating, error) {
+	type result struct {
+		ratings []*codenames.Rating
+		err     error
+	}
+
+	resChan := make(chan *result)
+	s.dbChan <- func(sdb *sql.DB) {
+		rs, err := queryRatings(sdb, getLeaderboardStmt, role, limit)
+		if err != nil {
+			resChan <- &result{err: fmt.Errorf("failed to load leaderboard: %w", err)}
+			return
+		}
+		resChan <- &result{ratings: rs}
+	}
+
+	res := <-resChan
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.ratings, nil
+}
+
+func queryRatings(sdb *sql.DB, stmt string, args ...interface{}) ([]*codenames.Rating, error) {
+	rows, err := sdb.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ratings: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*codenames.Rating
+	for rows.Next() {
+		var (
+			r          codenames.Rating
+			playerType string
+			role       string
+		)
+		if err := rows.Scan(&playerType, &r.PlayerID.ID, &role, &r.Rating, &r.Games, &r.Wins); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		r.PlayerID.PlayerType = codenames.PlayerType(playerType)
+		r.Role = codenames.Role(role)
+		out = append(out, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning rows: %w", err)
+	}
+	return out, nil
+}
+
 func (s *DB) BatchPlayerNames(pIDs []codenames.PlayerID) (map[codenames.PlayerID]string, error) {
 	type result struct {
 		names map[codenames.PlayerID]string
@@ -651,6 +1273,32 @@ func (s *DB) StartGame(gID codenames.GameID) error {
 	return nil
 }
 
+func (s *DB) SetGameCreator(gID codenames.GameID, uID codenames.UserID) error {
+	resChan := make(chan error)
+	s.dbChan <- func(sdb *sql.DB) {
+		_, err := sdb.Exec(setGameCreatorStmt, uID, gID)
+		resChan <- err
+	}
+
+	if err := <-resChan; err != nil {
+		return fmt.Errorf("failed to update game creator: %w", err)
+	}
+	return nil
+}
+
+func (s *DB) SetRematch(gID, rematchID codenames.GameID) error {
+	resChan := make(chan error)
+	s.dbChan <- func(sdb *sql.DB) {
+		_, err := sdb.Exec(setRematchStmt, string(rematchID), string(gID))
+		resChan <- err
+	}
+
+	if err := <-resChan; err != nil {
+		return fmt.Errorf("failed to set rematch: %w", err)
+	}
+	return nil
+}
+
 func (s *DB) UpdateState(gID codenames.GameID, gs *codenames.GameState) error {
 	gsb, err := gameStateBytes(gs)
 	if err != nil {
@@ -669,6 +1317,174 @@ func (s *DB) UpdateState(gID codenames.GameID, gs *codenames.GameState) error {
 	return nil
 }
 
+func (s *DB) ClaimTurnTimer(gID codenames.GameID, owner string, deadline, lapsesAt time.Time) (bool, error) {
+	type result struct {
+		claimed bool
+		err     error
+	}
+
+	resChan := make(chan *result)
+	s.dbChan <- func(sdb *sql.DB) {
+		res, err := sdb.Exec(claimTurnTimerStmt, gID, owner, deadline.UnixNano(), lapsesAt.UnixNano(), time.Now().UnixNano())
+		if err != nil {
+			resChan <- &result{err: err}
+			return
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			resChan <- &result{err: err}
+			return
+		}
+		resChan <- &result{claimed: n > 0}
+	}
+
+	res := <-resChan
+	if res.err != nil {
+		return false, fmt.Errorf("failed to claim turn timer: %w", res.err)
+	}
+	return res.claimed, nil
+}
+
+func (s *DB) FinishGame(gID codenames.GameID, winner codenames.Team, outcome *codenames.Outcome) error {
+	ob, err := outcomeBytes(outcome)
+	if err != nil {
+		return fmt.Errorf("failed to serialize outcome: %w", err)
+	}
+
+	resChan := make(chan error)
+	s.dbChan <- func(sdb *sql.DB) {
+		_, err := sdb.Exec(finishGameStmt, winner, time.Now(), ob, gID)
+		resChan <- err
+	}
+
+	if err := <-resChan; err != nil {
+		return fmt.Errorf("failed to mark game finished: %w", err)
+	}
+	return nil
+}
+
+func (s *DB) RecordEvent(gID codenames.GameID, ev *codenames.Event) error {
+	evb, err := eventBytes(ev)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+
+	resChan := make(chan error)
+	s.dbChan <- func(sdb *sql.DB) {
+		_, err := sdb.Exec(updateGameHistoryStmt, gID, ev.Timestamp, evb)
+		resChan <- err
+	}
+
+	if err := <-resChan; err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+	return nil
+}
+
+func (s *DB) GameHistory(gID codenames.GameID) ([]*codenames.Event, error) {
+	type result struct {
+		evs []*codenames.Event
+		err error
+	}
+
+	resChan := make(chan *result)
+	s.dbChan <- func(sdb *sql.DB) {
+		rows, err := sdb.Query(getGameHistoryStmt, gID)
+		if err != nil {
+			resChan <- &result{err: fmt.Errorf("failed to query for game history: %w", err)}
+			return
+		}
+		defer rows.Close()
+
+		var evs []*codenames.Event
+		for rows.Next() {
+			var evb []byte
+			if err := rows.Scan(&evb); err != nil {
+				resChan <- &result{err: fmt.Errorf("failed to scan event: %w", err)}
+				return
+			}
+			ev, err := eventFromBytes(evb)
+			if err != nil {
+				resChan <- &result{err: err}
+				return
+			}
+			evs = append(evs, ev)
+		}
+
+		if err := rows.Err(); err != nil {
+			resChan <- &result{err: fmt.Errorf("error scanning rows: %w", err)}
+			return
+		}
+
+		resChan <- &result{evs: evs}
+	}
+
+	res := <-resChan
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.evs, nil
+}
+
+func (s *DB) AddChatMessage(gID codenames.GameID, cm *codenames.ChatMessage) error {
+	resChan := make(chan error)
+	s.dbChan <- func(sdb *sql.DB) {
+		_, err := sdb.Exec(addChatMessageStmt, gID, cm.PlayerID.PlayerType, cm.PlayerID.ID, cm.Scope, cm.Team, cm.Message, cm.Timestamp)
+		resChan <- err
+	}
+
+	if err := <-resChan; err != nil {
+		return fmt.Errorf("failed to add chat message: %w", err)
+	}
+	return nil
+}
+
+func (s *DB) ChatMessages(gID codenames.GameID) ([]*codenames.ChatMessage, error) {
+	type result struct {
+		cms []*codenames.ChatMessage
+		err error
+	}
+
+	resChan := make(chan *result)
+	s.dbChan <- func(sdb *sql.DB) {
+		rows, err := sdb.Query(getChatMessagesStmt, gID)
+		if err != nil {
+			resChan <- &result{err: fmt.Errorf("failed to query for chat messages: %w", err)}
+			return
+		}
+		defer rows.Close()
+
+		var cms []*codenames.ChatMessage
+		for rows.Next() {
+			var (
+				cm                      codenames.ChatMessage
+				playerType, scope, team string
+			)
+			if err := rows.Scan(&playerType, &cm.PlayerID.ID, &scope, &team, &cm.Message, &cm.Timestamp); err != nil {
+				resChan <- &result{err: fmt.Errorf("failed to scan chat message: %w", err)}
+				return
+			}
+			cm.PlayerID.PlayerType = codenames.PlayerType(playerType)
+			cm.Scope = codenames.ChatScope(scope)
+			cm.Team = codenames.Team(team)
+			cms = append(cms, &cm)
+		}
+
+		if err := rows.Err(); err != nil {
+			resChan <- &result{err: fmt.Errorf("error scanning rows: %w", err)}
+			return
+		}
+
+		resChan <- &result{cms: cms}
+	}
+
+	res := <-resChan
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.cms, nil
+}
+
 func (s *DB) uniqueID(tx *sql.Tx) (codenames.GameID, error) {
 	i := 0
 	var id codenames.GameID
@@ -702,3 +1518,31 @@ func gameStateFromBytes(dat []byte) (*codenames.GameState, error) {
 	}
 	return &gs, nil
 }
+
+func eventBytes(ev *codenames.Event) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(ev)
+	return buf.Bytes(), err
+}
+
+func eventFromBytes(dat []byte) (*codenames.Event, error) {
+	var ev codenames.Event
+	if err := gob.NewDecoder(bytes.NewReader(dat)).Decode(&ev); err != nil {
+		return nil, fmt.Errorf("failed to load event: %w", err)
+	}
+	return &ev, nil
+}
+
+func outcomeBytes(o *codenames.Outcome) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(o)
+	return buf.Bytes(), err
+}
+
+func outcomeFromBytes(dat []byte) (*codenames.Outcome, error) {
+	var o codenames.Outcome
+	if err := gob.NewDecoder(bytes.NewReader(dat)).Decode(&o); err != nil {
+		return nil, fmt.Errorf("failed to load outcome: %w", err)
+	}
+	return &o, nil
+}
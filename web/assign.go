@@ -0,0 +1,147 @@
+package web
+
+import (
+	"sort"
+
+	"github.com/bcspragu/Codenames/codenames"
+)
+
+// AssignmentStrategy is how players that don't have a role get one when the
+// game starts.
+type AssignmentStrategy string
+
+const (
+	// RandomAssignment fills the open spymaster spots and then deals everyone
+	// else out to the teams in a random order. It's the default.
+	RandomAssignment = AssignmentStrategy("RANDOM")
+	// BalancedAssignment gives the open spymaster spots to the players who've
+	// gone the longest without being spymaster, spreads robots across teams,
+	// and then evens out the teams using each player's win rate.
+	BalancedAssignment = AssignmentStrategy("BALANCED")
+)
+
+// randomAssignments assigns the unassigned players, which should already be
+// shuffled, to the open spymaster spots first and then round-robin to the
+// teams as operatives.
+func randomAssignments(teams []codenames.Team, unassigned []*codenames.PlayerRole, openSpymaster map[codenames.Team]bool) []*codenames.PlayerRole {
+	open := make(map[codenames.Team]bool)
+	for team, ok := range openSpymaster {
+		open[team] = ok
+	}
+
+	var out []*codenames.PlayerRole
+	for i, pr := range unassigned {
+		assigned := false
+		for _, team := range teams {
+			if !open[team] {
+				continue
+			}
+			out = append(out, &codenames.PlayerRole{PlayerID: pr.PlayerID, Team: team, Role: codenames.SpymasterRole})
+			open[team] = false
+			assigned = true
+			break
+		}
+		if assigned {
+			continue
+		}
+
+		out = append(out, &codenames.PlayerRole{PlayerID: pr.PlayerID, Team: teams[i%len(teams)], Role: codenames.OperativeRole})
+	}
+	return out
+}
+
+// balancedAssignments assigns the unassigned players so that the teams come
+// out as even as possible, counting the players that already have a role. The
+// unassigned players should already be shuffled, which breaks ties.
+func balancedAssignments(teams []codenames.Team, prs, unassigned []*codenames.PlayerRole, openSpymaster map[codenames.Team]bool, records map[codenames.PlayerID]*codenames.PlayerRecord) []*codenames.PlayerRole {
+	type teamStats struct {
+		size     int
+		robots   int
+		strength float64
+	}
+	stats := make(map[codenames.Team]*teamStats)
+	for _, team := range teams {
+		stats[team] = &teamStats{}
+	}
+	add := func(pID codenames.PlayerID, team codenames.Team) {
+		ts, ok := stats[team]
+		if !ok {
+			return
+		}
+		ts.size++
+		ts.strength += records[pID].WinRate()
+		if pID.PlayerType == codenames.PlayerTypeRobot {
+			ts.robots++
+		}
+	}
+	for _, pr := range prs {
+		if pr.RoleAssigned && !pr.Role.IsSpectator() {
+			add(pr.PlayerID, pr.Team)
+		}
+	}
+
+	// Whoever has gone the longest without being spymaster gets the open
+	// spymaster spots, starting with people who've never done it.
+	remaining := append([]*codenames.PlayerRole(nil), unassigned...)
+	sort.SliceStable(remaining, func(i, j int) bool {
+		a, b := records[remaining[i].PlayerID], records[remaining[j].PlayerID]
+		return spymasteredBefore(a, b)
+	})
+
+	var out []*codenames.PlayerRole
+	for _, team := range teams {
+		if !openSpymaster[team] || len(remaining) == 0 {
+			continue
+		}
+		pr := remaining[0]
+		remaining = remaining[1:]
+		out = append(out, &codenames.PlayerRole{PlayerID: pr.PlayerID, Team: team, Role: codenames.SpymasterRole})
+		add(pr.PlayerID, team)
+	}
+
+	// Everyone else becomes an operative, strongest players first, so the
+	// weaker players can even things out at the end. Robots go first, so they
+	// get spread out before the teams fill up with people.
+	sort.SliceStable(remaining, func(i, j int) bool {
+		a, b := remaining[i], remaining[j]
+		aRobot, bRobot := a.PlayerID.PlayerType == codenames.PlayerTypeRobot, b.PlayerID.PlayerType == codenames.PlayerTypeRobot
+		if aRobot != bRobot {
+			return aRobot
+		}
+		return records[a.PlayerID].WinRate() > records[b.PlayerID].WinRate()
+	})
+	for _, pr := range remaining {
+		isRobot := pr.PlayerID.PlayerType == codenames.PlayerTypeRobot
+		best := teams[0]
+		for _, team := range teams[1:] {
+			ts, bs := stats[team], stats[best]
+			switch {
+			case isRobot && ts.robots != bs.robots:
+				if ts.robots < bs.robots {
+					best = team
+				}
+			case ts.size != bs.size:
+				if ts.size < bs.size {
+					best = team
+				}
+			case ts.strength < bs.strength:
+				best = team
+			}
+		}
+		out = append(out, &codenames.PlayerRole{PlayerID: pr.PlayerID, Team: best, Role: codenames.OperativeRole})
+		add(pr.PlayerID, best)
+	}
+	return out
+}
+
+// spymasteredBefore returns true if the player with record a should get to be
+// spymaster before the player with record b, because they've waited longer.
+func spymasteredBefore(a, b *codenames.PlayerRecord) bool {
+	if a == nil || a.LastSpymaster == nil {
+		return b != nil && b.LastSpymaster != nil
+	}
+	if b == nil || b.LastSpymaster == nil {
+		return false
+	}
+	return a.LastSpymaster.Before(*b.LastSpymaster)
+}
@@ -0,0 +1,205 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcspragu/Codenames/codenames"
+	"github.com/bcspragu/Codenames/httperr"
+)
+
+const (
+	maxChatLength = 500
+
+	// Players can send at most chatRateLimit messages in any chatRateWindow,
+	// across all of their games.
+	chatRateLimit  = 5
+	chatRateWindow = 10 * time.Second
+)
+
+func (s *Srv) serveChat(w http.ResponseWriter, r *http.Request, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	if userPR == nil {
+		return httperr.
+			Forbidden("player %q tried to chat in game %q, which they aren't in", p.ID, g.ID).
+			WithMessage("you need to join this game first")
+	}
+
+	var req struct {
+		Message string `json:"message"`
+		Scope   string `json:"scope"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httperr.BadRequest("failed to decode chat request: %w", err)
+	}
+
+	scope := codenames.ChatScopeAll
+	if req.Scope != "" {
+		var ok bool
+		if scope, ok = codenames.ToChatScope(req.Scope); !ok {
+			return httperr.
+				BadRequest("player %q sent a chat message with invalid scope %q", p.ID, req.Scope).
+				WithMessage("invalid scope")
+		}
+	}
+
+	msg := strings.TrimSpace(req.Message)
+	if msg == "" {
+		return httperr.
+			BadRequest("player %q sent an empty chat message to game %q", p.ID, g.ID).
+			WithMessage("no message given")
+	}
+	if len(msg) > maxChatLength {
+		return httperr.
+			BadRequest("player %q sent a %d byte chat message to game %q", p.ID, len(msg), g.ID).
+			WithMessage("message is too long")
+	}
+
+	cm := &codenames.ChatMessage{
+		PlayerID:  p.ID,
+		Scope:     scope,
+		Message:   msg,
+		Timestamp: time.Now(),
+	}
+	if scope == codenames.ChatScopeTeam {
+		cm.Team = userPR.Team
+		if userPR.Team == codenames.NoTeam || !canSeeChat(g, userPR, cm) {
+			return httperr.
+				Forbidden("player %q with role %q on team %q tried to send team chat in game %q", p.ID, userPR.Role, userPR.Team, g.ID).
+				WithMessage("only operatives can use team chat")
+		}
+	}
+
+	if !s.chat.allow(p.ID, cm.Timestamp) {
+		return httperr.
+			TooManyRequests("player %q is sending chat messages too quickly", p.ID).
+			WithMessage("you're sending messages too quickly, slow down")
+	}
+
+	if err := s.db.AddChatMessage(g.ID, cm); err != nil {
+		return httperr.
+			Internal("failed to save chat message for game %q: %w", g.ID, err).
+			WithMessage("failed to send message")
+	}
+
+	out := &ChatMessage{ChatMessage: cm, Name: p.Name}
+	for _, pr := range prs {
+		if !canSeeChat(g, pr, cm) {
+			continue
+		}
+		if err := s.hub.ToPlayer(g.ID, pr.PlayerID, out); err != nil {
+			return httperr.
+				Internal("failed to send chat message for game %q: %w", g.ID, err).
+				WithMessage("failed to send message")
+		}
+	}
+
+	return jsonResp(w, struct {
+		Success bool `json:"success"`
+	}{true})
+}
+
+// serveChatHistory returns the chat messages sent in a game that the player
+// is allowed to see.
+func (s *Srv) serveChatHistory(w http.ResponseWriter, r *http.Request, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	if userPR == nil {
+		return httperr.
+			Forbidden("player %q tried to read chat in game %q, which they aren't in", p.ID, g.ID).
+			WithMessage("you need to join this game first")
+	}
+
+	cms, err := s.db.ChatMessages(g.ID)
+	if err != nil {
+		return httperr.
+			Internal("failed to load chat messages for game %q: %w", g.ID, err).
+			WithMessage("failed to load chat")
+	}
+
+	var (
+		visible []*codenames.ChatMessage
+		pIDs    []codenames.PlayerID
+	)
+	for _, cm := range cms {
+		if canSeeChat(g, userPR, cm) {
+			visible = append(visible, cm)
+			pIDs = append(pIDs, cm.PlayerID)
+		}
+	}
+
+	names, err := s.db.BatchPlayerNames(pIDs)
+	if err != nil {
+		return httperr.
+			Internal("failed to load names for chat in game %q: %w", g.ID, err).
+			WithMessage("failed to load player names")
+	}
+
+	out := []*ChatMessage{}
+	for _, cm := range visible {
+		out = append(out, &ChatMessage{ChatMessage: cm, Name: names[cm.PlayerID]})
+	}
+	return jsonResp(w, out)
+}
+
+// canSeeChat returns whether the given player should see a chat message.
+// Everyone sees messages sent to the whole game, but team chat is only for
+// operatives on the team it was sent to, so spymasters and spectators can't
+// see (or leak) what the operatives are discussing. Duet has no spymasters,
+// so everyone on the side sees it there.
+func canSeeChat(game *codenames.Game, pr *codenames.PlayerRole, cm *codenames.ChatMessage) bool {
+	if cm.Scope != codenames.ChatScopeTeam {
+		return true
+	}
+	if !pr.RoleAssigned || pr.Role.IsSpectator() || pr.Team != cm.Team {
+		return false
+	}
+	return game.State.Mode == codenames.DuetMode || pr.Role == codenames.OperativeRole
+}
+
+// chatLimiter keeps track of when players sent chat messages recently, so they
+// can't flood a game.
+type chatLimiter struct {
+	mu   sync.Mutex
+	sent map[codenames.PlayerID][]time.Time
+	// lastPrune is when players who haven't chatted within the window were
+	// last forgotten about.
+	lastPrune time.Time
+}
+
+func newChatLimiter() *chatLimiter {
+	return &chatLimiter{sent: make(map[codenames.PlayerID][]time.Time)}
+}
+
+// allow reports whether the player can send a message now, and if so, records
+// that they did.
+func (cl *chatLimiter) allow(pID codenames.PlayerID, now time.Time) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	// Every so often, forget about players who haven't chatted lately, so the
+	// map doesn't grow with everyone who ever sent a message.
+	if now.Sub(cl.lastPrune) >= chatRateWindow {
+		for id, sent := range cl.sent {
+			if now.Sub(sent[len(sent)-1]) >= chatRateWindow {
+				delete(cl.sent, id)
+			}
+		}
+		cl.lastPrune = now
+	}
+
+	// Forget about anything that's out of the window.
+	var recent []time.Time
+	for _, t := range cl.sent[pID] {
+		if now.Sub(t) < chatRateWindow {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= chatRateLimit {
+		cl.sent[pID] = recent
+		return false
+	}
+	cl.sent[pID] = append(recent, now)
+	return true
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcspragu/Codenames/codenames"
+	"github.com/bcspragu/Codenames/httperr"
+)
+
+// sseKeepAlive is how often we send a comment down an idle event stream, so
+// proxies don't time it out and we notice when the client is gone.
+const sseKeepAlive = 30 * time.Second
+
+// serveEvents streams updates to a game as Server-Sent Events, for clients
+// that can't use WebSockets. Players get the same messages they would over a
+// WebSocket, but can't send actions back over it.
+//
+// Each event's ID is the epoch and sequence number of the message, so clients
+// that reconnect with a Last-Event-ID header get what they missed, the same
+// way WebSocket clients do with ?epoch=...&since=....
+func (s *Srv) serveEvents(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return httperr.
+			Internal("response writer of type %T doesn't support streaming", w).
+			WithMessage("streaming isn't supported")
+	}
+
+	var (
+		msgs <-chan []byte
+		stop func()
+	)
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		epoch, since, err := parseEventID(id)
+		if err != nil {
+			return httperr.
+				BadRequest("event stream request had invalid Last-Event-ID %q: %w", id, err).
+				WithMessage("invalid Last-Event-ID")
+		}
+		msgs, stop = s.hub.ListenSince(game.ID, p.ID, epoch, since)
+	} else {
+		msgs, stop = s.hub.Listen(game.ID, p.ID)
+	}
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Tell nginx and friends not to buffer the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	var epoch string
+	for {
+		var buf bytes.Buffer
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				// We fell behind, the client can reconnect and catch up.
+				return nil
+			}
+			epoch = writeEvent(&buf, msg, epoch)
+		case <-keepAlive.C:
+			buf.WriteString(": keep-alive\n\n")
+		case <-r.Context().Done():
+			return nil
+		}
+
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			log.Printf("failed to write event for player %q in game %q: %v", p.ID, game.ID, err)
+			return nil
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a message from the hub as an event, returning the epoch
+// the stream is in after it.
+func writeEvent(buf *bytes.Buffer, msg []byte, epoch string) string {
+	var header struct {
+		Epoch string `json:"epoch"`
+		Seq   uint64 `json:"seq"`
+	}
+	if err := json.Unmarshal(msg, &header); err != nil {
+		log.Printf("failed to decode message header for event stream: %v", err)
+	}
+	// The epoch is only in the first message, and when we start over.
+	if header.Epoch != "" {
+		epoch = header.Epoch
+	}
+	if epoch != "" && (header.Seq > 0 || header.Epoch != "") {
+		fmt.Fprintf(buf, "id: %s\n", formatEventID(epoch, header.Seq))
+	}
+
+	// Our messages are single lines of JSON, but the format allows for more.
+	for _, line := range strings.Split(strings.TrimSpace(string(msg)), "\n") {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	return epoch
+}
+
+func formatEventID(epoch string, seq uint64) string {
+	return epoch + ":" + strconv.FormatUint(seq, 10)
+}
+
+func parseEventID(id string) (string, uint64, error) {
+	idx := strings.LastIndex(id, ":")
+	if idx == -1 {
+		return "", 0, errors.New("no ':' in event ID")
+	}
+	seq, err := strconv.ParseUint(id[idx+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid sequence number: %w", err)
+	}
+	return id[:idx], seq, nil
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"sync"
+
+	"github.com/bcspragu/Codenames/codenames"
+)
+
+// gameLocks serializes changes to each game, so that two requests, or a request
+// and a turn timer running out, can't both load the same state and have one
+// overwrite the other's move.
+type gameLocks struct {
+	mu    sync.Mutex
+	locks map[codenames.GameID]*gameLock
+}
+
+type gameLock struct {
+	mu sync.Mutex
+	// refs is the number of callers holding or waiting on the lock, so it can
+	// be cleaned up once nobody needs it.
+	refs int
+}
+
+func newGameLocks() *gameLocks {
+	return &gameLocks{locks: make(map[codenames.GameID]*gameLock)}
+}
+
+// lock blocks until it has the lock for the game, and returns the function to
+// release it.
+func (gl *gameLocks) lock(gID codenames.GameID) func() {
+	gl.mu.Lock()
+	l, ok := gl.locks[gID]
+	if !ok {
+		l = &gameLock{}
+		gl.locks[gID] = l
+	}
+	l.refs++
+	gl.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		gl.mu.Lock()
+		defer gl.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(gl.locks, gID)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/bcspragu/Codenames/codenames"
 )
@@ -11,6 +12,67 @@ type Player struct {
 	Name     string             `json:"name"`
 	Team     codenames.Team     `json:"team"`
 	Role     codenames.Role     `json:"role"`
+	// Online is whether the player is connected to the game right now.
+	Online bool `json:"online"`
+}
+
+// HistoryStep is a single clue or guess in a game, along with what the board
+// looked like right after it happened.
+type HistoryStep struct {
+	Event *codenames.Event `json:"event"`
+	Board *codenames.Board `json:"board"`
+}
+
+// GameListing is a page of the games in the lobby.
+type GameListing struct {
+	Games []*GameSummary `json:"games"`
+	// NextCursor is passed back as the cursor to load the next page of games,
+	// it's empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// GameSummary is what the lobby shows about a pending game.
+type GameSummary struct {
+	ID        codenames.GameID  `json:"id"`
+	Creator   *codenames.Player `json:"creator"`
+	CreatedAt time.Time         `json:"created_at"`
+	// AgeSecs is how long ago the game was created.
+	AgeSecs int                `json:"age_secs"`
+	Mode    codenames.GameMode `json:"mode"`
+	Teams   []codenames.Team   `json:"teams"`
+	// NumPlayers is how many players have joined, not counting spectators.
+	NumPlayers    int  `json:"num_players"`
+	NumSpectators int  `json:"num_spectators"`
+	HasAI         bool `json:"has_ai"`
+	// OpenSpymasters are the teams that still need a spymaster.
+	OpenSpymasters []codenames.Team `json:"open_spymasters"`
+	TeamsLocked    bool             `json:"teams_locked"`
+}
+
+// PlayerGames is a page of the games a player is in, along with their record
+// across all of their finished games.
+type PlayerGames struct {
+	Games []*codenames.PlayerGame `json:"games"`
+	// NextOffset is where the next page of games starts, or zero if there are
+	// no more games.
+	NextOffset int                     `json:"next_offset,omitempty"`
+	Record     *codenames.PlayerRecord `json:"record"`
+}
+
+// RatedPlayer is a player's rating in one role, along with their name.
+type RatedPlayer struct {
+	*codenames.Rating
+	Name string `json:"name"`
+}
+
+// PlayerStats is how a player has done across all of their finished games.
+type PlayerStats struct {
+	Player *codenames.Player       `json:"player"`
+	Record *codenames.PlayerRecord `json:"record"`
+	// Spymaster and Operative are the player's ratings in each role, which are
+	// codenames.InitialRating until they've played a rated game in that role.
+	Spymaster *codenames.Rating `json:"spymaster"`
+	Operative *codenames.Rating `json:"operative"`
 }
 
 type jsonGameStart GameStart
@@ -45,6 +107,9 @@ type PlayerVote struct {
 	PlayerID  codenames.PlayerID `json:"player_id"`
 	Guess     string             `json:"guess"`
 	Confirmed bool               `json:"confirmed"`
+	// Pass is true if the player voted to end their turn, in which case Guess
+	// is empty.
+	Pass bool `json:"pass"`
 }
 
 func (pv *PlayerVote) MarshalJSON() ([]byte, error) {
@@ -70,10 +135,151 @@ func (gg *GuessGiven) MarshalJSON() ([]byte, error) {
 	}{jsonGuessGiven(*gg), "GUESS_GIVEN"})
 }
 
+type jsonTurnPassed TurnPassed
+type TurnPassed struct {
+	Team codenames.Team  `json:"team"`
+	Game *codenames.Game `json:"game"`
+	// Expired is true if the turn ended because the team ran out of time,
+	// which can also happen to a spymaster that didn't give a clue.
+	Expired bool `json:"expired,omitempty"`
+}
+
+func (tp *TurnPassed) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonTurnPassed
+		Action string `json:"action"`
+	}{jsonTurnPassed(*tp), "TURN_PASSED"})
+}
+
+type jsonTurnTimer TurnTimer
+
+// TurnTimer is sent when a timed turn starts, with when it runs out.
+type TurnTimer struct {
+	Team     codenames.Team `json:"team"`
+	Role     codenames.Role `json:"role"`
+	Deadline time.Time      `json:"deadline"`
+}
+
+func (tt *TurnTimer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonTurnTimer
+		Action string `json:"action"`
+	}{jsonTurnTimer(*tt), "TIMER"})
+}
+
+type jsonPlayerJoined PlayerJoined
+
+// PlayerJoined is sent when a player joins a game, along with everyone that's
+// in the game now.
+type PlayerJoined struct {
+	Player  *Player   `json:"player"`
+	Players []*Player `json:"players"`
+}
+
+func (pj *PlayerJoined) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonPlayerJoined
+		Action string `json:"action"`
+	}{jsonPlayerJoined(*pj), "PLAYER_JOINED"})
+}
+
+type jsonRoleAssigned RoleAssigned
+
+// RoleAssigned is sent when a player is given a team and role, along with
+// everyone that's in the game now.
+type RoleAssigned struct {
+	Player  *Player   `json:"player"`
+	Players []*Player `json:"players"`
+}
+
+func (ra *RoleAssigned) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonRoleAssigned
+		Action string `json:"action"`
+	}{jsonRoleAssigned(*ra), "ROLE_ASSIGNED"})
+}
+
+type jsonTeamsLocked TeamsLocked
+
+// TeamsLocked is sent when the game creator locks or unlocks teams.
+type TeamsLocked struct {
+	Locked bool `json:"locked"`
+}
+
+func (tl *TeamsLocked) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonTeamsLocked
+		Action string `json:"action"`
+	}{jsonTeamsLocked(*tl), "TEAMS_LOCKED"})
+}
+
+type jsonPlayerLeft PlayerLeft
+
+// PlayerLeft is sent when a player leaves a game on their own, along with
+// everyone that's still in the game.
+type PlayerLeft struct {
+	PlayerID codenames.PlayerID `json:"player_id"`
+	Players  []*Player          `json:"players"`
+}
+
+func (pl *PlayerLeft) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonPlayerLeft
+		Action string `json:"action"`
+	}{jsonPlayerLeft(*pl), "PLAYER_LEFT"})
+}
+
+type jsonPlayerKicked PlayerKicked
+
+// PlayerKicked is sent when the game creator removes a player from a game,
+// along with everyone that's still in the game.
+type PlayerKicked struct {
+	PlayerID codenames.PlayerID `json:"player_id"`
+	Players  []*Player          `json:"players"`
+}
+
+func (pk *PlayerKicked) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonPlayerKicked
+		Action string `json:"action"`
+	}{jsonPlayerKicked(*pk), "PLAYER_KICKED"})
+}
+
+type jsonCreatorChanged CreatorChanged
+
+// CreatorChanged is sent when another user takes over running a game, either
+// because it was handed to them or because the old game creator left.
+type CreatorChanged struct {
+	CreatedBy codenames.UserID `json:"created_by"`
+}
+
+func (cc *CreatorChanged) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonCreatorChanged
+		Action string `json:"action"`
+	}{jsonCreatorChanged(*cc), "CREATOR_CHANGED"})
+}
+
+type jsonRematch Rematch
+
+// Rematch is sent to everyone in a finished game when someone starts a rematch
+// of it, so they can head over to the new game.
+type Rematch struct {
+	GameID codenames.GameID `json:"game_id"`
+}
+
+func (rm *Rematch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonRematch
+		Action string `json:"action"`
+	}{jsonRematch(*rm), "REMATCH"})
+}
+
 type jsonGameEnd GameEnd
 type GameEnd struct {
-	WinningTeam codenames.Team  `json:"winning_team"`
-	Game        *codenames.Game `json:"game"`
+	WinningTeam codenames.Team     `json:"winning_team"`
+	Game        *codenames.Game    `json:"game"`
+	Outcome     *codenames.Outcome `json:"outcome"`
 }
 
 func (ge *GameEnd) MarshalJSON() ([]byte, error) {
@@ -82,3 +288,88 @@ func (ge *GameEnd) MarshalJSON() ([]byte, error) {
 		Action string `json:"action"`
 	}{jsonGameEnd(*ge), "GAME_END"})
 }
+
+type jsonChatMessage ChatMessage
+
+// ChatMessage is sent when a player says something, to everyone in the game
+// that can see it. Team chat only goes to the operatives on that team.
+type ChatMessage struct {
+	*codenames.ChatMessage
+	Name string `json:"name"`
+}
+
+func (cm *ChatMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonChatMessage
+		Action string `json:"action"`
+	}{jsonChatMessage(*cm), "CHAT"})
+}
+
+type jsonAck Ack
+
+// Ack is sent back over a WebSocket connection when an action the player sent
+// over it succeeded.
+type Ack struct {
+	// ID is the ID of the request that succeeded.
+	ID string `json:"id"`
+	// Data is what the equivalent HTTP endpoint would have responded with, if
+	// anything.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (a *Ack) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonAck
+		Action string `json:"action"`
+	}{jsonAck(*a), "ACK"})
+}
+
+type jsonActionError ActionError
+
+// ActionError is sent back over a WebSocket connection when an action the
+// player sent over it failed.
+type ActionError struct {
+	// ID is the ID of the request that failed, which is empty if the request
+	// couldn't be parsed.
+	ID string `json:"id"`
+	// Code is the HTTP status code the equivalent HTTP endpoint would have
+	// responded with.
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (ae *ActionError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonActionError
+		Action string `json:"action"`
+	}{jsonActionError(*ae), "ERROR"})
+}
+
+type jsonPlayerOnline PlayerOnline
+
+// PlayerOnline is sent when a player connects to a game they weren't already
+// connected to.
+type PlayerOnline struct {
+	PlayerID codenames.PlayerID `json:"player_id"`
+}
+
+func (po *PlayerOnline) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonPlayerOnline
+		Action string `json:"action"`
+	}{jsonPlayerOnline(*po), "PLAYER_ONLINE"})
+}
+
+type jsonPlayerOffline PlayerOffline
+
+// PlayerOffline is sent when a player's last connection to a game closes.
+type PlayerOffline struct {
+	PlayerID codenames.PlayerID `json:"player_id"`
+}
+
+func (po *PlayerOffline) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jsonPlayerOffline
+		Action string `json:"action"`
+	}{jsonPlayerOffline(*po), "PLAYER_OFFLINE"})
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bcspragu/Codenames/codenames"
+	"github.com/bcspragu/Codenames/httperr"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultLeaderboardSize = 20
+	maxLeaderboardSize     = 100
+)
+
+// updateRatings rates everyone who played in a finished game, humans and
+// robots alike.
+func (s *Srv) updateRatings(gID codenames.GameID, winner codenames.Team) error {
+	prs, err := s.db.PlayersInGame(gID)
+	if err != nil {
+		return httperr.
+			Internal("failed to load players for finished game %q: %w", gID, err).
+			WithMessage("failed to load players")
+	}
+
+	var pIDs []codenames.PlayerID
+	for _, pr := range prs {
+		pIDs = append(pIDs, pr.PlayerID)
+	}
+
+	current, err := s.db.Ratings(pIDs)
+	if err != nil {
+		return httperr.
+			Internal("failed to load ratings for players in game %q: %w", gID, err).
+			WithMessage("failed to load ratings")
+	}
+
+	rs := codenames.RateGame(prs, winner, current)
+	if len(rs) == 0 {
+		return nil
+	}
+
+	if err := s.db.UpdateRatings(rs); err != nil {
+		return httperr.
+			Internal("failed to update ratings for players in game %q: %w", gID, err).
+			WithMessage("failed to update ratings")
+	}
+	return nil
+}
+
+func (s *Srv) serveLeaderboard(w http.ResponseWriter, r *http.Request) error {
+	role := codenames.OperativeRole
+	if rs := r.URL.Query().Get("role"); rs != "" {
+		var ok bool
+		if role, ok = codenames.ToRole(rs); !ok {
+			return httperr.
+				BadRequest("leaderboard request had invalid role %q", rs).
+				WithMessage("invalid role")
+		}
+	}
+
+	limit := defaultLeaderboardSize
+	if ls := r.URL.Query().Get("limit"); ls != "" {
+		var err error
+		if limit, err = strconv.Atoi(ls); err != nil || limit <= 0 {
+			return httperr.
+				BadRequest("leaderboard request had invalid limit %q", ls).
+				WithMessage("invalid limit")
+		}
+	}
+	if limit > maxLeaderboardSize {
+		limit = maxLeaderboardSize
+	}
+
+	rs, err := s.db.Leaderboard(role, limit)
+	if err != nil {
+		return httperr.
+			Internal("failed to load %s leaderboard: %w", role, err).
+			WithMessage("failed to load leaderboard")
+	}
+
+	var pIDs []codenames.PlayerID
+	for _, r := range rs {
+		pIDs = append(pIDs, r.PlayerID)
+	}
+	names, err := s.db.BatchPlayerNames(pIDs)
+	if err != nil {
+		return httperr.
+			Internal("failed to load names for leaderboard: %w", err).
+			WithMessage("failed to load player names")
+	}
+
+	out := []*RatedPlayer{}
+	for _, r := range rs {
+		out = append(out, &RatedPlayer{Rating: r, Name: names[r.PlayerID]})
+	}
+	return jsonResp(w, out)
+}
+
+func (s *Srv) serveUserStats(w http.ResponseWriter, r *http.Request) error {
+	uID := codenames.UserID(mux.Vars(r)["id"])
+	u, err := s.db.User(uID)
+	if errors.Is(err, codenames.ErrUserNotFound) {
+		return httperr.
+			NotFound("no user found with ID %q", uID).
+			WithMessage("user not found")
+	} else if err != nil {
+		return httperr.
+			Internal("failed to load user %q: %w", uID, err).
+			WithMessage("failed to load user")
+	}
+
+	return s.serveStats(w, &codenames.Player{ID: u.ID.AsPlayerID(), Name: u.Name})
+}
+
+func (s *Srv) serveAIStats(w http.ResponseWriter, r *http.Request) error {
+	rID := codenames.RobotID(mux.Vars(r)["id"])
+	rb, err := s.db.Robot(rID)
+	if errors.Is(err, codenames.ErrRobotNotFound) {
+		return httperr.
+			NotFound("no AI found with ID %q", rID).
+			WithMessage("AI not found")
+	} else if err != nil {
+		return httperr.
+			Internal("failed to load AI %q: %w", rID, err).
+			WithMessage("failed to load AI")
+	}
+
+	return s.serveStats(w, &codenames.Player{ID: rb.ID.AsPlayerID(), Name: rb.Name})
+}
+
+func (s *Srv) serveStats(w http.ResponseWriter, p *codenames.Player) error {
+	recs, err := s.db.PlayerRecords([]codenames.PlayerID{p.ID})
+	if err != nil {
+		return httperr.
+			Internal("failed to load record for player %q: %w", p.ID, err).
+			WithMessage("failed to load player record")
+	}
+
+	rs, err := s.db.Ratings([]codenames.PlayerID{p.ID})
+	if err != nil {
+		return httperr.
+			Internal("failed to load ratings for player %q: %w", p.ID, err).
+			WithMessage("failed to load ratings")
+	}
+
+	stats := &PlayerStats{
+		Player:    p,
+		Record:    recs[p.ID],
+		Spymaster: codenames.NewRating(p.ID, codenames.SpymasterRole),
+		Operative: codenames.NewRating(p.ID, codenames.OperativeRole),
+	}
+	for _, r := range rs {
+		switch r.Role {
+		case codenames.SpymasterRole:
+			stats.Spymaster = r
+		case codenames.OperativeRole:
+			stats.Operative = r
+		}
+	}
+	return jsonResp(w, stats)
+}
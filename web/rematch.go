@@ -0,0 +1,157 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/bcspragu/Codenames/codenames"
+	"github.com/bcspragu/Codenames/game"
+	"github.com/bcspragu/Codenames/httperr"
+)
+
+func (s *Srv) serveRematch(w http.ResponseWriter, r *http.Request, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	if userPR == nil || userPR.Role.IsSpectator() {
+		return httperr.
+			Forbidden("player %q tried to ask for a rematch of game %q, which they didn't play in", p.ID, g.ID).
+			WithMessage("only players can ask for a rematch")
+	}
+
+	// The request body is optional, no body means every player keeps their role
+	// and the starting team is picked at random, like in a new game.
+	var req struct {
+		RotateSpymasters bool `json:"rotate_spymasters"`
+		AlternateStarter bool `json:"alternate_starter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		return httperr.BadRequest("failed to decode rematch request: %w", err)
+	}
+
+	s.rematchMu.Lock()
+	defer s.rematchMu.Unlock()
+
+	// Load the game again now that we have the lock, someone else might have
+	// beaten us to it.
+	gID := g.ID
+	g, err := s.db.Game(gID)
+	if err != nil {
+		return httperr.
+			Internal("failed to load game %q: %w", gID, err).
+			WithMessage("failed to load game")
+	}
+	if g.RematchID != "" {
+		return jsonResp(w, struct {
+			ID string `json:"id"`
+		}{string(g.RematchID)})
+	}
+
+	old := g.State
+	starter := s.pickStarter(old.Teams)
+	if req.AlternateStarter {
+		starter = game.NextTeam(old.AllTeams(), old.StartingTeam)
+	}
+
+	state := &codenames.GameState{
+		Mode:         old.Mode,
+		StartingTeam: starter,
+		ActiveTeam:   starter,
+		ActiveRole:   codenames.SpymasterRole,
+		Teams:        old.Teams,
+		Spec:         old.Spec.Clone(),
+		Timers:       old.Timers.Clone(),
+
+		AllowSpymasterSpectators: old.AllowSpymasterSpectators,
+		TeamsLocked:              old.TeamsLocked,
+		Unlisted:                 old.Unlisted,
+	}
+	s.dealBoard(state)
+
+	id, err := s.db.NewGame(&codenames.Game{
+		CreatedBy: g.CreatedBy,
+		State:     state,
+		RematchOf: g.ID,
+	})
+	if err != nil {
+		return httperr.
+			Internal("failed to create rematch of game %q: %w", g.ID, err).
+			WithMessage("failed to create rematch")
+	}
+	if err := s.db.SetRematch(g.ID, id); err != nil {
+		return httperr.
+			Internal("failed to link game %q to rematch %q: %w", g.ID, id, err).
+			WithMessage("failed to create rematch")
+	}
+
+	if req.RotateSpymasters {
+		prs = rotateSpymasters(prs, old.AllTeams())
+	}
+	for _, pr := range prs {
+		// Spectators were only watching, so they aren't signed up for the
+		// rematch. They can follow it from the old game and spectate again.
+		if pr.RoleAssigned && pr.Role.IsSpectator() {
+			continue
+		}
+		if err := s.db.JoinGame(id, pr.PlayerID); err != nil {
+			return httperr.
+				Internal("failed to join rematch %q with player %q: %w", id, pr.PlayerID, err).
+				WithMessage("failed to add players to rematch")
+		}
+		if !pr.RoleAssigned {
+			continue
+		}
+		if err := s.db.AssignRole(id, pr); err != nil {
+			return httperr.
+				Internal("failed to assign role (%q, %q) to player %q in rematch %q: %w", pr.Team, pr.Role, pr.PlayerID, id, err).
+				WithMessage("failed to add players to rematch")
+		}
+		s.recordEvent(id, &codenames.Event{
+			Type:     codenames.EventRoleAssigned,
+			PlayerID: pr.PlayerID,
+			Team:     pr.Team,
+			Role:     pr.Role,
+		})
+	}
+
+	if err := s.hub.ToGame(g.ID, &Rematch{GameID: id}); err != nil {
+		return httperr.
+			Internal("failed to send rematch for game %q: %w", g.ID, err).
+			WithMessage("failed to inform players of rematch")
+	}
+
+	return jsonResp(w, struct {
+		ID string `json:"id"`
+	}{string(id)})
+}
+
+// rotateSpymasters returns a copy of the players where, on each team, the
+// spymaster becomes an operative and the next player on the team (in the order
+// they joined) becomes the spymaster.
+func rotateSpymasters(prs []*codenames.PlayerRole, teams []codenames.Team) []*codenames.PlayerRole {
+	out := make([]*codenames.PlayerRole, len(prs))
+	for i, pr := range prs {
+		out[i] = pr.Clone()
+	}
+
+	for _, team := range teams {
+		var (
+			members   []*codenames.PlayerRole
+			spymaster = -1
+		)
+		for _, pr := range out {
+			if !pr.RoleAssigned || pr.Team != team || pr.Role.IsSpectator() {
+				continue
+			}
+			if pr.Role == codenames.SpymasterRole {
+				spymaster = len(members)
+			}
+			members = append(members, pr)
+		}
+		if spymaster < 0 || len(members) < 2 {
+			continue
+		}
+
+		members[spymaster].Role = codenames.OperativeRole
+		members[(spymaster+1)%len(members)].Role = codenames.SpymasterRole
+	}
+	return out
+}
@@ -0,0 +1,272 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bcspragu/Codenames/codenames"
+	"github.com/bcspragu/Codenames/consensus"
+	"github.com/bcspragu/Codenames/game"
+	"github.com/bcspragu/Codenames/httperr"
+)
+
+const (
+	// turnClaimGrace is how long after a turn's deadline the server that
+	// claimed its timer has to expire it, before other servers can take over.
+	turnClaimGrace = 30 * time.Second
+
+	// turnTimerSweepInterval is how often the server looks for turn timers that
+	// nobody is running, like ones claimed by a server that's gone away.
+	turnTimerSweepInterval = 30 * time.Second
+)
+
+// turnTimers holds the scheduled expiry for each game with a timed turn in
+// progress. There's at most one per game, since only one turn is in progress
+// at a time.
+type turnTimers struct {
+	mu     sync.Mutex
+	timers map[codenames.GameID]*time.Timer
+}
+
+func newTurnTimers() *turnTimers {
+	return &turnTimers{timers: make(map[codenames.GameID]*time.Timer)}
+}
+
+// set schedules fn to run at the deadline, replacing any timer already set for
+// the game.
+func (tt *turnTimers) set(gID codenames.GameID, deadline time.Time, fn func()) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if t, ok := tt.timers[gID]; ok {
+		t.Stop()
+	}
+	tt.start(gID, deadline, fn)
+}
+
+// setIfUnset schedules fn to run at the deadline, unless a timer has already
+// been set for the game.
+func (tt *turnTimers) setIfUnset(gID codenames.GameID, deadline time.Time, fn func()) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if _, ok := tt.timers[gID]; ok {
+		return
+	}
+	tt.start(gID, deadline, fn)
+}
+
+// start schedules fn to run at the deadline. The timer forgets itself when it
+// fires, unless it's been replaced by then, so that setIfUnset can schedule
+// the game's next one. tt.mu must be held.
+func (tt *turnTimers) start(gID codenames.GameID, deadline time.Time, fn func()) {
+	var t *time.Timer
+	t = time.AfterFunc(time.Until(deadline), func() {
+		tt.mu.Lock()
+		if tt.timers[gID] == t {
+			delete(tt.timers, gID)
+		}
+		tt.mu.Unlock()
+		fn()
+	})
+	tt.timers[gID] = t
+}
+
+// has reports whether a timer is set for the game.
+func (tt *turnTimers) has(gID codenames.GameID) bool {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	_, ok := tt.timers[gID]
+	return ok
+}
+
+// stop cancels the timer for the game, if there is one.
+func (tt *turnTimers) stop(gID codenames.GameID) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if t, ok := tt.timers[gID]; ok {
+		t.Stop()
+		delete(tt.timers, gID)
+	}
+}
+
+// resetDeadline starts the clock on the active role's turn, if the game has a
+// timer for that role.
+func resetDeadline(gs *codenames.GameState) {
+	d := gs.Timers.For(gs.ActiveRole)
+	if d == 0 {
+		gs.Deadline = nil
+		return
+	}
+	deadline := time.Now().Add(d).Round(0)
+	gs.Deadline = &deadline
+}
+
+// startTurnTimer schedules the current turn to expire at its deadline, and
+// lets everyone know when that is. It should be called after the state with
+// the deadline has been saved.
+func (s *Srv) startTurnTimer(gID codenames.GameID, gs *codenames.GameState) error {
+	if gs.Deadline == nil {
+		s.timers.stop(gID)
+		return nil
+	}
+
+	claimed, err := s.claimTurnTimer(gID, *gs.Deadline)
+	if err != nil {
+		return httperr.
+			Internal("failed to claim turn timer for game %q: %w", gID, err).
+			WithMessage("failed to start turn timer")
+	}
+	if claimed {
+		s.scheduleExpiry(gID, *gs.Deadline)
+	} else {
+		// Another server already picked up the new turn, so it runs the timer.
+		s.timers.stop(gID)
+	}
+
+	if err := s.hub.ToGame(gID, &TurnTimer{
+		Team:     gs.ActingTeam(),
+		Role:     gs.ActiveRole,
+		Deadline: *gs.Deadline,
+	}); err != nil {
+		return httperr.
+			Internal("failed to send turn timer for game %q: %w", gID, err).
+			WithMessage("failed to inform players of turn timer")
+	}
+	return nil
+}
+
+func (s *Srv) scheduleExpiry(gID codenames.GameID, deadline time.Time) {
+	s.timers.set(gID, deadline, s.expiry(gID, deadline))
+}
+
+func (s *Srv) expiry(gID codenames.GameID, deadline time.Time) func() {
+	return func() {
+		if err := s.expireTurn(gID, deadline); err != nil {
+			log.Printf("failed to expire turn in game %q: %v", gID, err)
+		}
+	}
+}
+
+// claimTurnTimer claims the turn ending at deadline for this server, so that
+// when several servers share the DB, only one of them expires it.
+func (s *Srv) claimTurnTimer(gID codenames.GameID, deadline time.Time) (bool, error) {
+	return s.db.ClaimTurnTimer(gID, s.id, deadline, deadline.Add(turnClaimGrace))
+}
+
+// resumeTurnTimers schedules the timer for every game in progress that doesn't
+// have one on this server, as long as this server can claim it. When the
+// server starts, that picks up its games from before a restart, and after
+// that, it picks up games whose server went away once their claims lapse.
+// Turns that ran out while nobody was around expire right away.
+func (s *Srv) resumeTurnTimers() error {
+	gIDs, err := s.db.PlayingGames()
+	if err != nil {
+		return fmt.Errorf("failed to load games in progress: %w", err)
+	}
+
+	for _, gID := range gIDs {
+		if s.timers.has(gID) {
+			continue
+		}
+		g, err := s.db.Game(gID)
+		if err != nil {
+			return fmt.Errorf("failed to load game %q: %w", gID, err)
+		}
+		if g.Status != codenames.Playing || g.State.Deadline == nil {
+			continue
+		}
+		claimed, err := s.claimTurnTimer(gID, *g.State.Deadline)
+		if err != nil {
+			return fmt.Errorf("failed to claim turn timer for game %q: %w", gID, err)
+		}
+		if claimed {
+			s.timers.setIfUnset(gID, *g.State.Deadline, s.expiry(gID, *g.State.Deadline))
+		}
+	}
+	return nil
+}
+
+// sweepTurnTimers calls resumeTurnTimers every turnTimerSweepInterval, forever.
+func (s *Srv) sweepTurnTimers() {
+	for range time.Tick(turnTimerSweepInterval) {
+		if err := s.resumeTurnTimers(); err != nil {
+			log.Printf("failed to resume turn timers: %v", err)
+		}
+	}
+}
+
+// expireTurn ends the turn in progress because it ran out of time. The
+// deadline is the one the timer was set for, if the turn has moved on since
+// then, or another server has claimed the turn, nothing happens. It holds the
+// game's lock, so a move made right as the timer fires either lands first and
+// stops the expiry, or waits for it.
+func (s *Srv) expireTurn(gID codenames.GameID, deadline time.Time) error {
+	unlock := s.locks.lock(gID)
+	defer unlock()
+
+	g, err := s.db.Game(gID)
+	if err != nil {
+		return fmt.Errorf("failed to load game: %w", err)
+	}
+	if g.Status != codenames.Playing || g.State.Deadline == nil || !g.State.Deadline.Equal(deadline) {
+		return nil
+	}
+	claimed, err := s.claimTurnTimer(gID, deadline)
+	if err != nil {
+		return fmt.Errorf("failed to claim turn timer: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	prs, err := s.db.PlayersInGame(gID)
+	if err != nil {
+		return fmt.Errorf("failed to load players: %w", err)
+	}
+
+	team := g.State.ActingTeam()
+	newState, newStatus, err := game.NewForMove(g.State, s.moveConfig()).Move(&game.Move{
+		Action: game.ActionExpire,
+		Team:   team,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to expire turn: %w", err)
+	}
+
+	// Any votes in progress don't count anymore.
+	consensus.Clear(newState)
+
+	resetDeadline(newState)
+	if err := s.db.UpdateState(gID, newState); err != nil {
+		return fmt.Errorf("failed to update state: %w", err)
+	}
+	g.State = newState
+	g.Status = newStatus
+
+	s.recordEvent(gID, &codenames.Event{
+		Type: codenames.EventTurnExpired,
+		Team: team,
+	})
+
+	if err := s.broadcastMessage(g, prs, func(g *codenames.Game) interface{} {
+		return &TurnPassed{
+			Team:    team,
+			Game:    g,
+			Expired: true,
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to send turn expiry: %w", err)
+	}
+
+	// In Duet, running out of time uses up a turn, which can end the game.
+	if newStatus == codenames.Finished {
+		return s.endGame(gID)
+	}
+
+	return s.startTurnTimer(gID, newState)
+}
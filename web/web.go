@@ -2,16 +2,23 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bcspragu/Codenames/aiclient"
 	"github.com/bcspragu/Codenames/boardgen"
 	"github.com/bcspragu/Codenames/codenames"
 	"github.com/bcspragu/Codenames/consensus"
+	"github.com/bcspragu/Codenames/cryptorand"
+	"github.com/bcspragu/Codenames/dict"
 	"github.com/bcspragu/Codenames/game"
 	"github.com/bcspragu/Codenames/httperr"
 	"github.com/bcspragu/Codenames/hub"
@@ -22,6 +29,9 @@ import (
 
 const (
 	maxOperativesPerTeam = 10
+
+	defaultGamesPageSize = 20
+	maxGamesPageSize     = 100
 )
 
 type Srv struct {
@@ -31,37 +41,108 @@ type Srv struct {
 	db        codenames.DB
 	r         *rand.Rand
 	ws        *websocket.Upgrader
-	consensus *consensus.Guesser
 	ai        *aiclient.Client
+	timers    *turnTimers
+	chat      *chatLimiter
+	// id identifies this server in the turn timers it claims, see
+	// WithServerID.
+	id string
+	// locks serializes changes to each game's state.
+	locks *gameLocks
+	// wsActions are the routes that can be called over a game's WebSocket
+	// connection, keyed by action.
+	wsActions map[string]*route
+
+	// rematchMu makes sure only one rematch gets created for a game, even if
+	// everyone asks for one at the same time.
+	rematchMu sync.Mutex
+
+	clueValidator game.ClueValidator
+
+	// hubOpts configure the hub, which is created once all the options have
+	// been applied.
+	hubOpts []hub.Option
+}
+
+// Option configures optional behavior of the server.
+type Option func(*Srv)
+
+// WithDictionary requires that clues given in games are words in the given
+// dictionary, on top of the standard clue rules.
+func WithDictionary(d *dict.Dictionary) Option {
+	return func(s *Srv) {
+		s.clueValidator = &game.DefaultClueValidator{Dictionary: d}
+	}
+}
+
+// WithHubBackend sends updates to games through the given backend, so that
+// several servers sharing it can each have players from the same game
+// connected to them.
+func WithHubBackend(b hub.Backend) Option {
+	return func(s *Srv) {
+		s.hubOpts = append(s.hubOpts, hub.WithBackend(b))
+	}
+}
+
+// WithServerID sets the name this server claims turn timers under, which
+// should be unique to it among the servers sharing a DB. Keeping it the same
+// across restarts lets a restarted server pick its timers right back up,
+// rather than waiting for its old claims to lapse. By default, a random one is
+// used.
+func WithServerID(id string) Option {
+	return func(s *Srv) {
+		s.id = id
+	}
 }
 
 // New returns an initialized server.
-func New(db codenames.DB, r *rand.Rand, sc *securecookie.SecureCookie, ai *aiclient.Client) *Srv {
+func New(db codenames.DB, r *rand.Rand, sc *securecookie.SecureCookie, ai *aiclient.Client, opts ...Option) *Srv {
 	s := &Srv{
-		sc:        sc,
-		hub:       hub.New(),
-		db:        db,
-		r:         r,
-		ws:        &websocket.Upgrader{}, // use default options, for now
-		consensus: consensus.New(),
-		ai:        ai,
+		sc:            sc,
+		db:            db,
+		r:             r,
+		ws:            &websocket.Upgrader{}, // use default options, for now
+		ai:            ai,
+		timers:        newTurnTimers(),
+		locks:         newGameLocks(),
+		chat:          newChatLimiter(),
+		clueValidator: &game.DefaultClueValidator{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.id == "" {
+		s.id = codenames.RandomPlayerID(rand.New(cryptorand.NewSource()))
 	}
 
+	s.hub = hub.New(append(s.hubOpts, hub.WithPresence(presenceMsg))...)
 	s.mux = s.initMux()
 
+	if err := s.resumeTurnTimers(); err != nil {
+		log.Printf("failed to resume turn timers: %v", err)
+	}
+	go s.sweepTurnTimers()
+
 	return s
 }
 
 type handlerFunc func(w http.ResponseWriter, r *http.Request) error
 
+type route struct {
+	path        string
+	method      string
+	handlerFunc handlerFunc
+	// wsAction, if set, is the action players can send over their WebSocket
+	// connection to a game to call this handler without a separate request.
+	wsAction string
+}
+
 func (s *Srv) initMux() *mux.Router {
 	m := mux.NewRouter()
 
-	handlers := []struct {
-		path        string
-		method      string
-		handlerFunc handlerFunc
-	}{
+	handlers := []*route{
 		// New user.
 		{
 			path:        "/api/user",
@@ -86,17 +167,41 @@ func (s *Srv) initMux() *mux.Router {
 			method:      http.MethodGet,
 			handlerFunc: s.serveUser,
 		},
+		// List the games the logged in player is in.
+		{
+			path:        "/api/user/games",
+			method:      http.MethodGet,
+			handlerFunc: s.serveUserGames,
+		},
+		// Load how a user has done in their games.
+		{
+			path:        "/api/user/{id}/stats",
+			method:      http.MethodGet,
+			handlerFunc: s.serveUserStats,
+		},
+		// Load how an AI player has done in their games.
+		{
+			path:        "/api/ai/{id}/stats",
+			method:      http.MethodGet,
+			handlerFunc: s.serveAIStats,
+		},
+		// Highest rated players in a role.
+		{
+			path:        "/api/leaderboard",
+			method:      http.MethodGet,
+			handlerFunc: s.serveLeaderboard,
+		},
 		// New game.
 		{
 			path:        "/api/game",
 			method:      http.MethodPost,
 			handlerFunc: s.serveCreateGame,
 		},
-		// Pending games.
+		// Pending games in the lobby.
 		{
 			path:        "/api/games",
 			method:      http.MethodGet,
-			handlerFunc: s.servePendingGames,
+			handlerFunc: s.serveListGames,
 		},
 		// Get game.
 		{
@@ -104,6 +209,12 @@ func (s *Srv) initMux() *mux.Router {
 			method:      http.MethodGet,
 			handlerFunc: s.requireGameAuth(s.serveGame),
 		},
+		// Get the clues and guesses made so far.
+		{
+			path:        "/api/game/{id}/history",
+			method:      http.MethodGet,
+			handlerFunc: s.requireGameAuth(s.serveGameHistory),
+		},
 		// Get players.
 		{
 			path:        "/api/game/{id}/players",
@@ -120,31 +231,95 @@ func (s *Srv) initMux() *mux.Router {
 		{
 			path:        "/api/game/{id}/join",
 			method:      http.MethodPost,
-			handlerFunc: s.requireGameAuth(s.serveJoinGame, isGamePending()),
+			handlerFunc: s.requireGameAuth(s.serveJoinGame, isGamePending(), locksGame()),
+		},
+		// Watch a game without playing in it.
+		{
+			path:        "/api/game/{id}/spectate",
+			method:      http.MethodPost,
+			handlerFunc: s.requireGameAuth(s.serveSpectate),
+		},
+		// Leave a game.
+		{
+			path:        "/api/game/{id}/leave",
+			method:      http.MethodPost,
+			handlerFunc: s.requireGameAuth(s.serveLeaveGame, locksGame()),
+		},
+		// Remove another player from a game.
+		{
+			path:        "/api/game/{id}/kick",
+			method:      http.MethodPost,
+			handlerFunc: s.requireGameAuth(s.serveKickPlayer, isGameCreator(), locksGame()),
+		},
+		// Hand control of a game to another player.
+		{
+			path:        "/api/game/{id}/transferOwner",
+			method:      http.MethodPost,
+			handlerFunc: s.requireGameAuth(s.serveTransferOwner, isGameCreator()),
+		},
+		// Start a new game with the same players, once a game is over.
+		{
+			path:        "/api/game/{id}/rematch",
+			method:      http.MethodPost,
+			handlerFunc: s.requireGameAuth(s.serveRematch, isGameStatus(codenames.Finished)),
 		},
 		// Assign roles
 		{
 			path:        "/api/game/{id}/assignRole",
 			method:      http.MethodPost,
-			handlerFunc: s.requireGameAuth(s.serveAssignRole, isGameCreator(), isGamePending()),
+			handlerFunc: s.requireGameAuth(s.serveAssignRole, isGameCreator(), isGamePending(), locksGame()),
+		},
+		// Pick your own team and role.
+		{
+			path:        "/api/game/{id}/chooseRole",
+			method:      http.MethodPost,
+			handlerFunc: s.requireGameAuth(s.serveChooseRole, isGamePending(), locksGame()),
+		},
+		// Stop players from picking their own team and role.
+		{
+			path:        "/api/game/{id}/lockTeams",
+			method:      http.MethodPost,
+			handlerFunc: s.requireGameAuth(s.serveLockTeams, isGameCreator(), isGamePending(), locksGame()),
 		},
 		// Start game.
 		{
 			path:        "/api/game/{id}/start",
 			method:      http.MethodPost,
-			handlerFunc: s.requireGameAuth(s.serveStartGame, isGameCreator(), isGamePending()),
+			handlerFunc: s.requireGameAuth(s.serveStartGame, isGameCreator(), isGamePending(), locksGame()),
 		},
 		// Serve a clue to a game.
 		{
 			path:        "/api/game/{id}/clue",
 			method:      http.MethodPost,
-			handlerFunc: s.requireGameAuth(s.serveClue, isSpymaster(), isGamePlaying()),
+			wsAction:    "CLUE",
+			handlerFunc: s.requireGameAuth(s.serveClue, isSpymaster(), anyRoleInDuet(), isGamePlaying(), locksGame()),
 		},
 		// Serve a card guess to a game.
 		{
 			path:        "/api/game/{id}/guess",
 			method:      http.MethodPost,
-			handlerFunc: s.requireGameAuth(s.serveGuess, isOperative(), isGamePlaying()),
+			wsAction:    "GUESS",
+			handlerFunc: s.requireGameAuth(s.serveGuess, isOperative(), anyRoleInDuet(), isGamePlaying(), locksGame()),
+		},
+		// Vote to end the team's turn without guessing further.
+		{
+			path:        "/api/game/{id}/pass",
+			method:      http.MethodPost,
+			wsAction:    "PASS",
+			handlerFunc: s.requireGameAuth(s.servePass, isOperative(), anyRoleInDuet(), isGamePlaying(), locksGame()),
+		},
+		// Get the chat messages the player can see.
+		{
+			path:        "/api/game/{id}/chat",
+			method:      http.MethodGet,
+			handlerFunc: s.requireGameAuth(s.serveChatHistory),
+		},
+		// Send a chat message to the game, or just the player's team.
+		{
+			path:        "/api/game/{id}/chat",
+			method:      http.MethodPost,
+			wsAction:    "CHAT",
+			handlerFunc: s.requireGameAuth(s.serveChat),
 		},
 		// WebSocket handler for games.
 		{
@@ -152,10 +327,21 @@ func (s *Srv) initMux() *mux.Router {
 			method:      http.MethodGet,
 			handlerFunc: s.requireGameAuth(s.serveData),
 		},
+		// Server-Sent Events stream for games, for clients that can't use
+		// WebSockets.
+		{
+			path:        "/api/game/{id}/events",
+			method:      http.MethodGet,
+			handlerFunc: s.requireGameAuth(s.serveEvents),
+		},
 	}
 
+	s.wsActions = make(map[string]*route)
 	for _, h := range handlers {
 		m.HandleFunc(h.path, s.handleError(h.handlerFunc)).Methods(h.method)
+		if h.wsAction != "" {
+			s.wsActions[h.wsAction] = h
+		}
 	}
 
 	return m
@@ -301,6 +487,61 @@ func (s *Srv) serveUser(w http.ResponseWriter, r *http.Request) error {
 	return jsonResp(w, u)
 }
 
+func (s *Srv) serveUserGames(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.loadPlayerRequired(r)
+	if err != nil {
+		return err
+	}
+
+	limit, offset := defaultGamesPageSize, 0
+	if ls := r.URL.Query().Get("limit"); ls != "" {
+		if limit, err = strconv.Atoi(ls); err != nil || limit <= 0 {
+			return httperr.
+				BadRequest("games request had invalid limit %q", ls).
+				WithMessage("invalid limit")
+		}
+	}
+	if limit > maxGamesPageSize {
+		limit = maxGamesPageSize
+	}
+	if offs := r.URL.Query().Get("offset"); offs != "" {
+		if offset, err = strconv.Atoi(offs); err != nil || offset < 0 {
+			return httperr.
+				BadRequest("games request had invalid offset %q", offs).
+				WithMessage("invalid offset")
+		}
+	}
+
+	// Ask for one extra game, so we know if there's another page.
+	pgs, err := s.db.PlayerGames(p.ID, limit+1, offset)
+	if err != nil {
+		return httperr.
+			Internal("failed to load games for player %q: %w", p.ID, err).
+			WithMessage("failed to load games")
+	}
+
+	recs, err := s.db.PlayerRecords([]codenames.PlayerID{p.ID})
+	if err != nil {
+		return httperr.
+			Internal("failed to load record for player %q: %w", p.ID, err).
+			WithMessage("failed to load player record")
+	}
+
+	resp := &PlayerGames{
+		Games:  []*codenames.PlayerGame{},
+		Record: recs[p.ID],
+	}
+	if len(pgs) > limit {
+		pgs = pgs[:limit]
+		resp.NextOffset = offset + limit
+	}
+	for _, pg := range pgs {
+		pg.Game.State.Board = boardFor(pg.Game, pg.Role, pg.Game.State.Board)
+		resp.Games = append(resp.Games, pg)
+	}
+	return jsonResp(w, resp)
+}
+
 func (s *Srv) serveCreateGame(w http.ResponseWriter, r *http.Request) error {
 	p, err := s.loadPlayerRequired(r)
 	if err != nil {
@@ -314,19 +555,121 @@ func (s *Srv) serveCreateGame(w http.ResponseWriter, r *http.Request) error {
 			WithMessage("only users can create games")
 	}
 
-	ar := codenames.RedTeam
-	if s.r.Intn(2) == 0 {
-		ar = codenames.BlueTeam
+	// The request body is optional, no body means a classic game.
+	var req struct {
+		Mode string `json:"mode"`
+		// NumTeams is either 2 (the default) or 3, only for classic games.
+		NumTeams int `json:"num_teams"`
+		// BoardSpec is the layout of the board, only for classic games. If it
+		// isn't given, the default board for the number of teams is used.
+		BoardSpec *codenames.BoardSpec `json:"board_spec"`
+		// Board is the name of a preset board, like "QUICK", only for classic
+		// games. It's an alternative to giving the whole BoardSpec.
+		Board string `json:"board"`
+		// Timers is how long each role gets for their turn. If it isn't given,
+		// turns aren't timed.
+		Timers *codenames.TurnTimers `json:"timers"`
+		// AllowSpymasterSpectators lets spectators see the whole board before
+		// the game is over, if they ask to.
+		AllowSpymasterSpectators bool `json:"allow_spymaster_spectators"`
+		// LockTeams stops players from picking their own team and role, so only
+		// the game creator can assign them.
+		LockTeams bool `json:"lock_teams"`
+		// Unlisted keeps the game out of the lobby, so players can only join it
+		// by ID.
+		Unlisted bool `json:"unlisted"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		return httperr.BadRequest("failed to decode create game request: %w", err)
+	}
+
+	mode := codenames.ClassicMode
+	if req.Mode != "" {
+		m, ok := codenames.ToGameMode(req.Mode)
+		if !ok {
+			return httperr.
+				BadRequest("unknown game mode %q given", req.Mode).
+				WithMessage("bad game mode")
+		}
+		mode = m
+	}
+
+	var teams []codenames.Team
+	switch req.NumTeams {
+	case 0, 2:
+		// The default, red vs. blue.
+	case 3:
+		if mode != codenames.ClassicMode {
+			return httperr.
+				BadRequest("three teams were requested for a %q game", mode).
+				WithMessage("only classic games can have three teams")
+		}
+		teams = codenames.ThreeTeams
+	default:
+		return httperr.
+			BadRequest("bad number of teams %d given", req.NumTeams).
+			WithMessage("games can have two or three teams")
+	}
+
+	if req.Board != "" {
+		if req.BoardSpec != nil {
+			return httperr.
+				BadRequest("both board %q and board spec %+v were given", req.Board, req.BoardSpec).
+				WithMessage("give either a board or a board spec, not both")
+		}
+		spec, ok := codenames.ToBoardSpec(req.Board)
+		if !ok {
+			return httperr.
+				BadRequest("unknown board %q given", req.Board).
+				WithMessage("bad board, should be one of STANDARD, QUICK, or DEEP")
+		}
+		req.BoardSpec = spec
+	}
+
+	ar := s.pickStarter(teams)
+
+	state := &codenames.GameState{
+		Mode:         mode,
+		StartingTeam: ar,
+		ActiveTeam:   ar,
+		ActiveRole:   codenames.SpymasterRole,
+		Teams:        teams,
+		Spec:         req.BoardSpec,
+
+		AllowSpymasterSpectators: req.AllowSpymasterSpectators,
+		TeamsLocked:              req.LockTeams,
+		Unlisted:                 req.Unlisted,
 	}
 
+	if tt := req.Timers; tt != nil {
+		if tt.SpymasterSecs < 0 || tt.OperativeSecs < 0 {
+			return httperr.
+				BadRequest("negative turn timers %+v given", tt).
+				WithMessage("turn timers can't be negative")
+		}
+		if tt.SpymasterSecs > 0 || tt.OperativeSecs > 0 {
+			state.Timers = tt
+		}
+	}
+
+	if state.Spec != nil {
+		if mode != codenames.ClassicMode {
+			return httperr.
+				BadRequest("board spec was given for a %q game", mode).
+				WithMessage("only classic games can have custom boards")
+		}
+		if err := state.Spec.Validate(state.AllTeams()); err != nil {
+			return httperr.
+				BadRequest("invalid board spec %+v given: %w", state.Spec, err).
+				WithMessage(fmt.Sprintf("bad board spec: %v", err))
+		}
+	}
+
+	s.dealBoard(state)
+
 	id, err := s.db.NewGame(&codenames.Game{
 		CreatedBy: uID,
-		State: &codenames.GameState{
-			StartingTeam: ar,
-			ActiveTeam:   ar,
-			ActiveRole:   codenames.SpymasterRole,
-			Board:        boardgen.New(ar, s.r),
-		},
+		State:     state,
 	})
 	if err != nil {
 		return httperr.
@@ -339,23 +682,221 @@ func (s *Srv) serveCreateGame(w http.ResponseWriter, r *http.Request) error {
 	}{string(id)})
 }
 
-func (s *Srv) servePendingGames(w http.ResponseWriter, r *http.Request) error {
-	gIDs, err := s.db.PendingGames()
+// pickStarter randomly picks the team that goes first in a new game with the
+// given teams, where no teams means red vs. blue.
+func (s *Srv) pickStarter(teams []codenames.Team) codenames.Team {
+	if len(teams) > 0 {
+		return teams[s.r.Intn(len(teams))]
+	}
+	if s.r.Intn(2) == 0 {
+		return codenames.BlueTeam
+	}
+	return codenames.RedTeam
+}
+
+// dealBoard deals a fresh board for a new game, based on its mode, teams, and
+// starting team.
+func (s *Srv) dealBoard(state *codenames.GameState) {
+	switch state.Mode {
+	case codenames.DuetMode:
+		state.Board = boardgen.NewDuet(s.r)
+		state.TurnsLeft = game.DuetTurns
+	default:
+		state.Board = boardgen.NewFromSpec(state.BoardSpec(), state.AllTeams(), state.StartingTeam, s.r)
+	}
+}
+
+func (s *Srv) serveListGames(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+
+	filter := &codenames.GameFilter{
+		OpenSpymaster: q.Get("open_spymaster") == "true",
+		HasRobots:     q.Get("has_ai") == "true",
+	}
+	if cw := q.Get("created_within"); cw != "" {
+		mins, err := strconv.Atoi(cw)
+		if err != nil || mins <= 0 {
+			return httperr.
+				BadRequest("games request had invalid created_within %q", cw).
+				WithMessage("created_within should be a number of minutes")
+		}
+		filter.CreatedAfter = time.Now().Add(-time.Duration(mins) * time.Minute)
+	}
+
+	limit := defaultGamesPageSize
+	if ls := q.Get("limit"); ls != "" {
+		var err error
+		if limit, err = strconv.Atoi(ls); err != nil || limit <= 0 {
+			return httperr.
+				BadRequest("games request had invalid limit %q", ls).
+				WithMessage("invalid limit")
+		}
+	}
+	if limit > maxGamesPageSize {
+		limit = maxGamesPageSize
+	}
+
+	lgs, next, err := s.db.ListGames(filter, q.Get("cursor"), limit)
+	if errors.Is(err, codenames.ErrInvalidCursor) {
+		return httperr.
+			BadRequest("failed to list games: %w", err).
+			WithMessage("invalid cursor")
+	} else if err != nil {
+		return httperr.
+			Internal("failed to list games: %w", err).
+			WithMessage("failed to load games")
+	}
+
+	var pIDs []codenames.PlayerID
+	for _, lg := range lgs {
+		pIDs = append(pIDs, lg.Game.CreatedBy.AsPlayerID())
+	}
+	names, err := s.db.BatchPlayerNames(pIDs)
 	if err != nil {
-		return httperr.Internal("failed to load pending games: %w", err)
+		return httperr.
+			Internal("failed to load names of game creators: %w", err).
+			WithMessage("failed to load player names")
+	}
+
+	resp := &GameListing{
+		Games:      []*GameSummary{},
+		NextCursor: next,
+	}
+	now := time.Now()
+	for _, lg := range lgs {
+		creator := lg.Game.CreatedBy.AsPlayerID()
+		gs := &GameSummary{
+			ID:             lg.Game.ID,
+			Creator:        &codenames.Player{ID: creator, Name: names[creator]},
+			CreatedAt:      lg.CreatedAt,
+			AgeSecs:        int(now.Sub(lg.CreatedAt).Seconds()),
+			Mode:           lg.Game.State.Mode,
+			Teams:          lg.Game.State.AllTeams(),
+			HasAI:          lg.HasRobots(),
+			OpenSpymasters: lg.OpenSpymasters(),
+			TeamsLocked:    lg.Game.State.TeamsLocked,
+		}
+		for _, pr := range lg.Players {
+			if pr.Role.IsSpectator() {
+				gs.NumSpectators++
+			} else {
+				gs.NumPlayers++
+			}
+		}
+		resp.Games = append(resp.Games, gs)
 	}
 
-	return jsonResp(w, gIDs)
+	return jsonResp(w, resp)
 }
 
 func (s *Srv) serveGame(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	game.State.Board = boardFor(game, userPR, game.State.Board)
+	return jsonResp(w, game)
+}
+
+// boardFor returns the given board from the game, redacted for what the player
+// is allowed to see.
+func boardFor(game *codenames.Game, userPR *codenames.PlayerRole, b *codenames.Board) *codenames.Board {
+	// Unless the game is over, then there's nothing left to hide.
+	if game.Status == codenames.Finished {
+		return b
+	}
+
+	if userPR != nil && userPR.Role.IsSpectator() {
+		if spymasterView(game, userPR) {
+			return b
+		}
+		return codenames.Revealed(b)
+	}
+
+	// In Duet, everyone sees their own side of the key.
+	if game.State.Mode == codenames.DuetMode {
+		if userPR == nil {
+			return codenames.Revealed(b)
+		}
+		return codenames.KeyView(b, userPR.Team)
+	}
+
 	// If you aren't in this game or ain't a spymaster, you don't get to see what
 	// color all the cards are, that's [REDACTED].
 	if userPR == nil || userPR.Role != codenames.SpymasterRole {
-		game.State.Board = codenames.Revealed(game.State.Board)
+		return codenames.Revealed(b)
 	}
+	return b
+}
 
-	return jsonResp(w, game)
+// spymasterView returns true if the player is a spectator that gets to see the
+// whole board, which they can once the game is over, or before then if the
+// game creator allowed it.
+func spymasterView(game *codenames.Game, pr *codenames.PlayerRole) bool {
+	if pr.Role != codenames.SpymasterSpectatorRole {
+		return false
+	}
+	return game.Status == codenames.Finished || game.State.AllowSpymasterSpectators
+}
+
+func (s *Srv) serveGameHistory(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	evs, err := s.db.GameHistory(game.ID)
+	if err != nil {
+		return httperr.
+			Internal("failed to load history for game %q: %w", game.ID, err).
+			WithMessage("failed to load game history")
+	}
+
+	steps := replayHistory(game.State, evs)
+
+	// Same deal as serveGame, only spymasters get to see the card colors while
+	// the game is going. Once it's over, everything is fair game.
+	for _, step := range steps {
+		step.Board = boardFor(game, userPR, step.Board)
+	}
+
+	return jsonResp(w, steps)
+}
+
+// replayHistory reconstructs what the board looked like after each clue and
+// guess in the game. Guesses are the only thing that modify the board, so we
+// start from the current board with every card flipped back over, and reveal
+// cards as they're guessed.
+func replayHistory(current *codenames.GameState, evs []*codenames.Event) []*HistoryStep {
+	board := current.Board.Clone()
+	for i := range board.Cards {
+		board.Cards[i].Revealed = false
+		board.Cards[i].RevealedBy = codenames.NoTeam
+		if current.Mode == codenames.DuetMode {
+			// Duet cards only get an agent once they're revealed.
+			board.Cards[i].Agent = codenames.UnknownAgent
+		}
+	}
+
+	var steps []*HistoryStep
+	for _, ev := range evs {
+		switch ev.Type {
+		case codenames.EventGuess:
+			for i, card := range board.Cards {
+				if strings.ToLower(card.Codename) != strings.ToLower(ev.Guess) {
+					continue
+				}
+				board.Cards[i].Revealed = true
+				board.Cards[i].RevealedBy = ev.Team
+				if ev.Card != nil {
+					board.Cards[i].Agent = ev.Card.Agent
+					board.Cards[i].RevealedBy = ev.Card.RevealedBy
+				}
+			}
+		case codenames.EventClueGiven, codenames.EventPass, codenames.EventTurnExpired, codenames.EventGameEnded:
+			// These don't change the board, but they're still steps in the game.
+		default:
+			// Lobby events and votes aren't interesting for a replay.
+			continue
+		}
+
+		steps = append(steps, &HistoryStep{
+			Event: ev,
+			Board: board.Clone(),
+		})
+	}
+	return steps
 }
 
 func (s *Srv) serveRequestAI(w http.ResponseWriter, r *http.Request, creator *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
@@ -371,7 +912,7 @@ func (s *Srv) serveRequestAI(w http.ResponseWriter, r *http.Request, creator *co
 }
 
 func (s *Srv) serveGamePlayers(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
-	players, err := s.toPlayers(prs)
+	players, err := s.toPlayers(game.ID, prs)
 	if err != nil {
 		return httperr.
 			Internal("failed to convert players in game %q: %w", game.ID, err).
@@ -396,11 +937,251 @@ func (s *Srv) serveJoinGame(w http.ResponseWriter, r *http.Request, p *codenames
 			WithMessage("failed to join game")
 	}
 
+	if err := s.sendPlayerJoined(game.ID, p.ID); err != nil {
+		return err
+	}
+
+	return jsonResp(w, struct {
+		Success bool `json:"success"`
+	}{true})
+}
+
+func (s *Srv) serveSpectate(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	// The request body is optional, no body means the operative view.
+	var req struct {
+		SpymasterView bool `json:"spymaster_view"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		return httperr.BadRequest("failed to decode spectate request: %w", err)
+	}
+
+	role := codenames.SpectatorRole
+	if req.SpymasterView {
+		role = codenames.SpymasterSpectatorRole
+	}
+
+	switch {
+	case userPR == nil:
+		if err := s.db.JoinGame(game.ID, p.ID); err != nil {
+			return httperr.
+				Internal("failed to join game %q with spectator %q: %w", game.ID, p.ID, err).
+				WithMessage("failed to join game")
+		}
+		if err := s.sendPlayerJoined(game.ID, p.ID); err != nil {
+			return err
+		}
+	case userPR.RoleAssigned && !userPR.Role.IsSpectator():
+		return httperr.
+			BadRequest("player %q tried to spectate game %q, already joined as %q %q", p.ID, game.ID, userPR.Team, userPR.Role).
+			WithMessage("you're already playing in this game")
+	}
+
+	// Spectators are assigned their role right away, they don't need to wait
+	// for the game creator.
+	if err := s.db.AssignRole(game.ID, &codenames.PlayerRole{
+		PlayerID: p.ID,
+		Role:     role,
+	}); err != nil {
+		return httperr.
+			Internal("failed to make %q a spectator in game %q: %w", p.ID, game.ID, err).
+			WithMessage("failed to spectate game")
+	}
+	s.recordEvent(game.ID, &codenames.Event{
+		Type:     codenames.EventRoleAssigned,
+		PlayerID: p.ID,
+		Role:     role,
+	})
+
+	players, err := s.lobbyPlayers(game.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.hub.ToGame(game.ID, &RoleAssigned{
+		Player:  findPlayer(p.ID, players),
+		Players: players,
+	}); err != nil {
+		return httperr.
+			Internal("failed to send role assignment for game %q: %w", game.ID, err).
+			WithMessage("failed to inform players of new spectator")
+	}
+
 	return jsonResp(w, struct {
 		Success bool `json:"success"`
 	}{true})
 }
 
+func (s *Srv) serveLeaveGame(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	if userPR == nil {
+		return httperr.
+			BadRequest("player %q tried to leave game %q, which they aren't in", p.ID, game.ID).
+			WithMessage("you aren't in this game")
+	}
+	if game.Status == codenames.Finished {
+		return httperr.
+			BadRequest("player %q tried to leave game %q, which is over", p.ID, game.ID).
+			WithMessage("the game is already over")
+	}
+
+	return s.removePlayer(w, p, game, userPR, prs, false /* kicked */)
+}
+
+func (s *Srv) serveKickPlayer(w http.ResponseWriter, r *http.Request, creator *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	var req struct {
+		PlayerID codenames.PlayerID `json:"player_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httperr.BadRequest("failed to decode kick request: %w", err)
+	}
+
+	if req.PlayerID == creator.ID {
+		return httperr.
+			BadRequest("player %q tried to kick themselves from game %q", creator.ID, game.ID).
+			WithMessage("you can't kick yourself, leave the game instead")
+	}
+	if game.Status == codenames.Finished {
+		return httperr.
+			BadRequest("player %q tried to kick %q from game %q, which is over", creator.ID, req.PlayerID, game.ID).
+			WithMessage("the game is already over")
+	}
+
+	pr, ok := findRole(req.PlayerID, prs)
+	if !ok {
+		return httperr.
+			BadRequest("player %q tried to kick %q from game %q, who isn't in it", creator.ID, req.PlayerID, game.ID).
+			WithMessage("that player isn't in this game")
+	}
+
+	return s.removePlayer(w, creator, game, pr, prs, true /* kicked */)
+}
+
+func (s *Srv) serveTransferOwner(w http.ResponseWriter, r *http.Request, creator *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	var req struct {
+		PlayerID codenames.PlayerID `json:"player_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httperr.BadRequest("failed to decode transfer owner request: %w", err)
+	}
+
+	if req.PlayerID.PlayerType != codenames.PlayerTypeHuman {
+		return httperr.
+			BadRequest("player %q tried to hand game %q to non-human player %q", creator.ID, game.ID, req.PlayerID).
+			WithMessage("only people can run a game")
+	}
+	if _, ok := findRole(req.PlayerID, prs); !ok {
+		return httperr.
+			BadRequest("player %q tried to hand game %q to %q, who isn't in it", creator.ID, game.ID, req.PlayerID).
+			WithMessage("that player isn't in this game")
+	}
+
+	if err := s.setCreator(game.ID, codenames.UserID(req.PlayerID.ID)); err != nil {
+		return err
+	}
+
+	return jsonResp(w, struct {
+		Success bool `json:"success"`
+	}{true})
+}
+
+// removePlayer takes a player out of the game, either because they left or
+// because they were kicked, and cleans up anything that was waiting on them.
+// The player p is the one that made the request.
+func (s *Srv) removePlayer(w http.ResponseWriter, p *codenames.Player, g *codenames.Game, pr *codenames.PlayerRole, prs []*codenames.PlayerRole, kicked bool) error {
+	if err := s.db.LeaveGame(g.ID, pr.PlayerID); err != nil {
+		return httperr.
+			Internal("failed to remove player %q from game %q: %w", pr.PlayerID, g.ID, err).
+			WithMessage("failed to remove player from game")
+	}
+
+	var remaining []*codenames.PlayerRole
+	for _, other := range prs {
+		if other.PlayerID != pr.PlayerID {
+			remaining = append(remaining, other)
+		}
+	}
+	players, err := s.toPlayers(g.ID, remaining)
+	if err != nil {
+		return httperr.
+			Internal("failed to convert players in game %q: %w", g.ID, err).
+			WithMessage("failed to make players")
+	}
+
+	evType := codenames.EventPlayerLeft
+	var msg interface{} = &PlayerLeft{PlayerID: pr.PlayerID, Players: players}
+	if kicked {
+		evType = codenames.EventPlayerKicked
+		msg = &PlayerKicked{PlayerID: pr.PlayerID, Players: players}
+	}
+	s.recordEvent(g.ID, &codenames.Event{
+		Type:     evType,
+		PlayerID: pr.PlayerID,
+		Team:     pr.Team,
+		Role:     pr.Role,
+	})
+	if err := s.hub.ToGame(g.ID, msg); err != nil {
+		return httperr.
+			Internal("failed to send player removal for game %q: %w", g.ID, err).
+			WithMessage("failed to inform players of player leaving")
+	}
+
+	// Close the kicked player's connections once they've been told, so they
+	// stop getting the game's updates.
+	if kicked {
+		if err := s.hub.Disconnect(g.ID, pr.PlayerID); err != nil {
+			return httperr.
+				Internal("failed to disconnect kicked player %q from game %q: %w", pr.PlayerID, g.ID, err).
+				WithMessage("failed to disconnect kicked player")
+		}
+	}
+
+	// If the game creator left, hand the game to whoever joined after them, so
+	// that the game doesn't get stuck without anyone to run it.
+	if string(g.CreatedBy) == pr.PlayerID.ID && pr.PlayerID.PlayerType == codenames.PlayerTypeHuman {
+		for _, other := range remaining {
+			if other.PlayerID.PlayerType != codenames.PlayerTypeHuman {
+				continue
+			}
+			if err := s.setCreator(g.ID, codenames.UserID(other.PlayerID.ID)); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	// If the team is in the middle of voting, the player's vote doesn't count
+	// anymore, and there's one less voter needed to agree. That might be
+	// enough for the rest of the team to have reached consensus.
+	if g.Status == codenames.Playing && g.State.ActiveRole == codenames.OperativeRole && pr.Team == g.State.GuessingTeam() {
+		word, hasConsensus := consensus.RemoveVoter(g.State, pr.PlayerID, countVoters(remaining, g.State))
+		if hasConsensus {
+			return s.handleConsensus(w, p, g, pr, remaining, word)
+		}
+		if err := s.saveVotes(g); err != nil {
+			return err
+		}
+	}
+
+	return jsonResp(w, struct {
+		Success bool `json:"success"`
+	}{true})
+}
+
+// setCreator hands control of the game to the given user, and lets everyone
+// know.
+func (s *Srv) setCreator(gID codenames.GameID, uID codenames.UserID) error {
+	if err := s.db.SetGameCreator(gID, uID); err != nil {
+		return httperr.
+			Internal("failed to set creator of game %q to %q: %w", gID, uID, err).
+			WithMessage("failed to transfer game")
+	}
+
+	if err := s.hub.ToGame(gID, &CreatorChanged{CreatedBy: uID}); err != nil {
+		return httperr.
+			Internal("failed to send creator change for game %q: %w", gID, err).
+			WithMessage("failed to inform players of new game creator")
+	}
+	return nil
+}
+
 func (s *Srv) serveAssignRole(w http.ResponseWriter, r *http.Request, creator *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
 	var req struct {
 		PlayerID codenames.PlayerID `json:"player_id"`
@@ -417,21 +1198,94 @@ func (s *Srv) serveAssignRole(w http.ResponseWriter, r *http.Request, creator *c
 			BadRequest("failed to load player %q in assignRole: %w", req.PlayerID, err).
 			WithMessage("bad player ID given")
 	}
-	pID := req.PlayerID
 
-	desiredRole, ok := codenames.ToRole(req.Role)
-	if !ok {
+	players, err := s.assignRole(game, prs, req.PlayerID, req.Team, req.Role, false /* canSwitch */)
+	if err != nil {
+		return err
+	}
+
+	return jsonResp(w, players)
+}
+
+func (s *Srv) serveChooseRole(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	if userPR == nil {
+		return httperr.
+			Forbidden("player %q tried to pick a role in game %q without joining", p.ID, game.ID).
+			WithMessage("you need to join this game first")
+	}
+	if game.State.TeamsLocked {
+		return httperr.
+			Forbidden("player %q tried to pick a role in game %q, which has locked teams", p.ID, game.ID).
+			WithMessage("the game creator has locked teams")
+	}
+
+	var req struct {
+		Team string `json:"team"`
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httperr.BadRequest("failed to decode choose role request: %w", err)
+	}
+
+	// Players can change their mind before the game starts, as long as the
+	// role they want is still open.
+	players, err := s.assignRole(game, prs, p.ID, req.Team, req.Role, true /* canSwitch */)
+	if err != nil {
+		return err
+	}
+
+	return jsonResp(w, players)
+}
+
+func (s *Srv) serveLockTeams(w http.ResponseWriter, r *http.Request, creator *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	var req struct {
+		Locked bool `json:"locked"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httperr.BadRequest("failed to decode lock teams request: %w", err)
+	}
+
+	game.State.TeamsLocked = req.Locked
+	if err := s.db.UpdateState(game.ID, game.State); err != nil {
+		return httperr.
+			Internal("failed to update state for game %q: %w", game.ID, err).
+			WithMessage("failed to update game state")
+	}
+
+	if err := s.hub.ToGame(game.ID, &TeamsLocked{Locked: req.Locked}); err != nil {
 		return httperr.
-			BadRequest("unknown role %q given", req.Role).
+			Internal("failed to send teams locked for game %q: %w", game.ID, err).
+			WithMessage("failed to inform players of locked teams")
+	}
+
+	return jsonResp(w, struct {
+		Success bool `json:"success"`
+	}{true})
+}
+
+// assignRole puts the player on the given team and role, if it's open, and
+// lets everyone know. If canSwitch is true, a player that already has a role
+// can switch to a different one, otherwise players can only be assigned once.
+// It returns everyone in the game after the change.
+func (s *Srv) assignRole(game *codenames.Game, prs []*codenames.PlayerRole, pID codenames.PlayerID, team, role string, canSwitch bool) ([]*Player, error) {
+	desiredRole, ok := codenames.ToRole(role)
+	if !ok {
+		return nil, httperr.
+			BadRequest("unknown role %q given", role).
 			WithMessage("bad role")
 	}
 
-	desiredTeam, ok := codenames.ToTeam(req.Team)
+	desiredTeam, ok := codenames.ToTeam(team)
 	if !ok {
-		return httperr.
-			BadRequest("unknown team %q given", req.Team).
+		return nil, httperr.
+			BadRequest("unknown team %q given", team).
 			WithMessage("bad team")
 	}
+	if !isPlaying(game.State.AllTeams(), desiredTeam) {
+		return nil, httperr.
+			BadRequest("player %q wanted to join team %q, which isn't playing in game %q", pID, desiredTeam, game.ID).
+			WithMessage(fmt.Sprintf("team %q isn't playing in this game", desiredTeam))
+	}
 
 	roleCount := make(map[codenames.Role]map[codenames.Team]int)
 	for _, pr := range prs {
@@ -444,17 +1298,21 @@ func (s *Srv) serveAssignRole(w http.ResponseWriter, r *http.Request, creator *c
 			rc = make(map[codenames.Team]int)
 		}
 		if pr.PlayerID == pID {
-			return httperr.
+			if canSwitch {
+				// Their current role doesn't count against them.
+				continue
+			}
+			return nil, httperr.
 				BadRequest("player %q tried to join game %q as %q %q, already joined as %q %q", pID, game.ID, desiredTeam, desiredRole, pr.Team, pr.Role).
 				WithMessage(fmt.Sprintf("can't join game as %q %q, already joined as %q %q", desiredTeam, desiredRole, pr.Team, pr.Role))
 		}
 		if pr.Role == codenames.SpymasterRole && rc[pr.Team] > 1 {
-			return httperr.
+			return nil, httperr.
 				Internal("game %q in bad state, has multiple players has %q spymaster", game.ID, pr.Team).
 				WithMessage(fmt.Sprintf("multiple players set as %q spymaster", pr.Team))
 		}
 		if pr.Role == codenames.OperativeRole && rc[pr.Team] > maxOperativesPerTeam {
-			return httperr.
+			return nil, httperr.
 				Internal("game %q in bad state, has too many players as %q operatives", game.ID, pr.Team).
 				WithMessage(fmt.Sprintf("too many players set as %q operatives", pr.Team))
 		}
@@ -463,12 +1321,12 @@ func (s *Srv) serveAssignRole(w http.ResponseWriter, r *http.Request, creator *c
 	}
 
 	if desiredRole == codenames.SpymasterRole && roleCount[codenames.SpymasterRole][desiredTeam] > 0 {
-		return httperr.
+		return nil, httperr.
 			BadRequest("player %q wanted to be %q spymaster, but that role is already filled in game %q", pID, desiredTeam, game.ID).
 			WithMessage(fmt.Sprintf("team %q already has a spymaster", desiredTeam))
 	}
 	if desiredRole == codenames.OperativeRole && roleCount[codenames.OperativeRole][desiredTeam] >= maxOperativesPerTeam {
-		return httperr.
+		return nil, httperr.
 			BadRequest("player %q wanted to be a %q operative, but that team already has the max number of operatives in game %q", pID, desiredTeam, game.ID).
 			WithMessage(fmt.Sprintf("team %q already has max operatives", desiredTeam))
 	}
@@ -478,40 +1336,58 @@ func (s *Srv) serveAssignRole(w http.ResponseWriter, r *http.Request, creator *c
 		Team:     desiredTeam,
 		Role:     desiredRole,
 	}); err != nil {
-		return httperr.
+		return nil, httperr.
 			Internal("failed to assign role (%q, %q) to player %q in game %q: %w", desiredTeam, desiredRole, pID, game.ID, err).
 			WithMessage("failed to assign role to player")
 	}
 
+	s.recordEvent(game.ID, &codenames.Event{
+		Type:     codenames.EventRoleAssigned,
+		PlayerID: pID,
+		Team:     desiredTeam,
+		Role:     desiredRole,
+	})
+
 	// Load the updated list of players in the game.
-	prs, err := s.db.PlayersInGame(game.ID)
+	players, err := s.lobbyPlayers(game.ID)
 	if err != nil {
-		return httperr.
-			Internal("failed to load players in game %q: %w", game.ID, err).
-			WithMessage("failed to load players in game")
+		return nil, err
 	}
 
-	players, err := s.toPlayers(prs)
-	if err != nil {
-		return httperr.
-			Internal("failed to convert players in game %q: %w", game.ID, err).
-			WithMessage("failed to make players")
+	if err := s.hub.ToGame(game.ID, &RoleAssigned{
+		Player:  findPlayer(pID, players),
+		Players: players,
+	}); err != nil {
+		return nil, httperr.
+			Internal("failed to send role assignment for game %q: %w", game.ID, err).
+			WithMessage("failed to inform players of role assignment")
 	}
 
-	return jsonResp(w, players)
+	return players, nil
 }
 
 func (s *Srv) serveStartGame(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
 	var req struct {
-		RandomAssignment bool `json:"random_assignment"`
+		// RandomAssignment means players without a role get one automatically,
+		// using the AssignmentStrategy.
+		RandomAssignment   bool               `json:"random_assignment"`
+		AssignmentStrategy AssignmentStrategy `json:"assignment_strategy"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return httperr.BadRequest("failed to decode start game request: %w", err)
 	}
 
+	switch req.AssignmentStrategy {
+	case "", RandomAssignment, BalancedAssignment:
+	default:
+		return httperr.
+			BadRequest("unknown assignment strategy %q given", req.AssignmentStrategy).
+			WithMessage("bad assignment strategy")
+	}
+
 	if req.RandomAssignment {
-		modified, err := s.finishAssigningRoles(game, prs)
+		modified, err := s.finishAssigningRoles(game, prs, req.AssignmentStrategy)
 		if err != nil {
 			return err
 		}
@@ -537,7 +1413,13 @@ func (s *Srv) serveStartGame(w http.ResponseWriter, r *http.Request, p *codename
 		}
 	}
 
-	if err := codenames.AllRolesFilled(prs); err != nil {
+	allRolesFilled := func(prs []*codenames.PlayerRole) error {
+		return codenames.AllRolesFilled(prs, game.State.AllTeams()...)
+	}
+	if game.State.Mode == codenames.DuetMode {
+		allRolesFilled = codenames.AllDuetRolesFilled
+	}
+	if err := allRolesFilled(prs); err != nil {
 		return httperr.
 			BadRequest("user %q tried to start game %q, but not all roles are filled: %w", p.ID, game.ID, err).
 			WithMessage(fmt.Sprintf("can't start game yet: %v", err))
@@ -552,7 +1434,23 @@ func (s *Srv) serveStartGame(w http.ResponseWriter, r *http.Request, p *codename
 	}
 	game.Status = codenames.Playing
 
-	players, err := s.toPlayers(prs)
+	// The clock starts for the first spymaster now.
+	if game.State.Timers != nil {
+		resetDeadline(game.State)
+		if err := s.db.UpdateState(game.ID, game.State); err != nil {
+			return httperr.
+				Internal("failed to update state for game %q: %w", game.ID, err).
+				WithMessage("failed to update game state")
+		}
+	}
+
+	s.recordEvent(game.ID, &codenames.Event{
+		Type:     codenames.EventGameStarted,
+		PlayerID: p.ID,
+		Team:     game.State.StartingTeam,
+	})
+
+	players, err := s.toPlayers(game.ID, prs)
 	if err != nil {
 		return httperr.
 			Internal("failed to convert players in game %q: %w", game.ID, err).
@@ -570,22 +1468,39 @@ func (s *Srv) serveStartGame(w http.ResponseWriter, r *http.Request, p *codename
 			WithMessage("failed to send game start message")
 	}
 
+	if err := s.startTurnTimer(game.ID, game.State); err != nil {
+		return err
+	}
+
 	return jsonResp(w, struct {
 		Success bool `json:"success"`
 	}{true})
 }
 
-func (s *Srv) finishAssigningRoles(game *codenames.Game, prs []*codenames.PlayerRole) (bool, error) {
-	if len(prs) < 4 {
+func (s *Srv) finishAssigningRoles(game *codenames.Game, prs []*codenames.PlayerRole, strategy AssignmentStrategy) (bool, error) {
+	// Each team needs a spymaster and an operative, except in Duet, which only
+	// needs someone on each side, since everyone gives clues and guesses.
+	teams := game.State.AllTeams()
+	minPlayers := 2 * len(teams)
+	if game.State.Mode == codenames.DuetMode {
+		minPlayers = 2
+	}
+	numPlayers := 0
+	for _, pr := range prs {
+		if !pr.Role.IsSpectator() {
+			numPlayers++
+		}
+	}
+	if numPlayers < minPlayers {
 		return false, httperr.
-			BadRequest("only have %d players, need four to start a game", len(prs)).
-			WithMessage("you need at least four players to start")
+			BadRequest("only have %d players, need %d to start a game", numPlayers, minPlayers).
+			WithMessage(fmt.Sprintf("you need at least %d players to start", minPlayers))
 	}
 
-	// Start by marking both spymaster positions available.
-	availableSpymasterPos := map[codenames.Team]bool{
-		codenames.BlueTeam: true,
-		codenames.RedTeam:  true,
+	// Start by marking all spymaster positions available.
+	availableSpymasterPos := make(map[codenames.Team]bool)
+	for _, team := range teams {
+		availableSpymasterPos[team] = true
 	}
 
 	// Now, find all the users without roles, and mark taking roles as such.
@@ -608,65 +1523,90 @@ func (s *Srv) finishAssigningRoles(game *codenames.Game, prs []*codenames.Player
 		unassigned[i], unassigned[j] = unassigned[j], unassigned[i]
 	})
 
-	attemptAssignSpymaster := func(pr *codenames.PlayerRole) (bool, error) {
-		for _, team := range []codenames.Team{codenames.RedTeam, codenames.BlueTeam} {
-			if !availableSpymasterPos[team] {
-				continue
-			}
-
-			// Assign this player to the spymaster role, since it's available.
-			if err := s.db.AssignRole(game.ID, &codenames.PlayerRole{
-				PlayerID: pr.PlayerID,
-				Team:     team,
-				Role:     codenames.SpymasterRole,
-			}); err != nil {
-				return false, httperr.
-					Internal("failed to assign %+v to %s spymaster: %w", pr.PlayerID, team, err).
-					WithMessage("failed to randomly assign players")
-			}
-			availableSpymasterPos[team] = false
-			return true, nil
+	var assignments []*codenames.PlayerRole
+	switch strategy {
+	case BalancedAssignment:
+		var ids []codenames.PlayerID
+		for _, pr := range prs {
+			ids = append(ids, pr.PlayerID)
 		}
-		return false, nil
-	}
-
-	for i, pr := range unassigned {
-		// First, try to assign to spymaster roles.
-		assigned, err := attemptAssignSpymaster(pr)
+		records, err := s.db.PlayerRecords(ids)
 		if err != nil {
-			return false, err
-		}
-		if assigned {
-			continue
-		}
-
-		// If there are no spymaster positions open, pick an operative team and
-		// assign them.
-		team := codenames.RedTeam
-		if i%2 == 1 {
-			team = codenames.BlueTeam
+			return false, httperr.
+				Internal("failed to load player records for game %q: %w", game.ID, err).
+				WithMessage("failed to load player history")
 		}
+		assignments = balancedAssignments(teams, prs, unassigned, availableSpymasterPos, records)
+	default:
+		assignments = randomAssignments(teams, unassigned, availableSpymasterPos)
+	}
 
-		if err := s.db.AssignRole(game.ID, &codenames.PlayerRole{
-			PlayerID: pr.PlayerID,
-			Team:     team,
-			Role:     codenames.OperativeRole,
-		}); err != nil {
+	for _, pr := range assignments {
+		if err := s.db.AssignRole(game.ID, pr); err != nil {
 			return false, httperr.
-				Internal("failed to assign %+v to %s operative: %w", pr.PlayerID, team, err).
+				Internal("failed to assign %+v to %s %s: %w", pr.PlayerID, pr.Team, pr.Role, err).
 				WithMessage("failed to randomly assign players")
 		}
+		s.recordEvent(game.ID, &codenames.Event{
+			Type:     codenames.EventRoleAssigned,
+			PlayerID: pr.PlayerID,
+			Team:     pr.Team,
+			Role:     pr.Role,
+		})
 	}
 
 	return len(unassigned) > 0, nil
 }
 
-func (s *Srv) toPlayers(prs []*codenames.PlayerRole) ([]*Player, error) {
+// lobbyPlayers loads everyone that's currently in the game, for letting
+// everyone know that the lobby changed.
+func (s *Srv) lobbyPlayers(gID codenames.GameID) ([]*Player, error) {
+	prs, err := s.db.PlayersInGame(gID)
+	if err != nil {
+		return nil, httperr.
+			Internal("failed to load players in game %q: %w", gID, err).
+			WithMessage("failed to load players in game")
+	}
+
+	players, err := s.toPlayers(gID, prs)
+	if err != nil {
+		return nil, httperr.
+			Internal("failed to convert players in game %q: %w", gID, err).
+			WithMessage("failed to make players")
+	}
+	return players, nil
+}
+
+// sendPlayerJoined lets everyone in the game know that the given player just
+// joined.
+func (s *Srv) sendPlayerJoined(gID codenames.GameID, pID codenames.PlayerID) error {
+	players, err := s.lobbyPlayers(gID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.hub.ToGame(gID, &PlayerJoined{
+		Player:  findPlayer(pID, players),
+		Players: players,
+	}); err != nil {
+		return httperr.
+			Internal("failed to send player joined for game %q: %w", gID, err).
+			WithMessage("failed to inform players of new player")
+	}
+	return nil
+}
+
+func (s *Srv) toPlayers(gID codenames.GameID, prs []*codenames.PlayerRole) ([]*Player, error) {
 	var ids []codenames.PlayerID
 	for _, pr := range prs {
 		ids = append(ids, pr.PlayerID)
 	}
 
+	online := make(map[codenames.PlayerID]bool)
+	for _, pID := range s.hub.Online(gID) {
+		online[pID] = true
+	}
+
 	names, err := s.db.BatchPlayerNames(ids)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load player names: %w", err)
@@ -683,6 +1623,7 @@ func (s *Srv) toPlayers(prs []*codenames.PlayerRole) ([]*Player, error) {
 			Name:     name,
 			Team:     pr.Team,
 			Role:     pr.Role,
+			Online:   online[pr.PlayerID],
 		})
 	}
 
@@ -690,10 +1631,15 @@ func (s *Srv) toPlayers(prs []*codenames.PlayerRole) ([]*Player, error) {
 }
 
 func (s *Srv) broadcastMessage(game *codenames.Game, prs []*codenames.PlayerRole, fn func(*codenames.Game) interface{}) error {
-	// First, send the full board to the spymasters.
+	if game.State.Mode == codenames.DuetMode {
+		return s.broadcastDuetMessage(game, prs, fn)
+	}
+
+	// First, send the full board to the spymasters, and any spectators that
+	// get to watch like one.
 	fullMsg := fn(game)
 	for _, pr := range prs {
-		if pr.Role != codenames.SpymasterRole {
+		if pr.Role != codenames.SpymasterRole && !spymasterView(game, pr) {
 			continue
 		}
 
@@ -707,7 +1653,10 @@ func (s *Srv) broadcastMessage(game *codenames.Game, prs []*codenames.PlayerRole
 	game.State.Board = codenames.Revealed(game.State.Board)
 	operativeMsg := fn(game)
 	for _, pr := range prs {
-		if pr.Role != codenames.OperativeRole {
+		if pr.Role != codenames.OperativeRole && !pr.Role.IsSpectator() {
+			continue
+		}
+		if spymasterView(game, pr) {
 			continue
 		}
 
@@ -719,10 +1668,48 @@ func (s *Srv) broadcastMessage(game *codenames.Game, prs []*codenames.PlayerRole
 	return nil
 }
 
+// broadcastDuetMessage is broadcastMessage for Duet games, where each side
+// sees their own key, regardless of role.
+func (s *Srv) broadcastDuetMessage(game *codenames.Game, prs []*codenames.PlayerRole, fn func(*codenames.Game) interface{}) error {
+	full := game.State.Board
+	msgs := make(map[codenames.Team]interface{})
+	var fullMsg interface{}
+	for _, pr := range prs {
+		// Spectators that get to watch like a spymaster see both keys.
+		if spymasterView(game, pr) {
+			if fullMsg == nil {
+				game.State.Board = full
+				fullMsg = fn(game)
+			}
+			if err := s.hub.ToPlayer(game.ID, pr.PlayerID, fullMsg); err != nil {
+				return fmt.Errorf("failed to send spectator msg: %w", err)
+			}
+			continue
+		}
+
+		msg, ok := msgs[pr.Team]
+		if !ok {
+			game.State.Board = codenames.KeyView(full, pr.Team)
+			msg = fn(game)
+			msgs[pr.Team] = msg
+		}
+
+		if err := s.hub.ToPlayer(game.ID, pr.PlayerID, msg); err != nil {
+			return fmt.Errorf("failed to send %q msg: %w", pr.Team, err)
+		}
+	}
+
+	// Leave the game with the same redacted board that broadcastMessage does.
+	game.State.Board = codenames.Revealed(full)
+
+	return nil
+}
+
 func (s *Srv) serveClue(w http.ResponseWriter, r *http.Request, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
 	var req struct {
-		Word  string `json:"word"`
-		Count int    `json:"count"`
+		Word      string `json:"word"`
+		Count     int    `json:"count"`
+		Unlimited bool   `json:"unlimited"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -730,16 +1717,23 @@ func (s *Srv) serveClue(w http.ResponseWriter, r *http.Request, p *codenames.Pla
 	}
 
 	clue := &codenames.Clue{
-		Word:  req.Word,
-		Count: req.Count,
+		Word:      req.Word,
+		Count:     req.Count,
+		Unlimited: req.Unlimited,
 	}
 	// We don't need to check if the status changed/game is over, because giving
 	// a clue will never end the game.
-	newState, newStatus, err := game.NewForMove(g.State).Move(&game.Move{
+	newState, newStatus, err := game.NewForMove(g.State, s.moveConfig()).Move(&game.Move{
 		Action:   game.ActionGiveClue,
 		Team:     userPR.Team,
 		GiveClue: clue,
 	})
+	var icErr *game.InvalidClueError
+	if errors.As(err, &icErr) {
+		return httperr.
+			BadRequest("player %q in game %q gave illegal clue: %w", p.ID, g.ID, err).
+			WithMessage(icErr.Error())
+	}
 	if err != nil {
 		// We assume the error is the result of a bad request.
 		return httperr.
@@ -747,6 +1741,9 @@ func (s *Srv) serveClue(w http.ResponseWriter, r *http.Request, p *codenames.Pla
 			WithMessage(fmt.Sprintf("failed to make move: %v", err))
 	}
 
+	// The spymaster is done, now the operatives are on the clock.
+	resetDeadline(newState)
+
 	// Update the state in the database.
 	if err := s.db.UpdateState(g.ID, newState); err != nil {
 		return httperr.
@@ -756,6 +1753,13 @@ func (s *Srv) serveClue(w http.ResponseWriter, r *http.Request, p *codenames.Pla
 	g.State = newState
 	g.Status = newStatus
 
+	s.recordEvent(g.ID, &codenames.Event{
+		Type:     codenames.EventClueGiven,
+		PlayerID: p.ID,
+		Team:     userPR.Team,
+		Clue:     clue,
+	})
+
 	// Send the clue down to everyone.
 	if err := s.broadcastMessage(g, prs, func(g *codenames.Game) interface{} {
 		return &ClueGiven{
@@ -769,15 +1773,20 @@ func (s *Srv) serveClue(w http.ResponseWriter, r *http.Request, p *codenames.Pla
 			WithMessage("failed to inform players of clue")
 	}
 
+	if err := s.startTurnTimer(g.ID, newState); err != nil {
+		return err
+	}
+
 	return jsonResp(w, struct {
 		Success bool `json:"success"`
 	}{true})
 }
 
-func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
-	// Since we record votes and calculate consensus before making the move, we
-	// need to independently validate moves first.
-	if userPR.Team != g.State.ActiveTeam {
+// checkCanVote validates that the given player is allowed to vote on a guess
+// or pass right now. Since we record votes and calculate consensus before
+// making the move, we need to independently validate moves first.
+func checkCanVote(p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole) error {
+	if userPR.Team != g.State.GuessingTeam() {
 		return httperr.
 			BadRequest("player %q of team %q tried to guess when %q %q was active in game %q", p.ID, userPR.Team, g.State.ActiveTeam, g.State.ActiveRole, g.ID).
 			WithMessage("it's not your team's turn")
@@ -789,6 +1798,20 @@ func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Pl
 			WithMessage("it's not time to guess")
 	}
 
+	return nil
+}
+
+// moveConfig returns the configuration for making moves in any game on this
+// server.
+func (s *Srv) moveConfig() *game.Config {
+	return &game.Config{ClueValidator: s.clueValidator}
+}
+
+func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	if err := checkCanVote(p, g, userPR); err != nil {
+		return err
+	}
+
 	var req struct {
 		Guess     string `json:"guess"`
 		Confirmed bool   `json:"confirmed"`
@@ -798,8 +1821,7 @@ func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Pl
 		return httperr.BadRequest("failed to decode guess request: %w", err)
 	}
 
-	card, ok := findCard(g.State.Board.Cards, req.Guess)
-	if !ok {
+	if _, ok := findCard(g.State.Board.Cards, req.Guess); !ok {
 		return httperr.
 			BadRequest("player %q guessed %q, which didn't correspond to a card in game %q", p.ID, req.Guess, g.ID).
 			WithMessage(fmt.Sprintf("guess %q didn't correspond to a card", req.Guess))
@@ -815,29 +1837,88 @@ func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Pl
 			WithMessage("failed to inform players of vote")
 	}
 
+	s.recordEvent(g.ID, &codenames.Event{
+		Type:      codenames.EventVote,
+		PlayerID:  p.ID,
+		Team:      userPR.Team,
+		Guess:     req.Guess,
+		Confirmed: req.Confirmed,
+	})
+
 	if !req.Confirmed {
 		// If it's not confirmed (e.g. it's just tentative), so we shouldn't count
 		// the votes.
 		return nil
 	}
 
-	guess, hasConsensus := s.consensus.RecordVote(g.ID, p.ID, req.Guess, countVoters(prs, g.State.ActiveTeam))
+	guess, hasConsensus := consensus.RecordVote(g.State, p.ID, req.Guess, countVoters(prs, g.State))
 	if !hasConsensus {
-		return nil
+		return s.saveVotes(g)
+	}
+
+	return s.handleConsensus(w, p, g, userPR, prs, guess)
+}
+
+func (s *Srv) servePass(w http.ResponseWriter, r *http.Request, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	if err := checkCanVote(p, g, userPR); err != nil {
+		return err
+	}
+
+	if err := s.hub.ToGame(g.ID, &PlayerVote{
+		PlayerID:  p.ID,
+		Confirmed: true,
+		Pass:      true,
+	}); err != nil {
+		return httperr.
+			Internal("failed to send player vote for game %q: %w", g.ID, err).
+			WithMessage("failed to inform players of vote")
+	}
+
+	s.recordEvent(g.ID, &codenames.Event{
+		Type:      codenames.EventVote,
+		PlayerID:  p.ID,
+		Team:      userPR.Team,
+		Guess:     consensus.Pass,
+		Confirmed: true,
+	})
+
+	word, hasConsensus := consensus.RecordVote(g.State, p.ID, consensus.Pass, countVoters(prs, g.State))
+	if !hasConsensus {
+		return s.saveVotes(g)
+	}
+
+	return s.handleConsensus(w, p, g, userPR, prs, word)
+}
+
+// saveVotes saves the votes cast so far this turn with the rest of the game's
+// state, so they still count if the game's next request goes to another
+// server.
+func (s *Srv) saveVotes(g *codenames.Game) error {
+	if err := s.db.UpdateState(g.ID, g.State); err != nil {
+		return httperr.
+			Internal("failed to save votes for game %q: %w", g.ID, err).
+			WithMessage("failed to record vote")
+	}
+	return nil
+}
+
+// handleConsensus makes the move that a team has agreed on, which is either a
+// guess or a pass.
+func (s *Srv) handleConsensus(w http.ResponseWriter, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole, guess string) error {
+	if guess == consensus.Pass {
+		return s.passTurn(w, p, g, userPR, prs)
 	}
 
-	if card, ok = findCard(g.State.Board.Cards, guess); !ok {
+	if _, ok := findCard(g.State.Board.Cards, guess); !ok {
 		// This should probably never happen because if we have consensus, it
 		// should be *this* vote that caused it, but because HTTP requests are
 		// asynchronous, it could theoretically happen.
 		return httperr.
-			BadRequest("team %q guessed %q, which didn't correspond to a card in game %q", userPR.Team, req.Guess, g.ID).
+			BadRequest("team %q guessed %q, which didn't correspond to a card in game %q", userPR.Team, guess, g.ID).
 			WithMessage(fmt.Sprintf("guess %q didn't correspond to a card", guess))
 	}
 
-	gfm := game.NewForMove(g.State)
-
-	newState, newStatus, err := gfm.Move(&game.Move{
+	newState, newStatus, err := game.NewForMove(g.State, s.moveConfig()).Move(&game.Move{
 		Action: game.ActionGuess,
 		Team:   userPR.Team,
 		Guess:  guess,
@@ -849,14 +1930,22 @@ func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Pl
 			WithMessage(fmt.Sprintf("failed to make move: %v", err))
 	}
 
-	if card, ok = findCard(newState.Board.Cards, guess); !ok {
+	card, ok := findCard(newState.Board.Cards, guess)
+	if !ok {
 		return httperr.
 			Internal("guess %q somehow no longer exists in the cards of game %q", guess, g.ID).
 			WithMessage(fmt.Sprintf("guess %q didn't correspond to a card", guess))
 	}
 
 	// They've made the guess, clear out the consensus for the next time.
-	s.consensus.Clear(g.ID)
+	consensus.Clear(newState)
+
+	// Players can keep guessing if the game tells us its still their turn.
+	canKeepGuessing := newState.ActiveRole == codenames.OperativeRole && newStatus != codenames.Finished
+	if !canKeepGuessing {
+		// The turn is over, so the next spymaster is on the clock.
+		resetDeadline(newState)
+	}
 
 	// Update the state in the database.
 	if err := s.db.UpdateState(g.ID, newState); err != nil {
@@ -864,9 +1953,16 @@ func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Pl
 			Internal("failed to update state for game %q: %w", g.ID, err).
 			WithMessage("failed to update game state")
 	}
+	g.State = newState
+	g.Status = newStatus
+
+	s.recordEvent(g.ID, &codenames.Event{
+		Type:  codenames.EventGuess,
+		Team:  userPR.Team,
+		Guess: guess,
+		Card:  card,
+	})
 
-	// Players can keep guessing if the game tells us its still their turn.
-	canKeepGuessing := newState.ActiveRole == codenames.OperativeRole && newStatus != codenames.Finished
 	if err := s.broadcastMessage(g, prs, func(g *codenames.Game) interface{} {
 		return &GuessGiven{
 			Guess:           guess,
@@ -881,9 +1977,35 @@ func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Pl
 			WithMessage("failed to inform players of guess")
 	}
 
-	if newStatus != codenames.Finished {
-		return nil
+	switch {
+	case newStatus == codenames.Finished:
+		if err := s.endGame(g.ID); err != nil {
+			return err
+		}
+	case !canKeepGuessing:
+		if err := s.startTurnTimer(g.ID, newState); err != nil {
+			return err
+		}
+	}
+
+	return jsonResp(w, struct {
+		Success bool `json:"success"`
+	}{true})
+}
+
+// endGame records that the given game has finished, and lets everyone know.
+func (s *Srv) endGame(gID codenames.GameID) error {
+	s.timers.stop(gID)
+
+	// Load the game back up, because broadcasting redacts the board, and we
+	// need the whole thing to figure out how the game ended.
+	g, err := s.db.Game(gID)
+	if err != nil {
+		return httperr.
+			Internal("failed to load finished game %q: %w", gID, err).
+			WithMessage("failed to load game")
 	}
+	gfm := game.NewForMove(g.State, s.moveConfig())
 
 	over, winningTeam := gfm.GameOver()
 	if !over {
@@ -892,31 +2014,164 @@ func (s *Srv) serveGuess(w http.ResponseWriter, r *http.Request, p *codenames.Pl
 			WithMessage("error with game state")
 	}
 
+	s.recordEvent(g.ID, &codenames.Event{
+		Type:   codenames.EventGameEnded,
+		Winner: winningTeam,
+	})
+
+	history, err := s.db.GameHistory(g.ID)
+	if err != nil {
+		return httperr.
+			Internal("failed to load history for game %q: %w", g.ID, err).
+			WithMessage("failed to load game history")
+	}
+	outcome := gfm.Outcome(history)
+
+	if err := s.db.FinishGame(g.ID, winningTeam, outcome); err != nil {
+		return httperr.
+			Internal("failed to finish game %q: %w", g.ID, err).
+			WithMessage("failed to finish game")
+	}
+
+	// Duet games are cooperative, so there's nobody to be rated against.
+	if g.State.Mode != codenames.DuetMode {
+		if err := s.updateRatings(g.ID, winningTeam); err != nil {
+			return err
+		}
+	}
+
+	// Load it once more to get the finished game info, everyone gets to see
+	// the whole board now that the game is over.
+	finished, err := s.db.Game(g.ID)
+	if err != nil {
+		return httperr.
+			Internal("failed to load finished game %q: %w", g.ID, err).
+			WithMessage("failed to load game")
+	}
+
 	// The game is over, we should let folks know.
 	if err := s.hub.ToGame(g.ID, &GameEnd{
 		WinningTeam: winningTeam,
+		Game:        finished,
+		Outcome:     outcome,
 	}); err != nil {
 		return httperr.
 			Internal("failed to send game over for game %q: %w", g.ID, err).
 			WithMessage("failed to inform players of game over")
 	}
 
+	return nil
+}
+
+func (s *Srv) passTurn(w http.ResponseWriter, p *codenames.Player, g *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	newState, newStatus, err := game.NewForMove(g.State, s.moveConfig()).Move(&game.Move{
+		Action: game.ActionPass,
+		Team:   userPR.Team,
+	})
+	if err != nil {
+		return httperr.
+			BadRequest("player %q/team %q in game %q couldn't pass: %w", p.ID, userPR.Team, g.ID, err).
+			WithMessage(fmt.Sprintf("failed to make move: %v", err))
+	}
+
+	// They've passed, clear out the consensus for the next time.
+	consensus.Clear(newState)
+
+	// The next spymaster is on the clock.
+	resetDeadline(newState)
+
+	if err := s.db.UpdateState(g.ID, newState); err != nil {
+		return httperr.
+			Internal("failed to update state for game %q: %w", g.ID, err).
+			WithMessage("failed to update game state")
+	}
+	g.State = newState
+	g.Status = newStatus
+
+	s.recordEvent(g.ID, &codenames.Event{
+		Type: codenames.EventPass,
+		Team: userPR.Team,
+	})
+
+	if err := s.broadcastMessage(g, prs, func(g *codenames.Game) interface{} {
+		return &TurnPassed{
+			Team: userPR.Team,
+			Game: g,
+		}
+	}); err != nil {
+		return httperr.
+			Internal("failed to send pass for game %q: %w", g.ID, err).
+			WithMessage("failed to inform players of pass")
+	}
+
+	// In Duet, passing uses up a turn, which can end the game.
+	if newStatus == codenames.Finished {
+		if err := s.endGame(g.ID); err != nil {
+			return err
+		}
+	} else if err := s.startTurnTimer(g.ID, newState); err != nil {
+		return err
+	}
+
 	return jsonResp(w, struct {
 		Success bool `json:"success"`
 	}{true})
 }
 
-func countVoters(prs []*codenames.PlayerRole, team codenames.Team) int {
+// countVoters returns the number of players that need to agree on a guess.
+// In Duet, spymasters guess for the other side's clues too.
+func countVoters(prs []*codenames.PlayerRole, gs *codenames.GameState) int {
 	cnt := 0
 	for _, pr := range prs {
-		if pr.Role == codenames.OperativeRole && pr.Team == team {
+		if pr.Team != gs.GuessingTeam() {
+			continue
+		}
+		if pr.Role == codenames.OperativeRole || gs.Mode == codenames.DuetMode {
 			cnt++
 		}
 	}
 	return cnt
 }
 
+// recordEvent timestamps the given event and appends it to the game's history.
+// It's called once the action has already been saved, so failing the request
+// would tell the player it didn't happen when it did. Instead, failures are
+// logged, and the history will be missing the event.
+func (s *Srv) recordEvent(gID codenames.GameID, ev *codenames.Event) {
+	ev.Timestamp = time.Now()
+	if err := s.db.RecordEvent(gID, ev); err != nil {
+		log.Printf("failed to record %q event for game %q: %v", ev.Type, gID, err)
+	}
+}
+
+// presenceMsg is what the hub sends everyone in a game when a player connects
+// or disconnects.
+func presenceMsg(gID codenames.GameID, pID codenames.PlayerID, online bool) interface{} {
+	if online {
+		return &PlayerOnline{PlayerID: pID}
+	}
+	return &PlayerOffline{PlayerID: pID}
+}
+
 func (s *Srv) serveData(w http.ResponseWriter, r *http.Request, p *codenames.Player, game *codenames.Game, userPR *codenames.PlayerRole, prs []*codenames.PlayerRole) error {
+	// Clients reconnecting after their connection dropped pass the epoch and
+	// sequence number of the last message they got, so we can send them what
+	// they missed.
+	var (
+		epoch  = r.URL.Query().Get("epoch")
+		since  uint64
+		replay bool
+	)
+	if ss := r.URL.Query().Get("since"); ss != "" {
+		var err error
+		if since, err = strconv.ParseUint(ss, 10, 64); err != nil {
+			return httperr.
+				BadRequest("WebSocket request had invalid since %q: %w", ss, err).
+				WithMessage("invalid since")
+		}
+		replay = true
+	}
+
 	conn, err := s.ws.Upgrade(w, r, nil)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -925,7 +2180,11 @@ func (s *Srv) serveData(w http.ResponseWriter, r *http.Request, p *codenames.Pla
 			WithMessage("failed to connect")
 	}
 
-	s.hub.Register(conn, game.ID, p.ID)
+	if replay {
+		s.hub.Reconnect(conn, game.ID, p.ID, epoch, since, s.wsHandler(r, game.ID))
+	} else {
+		s.hub.Register(conn, game.ID, p.ID, s.wsHandler(r, game.ID))
+	}
 
 	return nil
 }
@@ -1058,10 +2317,29 @@ func isRole(r codenames.Role) gameAuthOption {
 	}
 }
 
+// locksGame holds the game's lock while the handler runs, which handlers that
+// change the game's state need, so they don't race with each other or with
+// the turn timer.
+func locksGame() gameAuthOption {
+	return func(opts *gameAuthOptions) {
+		opts.locksGame = true
+	}
+}
+
+// anyRoleInDuet relaxes the role requirement for Duet games, where every player
+// both gives clues and guesses, whatever role they were assigned.
+func anyRoleInDuet() gameAuthOption {
+	return func(opts *gameAuthOptions) {
+		opts.anyRoleInDuet = true
+	}
+}
+
 type gameAuthOptions struct {
 	isGameCreator  bool
 	wantRole       codenames.Role
+	anyRoleInDuet  bool
 	wantGameStatus codenames.GameStatus
+	locksGame      bool
 }
 
 func (s *Srv) requireGameAuth(handler gameHandler, opts ...gameAuthOption) handlerFunc {
@@ -1076,6 +2354,11 @@ func (s *Srv) requireGameAuth(handler gameHandler, opts ...gameAuthOption) handl
 			return err
 		}
 
+		if gOpts.locksGame {
+			unlock := s.locks.lock(gID)
+			defer unlock()
+		}
+
 		p, err := s.loadPlayerRequired(r)
 		if err != nil {
 			return err
@@ -1113,6 +2396,17 @@ func (s *Srv) requireGameAuth(handler gameHandler, opts ...gameAuthOption) handl
 				Forbidden("player %q is not in game %q", p.ID, gID).
 				WithMessage("you need to join this game first")
 		}
+		if ok && gOpts.wantRole != codenames.NoRole && userPR.Role.IsSpectator() {
+			return httperr.
+				Forbidden("spectator %q tried to play in game %q", p.ID, gID).
+				WithMessage("spectators can't play")
+		}
+		anyRole := gOpts.anyRoleInDuet && game.State.Mode == codenames.DuetMode
+		if ok && gOpts.wantRole != codenames.NoRole && userPR.Role != gOpts.wantRole && !anyRole {
+			return httperr.
+				Forbidden("player %q is a %q in game %q, but only a %q can do that", p.ID, userPR.Role, gID, gOpts.wantRole).
+				WithMessage("your role can't do that")
+		}
 
 		return handler(w, r, p, game, userPR, prs)
 	}
@@ -1127,6 +2421,15 @@ func findRole(pID codenames.PlayerID, prs []*codenames.PlayerRole) (*codenames.P
 	return nil, false
 }
 
+func findPlayer(pID codenames.PlayerID, players []*Player) *Player {
+	for _, p := range players {
+		if p.PlayerID == pID {
+			return p
+		}
+	}
+	return nil
+}
+
 func (s *Srv) gameIDFromRequest(r *http.Request) (codenames.GameID, error) {
 	vars := mux.Vars(r)
 	id, ok := vars["id"]
@@ -1147,3 +2450,12 @@ func findCard(cards []codenames.Card, guess string) (*codenames.Card, bool) {
 	}
 	return nil, false
 }
+
+func isPlaying(teams []codenames.Team, team codenames.Team) bool {
+	for _, t := range teams {
+		if t == team {
+			return true
+		}
+	}
+	return false
+}
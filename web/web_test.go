@@ -1,6 +1,7 @@
 package web
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -8,14 +9,22 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bcspragu/Codenames/codenames"
+	"github.com/bcspragu/Codenames/game"
+	"github.com/bcspragu/Codenames/httperr"
 	"github.com/bcspragu/Codenames/memdb"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/securecookie"
+	"github.com/gorilla/websocket"
 )
 
 func TestBasicallyEverything(t *testing.T) {
@@ -48,6 +57,7 @@ func TestBasicallyEverything(t *testing.T) {
 		CreatedBy: "user_1",
 		Status:    codenames.Pending,
 		State: &codenames.GameState{
+			Mode:         codenames.ClassicMode,
 			ActiveTeam:   codenames.BlueTeam,
 			ActiveRole:   codenames.SpymasterRole,
 			Board:        &codenames.Board{Cards: startingBoardCards()},
@@ -127,6 +137,1858 @@ func TestBasicallyEverything(t *testing.T) {
 
 	// Have the game creator start the game.
 	env.startGame(t, gID, 1)
+
+	// Every role assignment and the start should have made it into the history.
+	gotHistory, err := env.db.GameHistory(gID)
+	if err != nil {
+		t.Fatalf("failed to load history for game %q: %v", gID, err)
+	}
+	roleEvent := func(idx int, role codenames.Role, team codenames.Team) *codenames.Event {
+		return &codenames.Event{
+			Type:     codenames.EventRoleAssigned,
+			PlayerID: human(codenames.UserID(fmt.Sprintf("user_%d", idx))),
+			Team:     team,
+			Role:     role,
+		}
+	}
+	wantHistory := []*codenames.Event{
+		roleEvent(0, codenames.SpymasterRole, codenames.BlueTeam),
+		roleEvent(1, codenames.SpymasterRole, codenames.RedTeam),
+		roleEvent(2, codenames.OperativeRole, codenames.BlueTeam),
+		roleEvent(3, codenames.OperativeRole, codenames.RedTeam),
+		&codenames.Event{
+			Type:     codenames.EventGameStarted,
+			PlayerID: human("user_1"),
+			Team:     codenames.BlueTeam,
+		},
+	}
+	if diff := cmp.Diff(wantHistory, gotHistory, cmpopts.IgnoreFields(codenames.Event{}, "Timestamp")); diff != "" {
+		t.Errorf("unexpected game history (-want +got)\n%s", diff)
+	}
+
+	// Blue goes first, have them give a clue and guess one of their cards.
+	clue := &codenames.Clue{Word: "medicine", Count: 2}
+	env.giveClue(t, gID, 0 /* blue spymaster */, clue)
+	env.guess(t, gID, 2 /* blue operative */, "doctor")
+
+	// The spymaster sees the full board at each step, the operatives only see
+	// what has been revealed.
+	guessedBoard := &codenames.Board{Cards: startingBoardCards()}
+	guessedBoard.Cards[2].Revealed = true
+	guessedBoard.Cards[2].RevealedBy = codenames.BlueTeam
+	wantSpymasterSteps := []*HistoryStep{
+		{
+			Event: &codenames.Event{
+				Type:     codenames.EventClueGiven,
+				PlayerID: human("user_0"),
+				Team:     codenames.BlueTeam,
+				Clue:     clue,
+			},
+			Board: &codenames.Board{Cards: startingBoardCards()},
+		},
+		{
+			Event: &codenames.Event{
+				Type:  codenames.EventGuess,
+				Team:  codenames.BlueTeam,
+				Guess: "doctor",
+				Card: &codenames.Card{
+					Codename:   "doctor",
+					Agent:      codenames.BlueAgent,
+					Revealed:   true,
+					RevealedBy: codenames.BlueTeam,
+				},
+			},
+			Board: guessedBoard,
+		},
+	}
+	gotSpymasterSteps := env.history(t, gID, 0)
+	if diff := cmp.Diff(wantSpymasterSteps, gotSpymasterSteps, cmpopts.IgnoreFields(codenames.Event{}, "Timestamp")); diff != "" {
+		t.Errorf("unexpected spymaster history (-want +got)\n%s", diff)
+	}
+
+	// The steps are the same for the operatives, just with the colors hidden.
+	wantOperativeSteps := wantSpymasterSteps
+	for _, step := range wantOperativeSteps {
+		step.Board = codenames.Revealed(step.Board)
+	}
+	gotOperativeSteps := env.history(t, gID, 3)
+	if diff := cmp.Diff(wantOperativeSteps, gotOperativeSteps, cmpopts.IgnoreFields(codenames.Event{}, "Timestamp")); diff != "" {
+		t.Errorf("unexpected operative history (-want +got)\n%s", diff)
+	}
+
+	// Blue still has a guess left, but they'd rather stop while they're ahead.
+	env.pass(t, gID, 2 /* blue operative */)
+
+	gotGame, err = env.db.Game(gID)
+	if err != nil {
+		t.Fatalf("failed to load game %q: %v", gID, err)
+	}
+	if gotGame.State.ActiveTeam != codenames.RedTeam || gotGame.State.ActiveRole != codenames.SpymasterRole {
+		t.Errorf("after passing, %q %q was active, want %q %q", gotGame.State.ActiveTeam, gotGame.State.ActiveRole, codenames.RedTeam, codenames.SpymasterRole)
+	}
+
+	// Red gives a clue, and promptly guesses the assassin, ending the game.
+	env.giveClue(t, gID, 1 /* red spymaster */, &codenames.Clue{Word: "sailor", Count: 1})
+	env.guess(t, gID, 3 /* red operative */, "button")
+
+	// Now that it's over, everyone gets to see the full board, and who won.
+	gotGame = env.game(t, gID, 3 /* red operative */)
+	if gotGame.Status != codenames.Finished {
+		t.Errorf("game status was %q, want %q", gotGame.Status, codenames.Finished)
+	}
+	if gotGame.Winner != codenames.BlueTeam {
+		t.Errorf("game winner was %q, want %q", gotGame.Winner, codenames.BlueTeam)
+	}
+	if gotGame.FinishedAt == nil {
+		t.Error("game had no finish time")
+	}
+	if gotGame.Outcome == nil || !gotGame.Outcome.Teams[codenames.RedTeam].AssassinHit {
+		t.Errorf("expected red to have hit the assassin, got outcome %+v", gotGame.Outcome)
+	}
+	if agent := gotGame.State.Board.Cards[0].Agent; agent != codenames.Bystander {
+		t.Errorf("unrevealed card had agent %q after game ended, want %q", agent, codenames.Bystander)
+	}
+}
+
+func TestDuet(t *testing.T) {
+	env := setup()
+
+	env.createUser(t, "Red")
+	env.createUser(t, "Blue")
+
+	gID := env.createGameWithMode(t, 0, codenames.DuetMode)
+	env.joinGame(t, gID, 0)
+	env.joinGame(t, gID, 1)
+	env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_1", codenames.SpymasterRole, codenames.BlueTeam)
+	env.startGame(t, gID, 0)
+
+	authIdx := map[codenames.Team]int{
+		codenames.RedTeam:  0,
+		codenames.BlueTeam: 1,
+	}
+
+	full, err := env.db.Game(gID)
+	if err != nil {
+		t.Fatalf("failed to load game %q: %v", gID, err)
+	}
+	if full.State.Mode != codenames.DuetMode || full.State.TurnsLeft != game.DuetTurns {
+		t.Fatalf("game was in mode %q with %d turns, want %q with %d", full.State.Mode, full.State.TurnsLeft, codenames.DuetMode, game.DuetTurns)
+	}
+	clueGiver := full.State.ActiveTeam
+	guesser := codenames.OtherTeam(clueGiver)
+	key := full.State.Board.Keys[clueGiver]
+
+	// Each side only gets to see their own key.
+	view := env.game(t, gID, authIdx[guesser])
+	if len(view.State.Board.Keys) != 1 || view.State.Board.Keys[guesser] == nil {
+		t.Errorf("%q player got keys %+v, want only their own", guesser, view.State.Board.Keys)
+	}
+
+	find := func(agent codenames.Agent) string {
+		for i, ag := range key {
+			if ag == agent {
+				return full.State.Board.Cards[i].Codename
+			}
+		}
+		t.Fatalf("no %q on the %q key", agent, clueGiver)
+		return ""
+	}
+
+	// The clue giver's partner does the guessing, and passing uses up a turn.
+	env.giveClue(t, gID, authIdx[clueGiver], &codenames.Clue{Word: "thing", Count: 1})
+	env.guess(t, gID, authIdx[guesser], find(codenames.Bystander))
+
+	gotGame, err := env.db.Game(gID)
+	if err != nil {
+		t.Fatalf("failed to load game %q: %v", gID, err)
+	}
+	if gotGame.State.ActiveTeam != guesser || gotGame.State.TurnsLeft != game.DuetTurns-1 {
+		t.Errorf("after a bystander, %q was active with %d turns left, want %q with %d", gotGame.State.ActiveTeam, gotGame.State.TurnsLeft, guesser, game.DuetTurns-1)
+	}
+
+	// Now the other side gives a clue, and their partner finds an assassin.
+	clueGiver, guesser = guesser, clueGiver
+	key = full.State.Board.Keys[clueGiver]
+	env.giveClue(t, gID, authIdx[clueGiver], &codenames.Clue{Word: "thing", Count: 1})
+	env.guess(t, gID, authIdx[guesser], find(codenames.Assassin))
+
+	gotGame = env.game(t, gID, authIdx[guesser])
+	if gotGame.Status != codenames.Finished || gotGame.Winner != codenames.NoTeam {
+		t.Errorf("game was %q with winner %q, want %q with no winner", gotGame.Status, gotGame.Winner, codenames.Finished)
+	}
+}
+
+func TestThreeTeams(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red 1", "Red 2", "Blue 1", "Blue 2", "Green 1", "Green 2"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGameWithTeams(t, 0, codenames.ClassicMode, 3)
+	for i := 0; i < 6; i++ {
+		env.joinGame(t, gID, i)
+	}
+	teams := []codenames.Team{codenames.RedTeam, codenames.BlueTeam, codenames.GreenTeam}
+	for i, team := range teams {
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 2*i), codenames.SpymasterRole, team)
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 2*i+1), codenames.OperativeRole, team)
+	}
+	env.startGame(t, gID, 0)
+
+	g := env.game(t, gID, 0)
+	if diff := cmp.Diff(codenames.ThreeTeams, g.State.Teams); diff != "" {
+		t.Errorf("unexpected teams (-want +got)\n%s", diff)
+	}
+
+	got := make(map[codenames.Agent]int)
+	for _, card := range g.State.Board.Cards {
+		got[card.Agent]++
+	}
+	if got[codenames.GreenAgent] < 6 {
+		t.Errorf("board had %d green agents, want at least 6", got[codenames.GreenAgent])
+	}
+
+	// Teams take turns in order, starting with whoever was picked to go first.
+	start := 0
+	for i, team := range teams {
+		if team == g.State.StartingTeam {
+			start = i
+		}
+	}
+	for i := 0; i < len(teams); i++ {
+		idx := (start + i) % len(teams)
+		if active := env.game(t, gID, 0).State.ActiveTeam; active != teams[idx] {
+			t.Fatalf("active team was %q, want %q", active, teams[idx])
+		}
+		env.giveClue(t, gID, 2*idx, &codenames.Clue{Word: "thing", Count: 1})
+		env.pass(t, gID, 2*idx+1)
+	}
+	if active := env.game(t, gID, 0).State.ActiveTeam; active != g.State.StartingTeam {
+		t.Errorf("active team was %q, want %q", active, g.State.StartingTeam)
+	}
+}
+
+func TestBoardSpec(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red 1", "Red 2", "Blue 1", "Blue 2"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGameWithReq(t, 0, &createGameReq{BoardSpec: codenames.QuickBoard})
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, gID, i)
+	}
+	env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, gID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+	env.startGame(t, gID, 0)
+
+	g := env.game(t, gID, 0)
+	if diff := cmp.Diff(codenames.QuickBoard, g.State.Spec); diff != "" {
+		t.Errorf("unexpected board spec (-want +got)\n%s", diff)
+	}
+	if n := len(g.State.Board.Cards); n != 16 {
+		t.Fatalf("board had %d cards, want 16", n)
+	}
+
+	got := make(map[codenames.Agent]int)
+	for _, card := range g.State.Board.Cards {
+		got[card.Agent]++
+	}
+	if diff := cmp.Diff(codenames.QuickBoard.Counts(codenames.DefaultTeams, g.State.StartingTeam), got); diff != "" {
+		t.Errorf("unexpected agents (-want +got)\n%s", diff)
+	}
+
+	// Specs that don't add up are rejected.
+	bad := &codenames.BoardSpec{Rows: 4, Columns: 4, Agents: 8, StarterAgents: 1, Bystanders: 7, Assassins: 1}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game", toBody(t, &createGameReq{BoardSpec: bad}))
+	env.addAuth(r, 0)
+	if err := env.srv.serveCreateGame(w, r); err == nil {
+		t.Error("creating a game with a bad board spec succeeded")
+	}
+
+	// Preset boards can be asked for by name.
+	gID = env.createGameWithReq(t, 0, &createGameReq{Board: "DEEP"})
+	g, err := env.db.Game(gID)
+	if err != nil {
+		t.Fatalf("failed to load game %q: %v", gID, err)
+	}
+	if diff := cmp.Diff(codenames.DeepBoard, g.State.Spec); diff != "" {
+		t.Errorf("unexpected board spec for DEEP board (-want +got)\n%s", diff)
+	}
+	if n := len(g.State.Board.Cards); n != 36 {
+		t.Errorf("DEEP board had %d cards, want 36", n)
+	}
+
+	for _, req := range []*createGameReq{
+		{Board: "HUGE"},
+		{Board: "QUICK", BoardSpec: codenames.QuickBoard},
+		{Board: "QUICK", Mode: codenames.DuetMode},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/game", toBody(t, req))
+		env.addAuth(r, 0)
+		if err := env.srv.serveCreateGame(w, r); err == nil {
+			t.Errorf("creating a game with %+v succeeded", req)
+		}
+	}
+}
+
+func TestTurnTimers(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red 1", "Red 2", "Blue 1", "Blue 2"} {
+		env.createUser(t, name)
+	}
+
+	timers := &codenames.TurnTimers{SpymasterSecs: 60, OperativeSecs: 120}
+	gID := env.createGameWithReq(t, 0, &createGameReq{Timers: timers})
+	defer env.srv.timers.stop(gID)
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, gID, i)
+	}
+	env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, gID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+
+	before := time.Now()
+	env.startGame(t, gID, 0)
+
+	g := env.game(t, gID, 0)
+	if g.State.Deadline == nil {
+		t.Fatal("no deadline was set for the first spymaster")
+	}
+	if d := g.State.Deadline.Sub(before); d < time.Minute || d > 2*time.Minute {
+		t.Errorf("first spymaster got %s, want about a minute", d)
+	}
+	if _, ok := env.srv.timers.timers[gID]; !ok {
+		t.Error("no timer was scheduled for the game")
+	}
+
+	// The first spymaster runs out of time, so the other team goes.
+	first := g.State.ActiveTeam
+	if err := env.srv.expireTurn(gID, *g.State.Deadline); err != nil {
+		t.Fatalf("expireTurn: %v", err)
+	}
+	expired := env.game(t, gID, 0)
+	if expired.State.ActiveTeam == first || expired.State.ActiveRole != codenames.SpymasterRole {
+		t.Errorf("after expiring, %q %q was active, want the other spymaster", expired.State.ActiveTeam, expired.State.ActiveRole)
+	}
+	if expired.State.Deadline == nil || !expired.State.Deadline.After(*g.State.Deadline) {
+		t.Errorf("deadline wasn't reset for the next spymaster, was %v", expired.State.Deadline)
+	}
+
+	// Timers that fire late for a turn that's already over don't do anything.
+	if err := env.srv.expireTurn(gID, *g.State.Deadline); err != nil {
+		t.Fatalf("expireTurn: %v", err)
+	}
+	if active := env.game(t, gID, 0).State.ActiveTeam; active != expired.State.ActiveTeam {
+		t.Errorf("stale timer changed the active team to %q", active)
+	}
+
+	// Other servers leave the timer to the one that claimed it.
+	other := New(env.db, rand.New(rand.NewSource(0)), setupCookies(), nil, WithServerID("other"))
+	defer other.timers.stop(gID)
+	if other.timers.has(gID) {
+		t.Error("another server scheduled a timer that was already claimed")
+	}
+
+	// When the server restarts, the timer is restored right away.
+	restarted := New(env.db, rand.New(rand.NewSource(0)), setupCookies(), nil, WithServerID(env.srv.id))
+	defer restarted.timers.stop(gID)
+	if !restarted.timers.has(gID) {
+		t.Error("no timer was restored for the game")
+	}
+
+	// If the server that claimed a turn goes away, another one takes over once
+	// the claim lapses, and expires turns that already ran out.
+	g = env.game(t, gID, 0)
+	ranOut := time.Now().Add(-time.Minute).Round(0)
+	g.State.Deadline = &ranOut
+	if err := env.db.UpdateState(gID, g.State); err != nil {
+		t.Fatalf("UpdateState: %v", err)
+	}
+	if _, err := env.db.ClaimTurnTimer(gID, "gone", ranOut, ranOut.Add(turnClaimGrace)); err != nil {
+		t.Fatalf("ClaimTurnTimer: %v", err)
+	}
+	if err := other.resumeTurnTimers(); err != nil {
+		t.Fatalf("resumeTurnTimers: %v", err)
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if d := env.game(t, gID, 0).State.Deadline; d != nil && d.After(ranOut) {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("turn that ran out was never expired")
+		}
+	}
+}
+
+func TestTurnTimerFired(t *testing.T) {
+	tt := newTurnTimers()
+	gID := codenames.GameID("game")
+	defer tt.stop(gID)
+
+	fired := make(chan struct{}, 1)
+	fn := func() { fired <- struct{}{} }
+	wait := func() {
+		t.Helper()
+		select {
+		case <-fired:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timer never fired")
+		}
+	}
+
+	tt.setIfUnset(gID, time.Now(), fn)
+	wait()
+
+	// Once a timer has fired, the game's timer can be resumed again.
+	tt.mu.Lock()
+	_, ok := tt.timers[gID]
+	tt.mu.Unlock()
+	if ok {
+		t.Error("timer that fired is still set for the game")
+	}
+	tt.setIfUnset(gID, time.Now(), fn)
+	wait()
+}
+
+func TestTurnTimerRace(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red 1", "Red 2", "Blue 1", "Blue 2"} {
+		env.createUser(t, name)
+	}
+
+	for i := 0; i < 20; i++ {
+		gID := env.createGameWithReq(t, 0, &createGameReq{Timers: &codenames.TurnTimers{SpymasterSecs: 60}})
+		for i := 0; i < 4; i++ {
+			env.joinGame(t, gID, i)
+		}
+		env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+		env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+		env.assignRole(t, gID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+		env.assignRole(t, gID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+		env.startGame(t, gID, 0)
+		env.srv.timers.stop(gID)
+
+		g := env.game(t, gID, 0)
+		first, spymaster := g.State.ActiveTeam, 0
+		if first == codenames.BlueTeam {
+			spymaster = 2
+		}
+
+		// The spymaster gives their clue just as their time runs out.
+		var (
+			wg     sync.WaitGroup
+			clueOK bool
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := env.srv.expireTurn(gID, *g.State.Deadline); err != nil {
+				t.Errorf("expireTurn: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/clue", toBody(t, &codenames.Clue{Word: "thing", Count: 1}))
+			r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+			env.addAuth(r, spymaster)
+			handler := env.srv.requireGameAuth(env.srv.serveClue, isSpymaster(), anyRoleInDuet(), isGamePlaying(), locksGame())
+			clueOK = handler(w, r) == nil
+		}()
+		wg.Wait()
+		env.srv.timers.stop(gID)
+
+		// Whichever happened first wins, but a clue that went through is never
+		// undone by the timer.
+		got := env.game(t, gID, 0).State
+		if clueOK && (got.ActiveTeam != first || got.ActiveRole != codenames.OperativeRole) {
+			t.Errorf("clue was accepted, but %q %q is active, want %q operatives", got.ActiveTeam, got.ActiveRole, first)
+		}
+		if !clueOK && (got.ActiveTeam == first || got.ActiveRole != codenames.SpymasterRole) {
+			t.Errorf("turn expired, but %q %q is active, want the other spymaster", got.ActiveTeam, got.ActiveRole)
+		}
+	}
+}
+
+func TestRequiredRoles(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red 1", "Red 2", "Blue 1", "Blue 2"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, gID, i)
+	}
+	env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, gID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+	env.startGame(t, gID, 0)
+
+	g, err := env.db.Game(gID)
+	if err != nil {
+		t.Fatalf("failed to load game %q: %v", gID, err)
+	}
+	spymaster, operative := 0, 1
+	if g.State.ActiveTeam == codenames.BlueTeam {
+		spymaster, operative = 2, 3
+	}
+
+	do := func(action string, authIdx int, body interface{}, handler handlerFunc) error {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/"+action, toBody(t, body))
+		r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+		env.addAuth(r, authIdx)
+		return handler(w, r)
+	}
+
+	clue := &codenames.Clue{Word: "thing", Count: 1}
+	clueHandler := env.srv.requireGameAuth(env.srv.serveClue, isSpymaster(), anyRoleInDuet(), isGamePlaying(), locksGame())
+	if err := do("clue", operative, clue, clueHandler); err == nil {
+		t.Error("operative was allowed to give a clue")
+	}
+	env.giveClue(t, gID, spymaster, clue)
+
+	guess := struct {
+		Guess     string `json:"guess"`
+		Confirmed bool   `json:"confirmed"`
+	}{g.State.Board.Cards[0].Codename, true}
+	guessHandler := env.srv.requireGameAuth(env.srv.serveGuess, isOperative(), anyRoleInDuet(), isGamePlaying(), locksGame())
+	if err := do("guess", spymaster, guess, guessHandler); err == nil {
+		t.Error("spymaster was allowed to guess")
+	}
+	passHandler := env.srv.requireGameAuth(env.srv.servePass, isOperative(), anyRoleInDuet(), isGamePlaying(), locksGame())
+	if err := do("pass", spymaster, nil, passHandler); err == nil {
+		t.Error("spymaster was allowed to pass")
+	}
+	env.pass(t, gID, operative)
+}
+
+func TestSpectators(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red 1", "Red 2", "Blue 1", "Blue 2", "Watcher", "Peeker"} {
+		env.createUser(t, name)
+	}
+
+	hidden := func(g *codenames.Game) bool {
+		for _, card := range g.State.Board.Cards {
+			if !card.Revealed && card.Agent != codenames.UnknownAgent {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, allow := range []bool{false, true} {
+		gID := env.createGameWithReq(t, 0, &createGameReq{AllowSpymasterSpectators: allow})
+		for i := 0; i < 4; i++ {
+			env.joinGame(t, gID, i)
+		}
+		env.spectate(t, gID, 4, false)
+		env.spectate(t, gID, 5, true)
+		env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+		env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+		env.assignRole(t, gID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+		env.assignRole(t, gID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+		env.startGame(t, gID, 0)
+
+		if !hidden(env.game(t, gID, 4)) {
+			t.Errorf("spectator could see unrevealed cards")
+		}
+		if got := hidden(env.game(t, gID, 5)); got == allow {
+			t.Errorf("spymaster view spectator saw hidden board = %t, with allow_spymaster_spectators = %t", got, allow)
+		}
+
+		// Spectators can't take part in the game.
+		req := struct {
+			Guess     string `json:"guess"`
+			Confirmed bool   `json:"confirmed"`
+		}{"ship", true}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/guess", toBody(t, req))
+		r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+		env.addAuth(r, 4)
+		handler := env.srv.requireGameAuth(env.srv.serveGuess, isOperative(), anyRoleInDuet(), isGamePlaying(), locksGame())
+		if err := handler(w, r); err == nil {
+			t.Error("spectator was allowed to guess")
+		}
+
+		// Players can't become spectators once they have a role.
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/spectate", nil)
+		r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+		env.addAuth(r, 1)
+		if err := env.srv.requireGameAuth(env.srv.serveSpectate)(w, r); err == nil {
+			t.Error("operative was allowed to become a spectator")
+		}
+	}
+
+	// Spectators don't count towards the players needed for random assignment.
+	gID := env.createGame(t, 0)
+	env.joinGame(t, gID, 1)
+	env.joinGame(t, gID, 2)
+	env.spectate(t, gID, 4, false)
+
+	req := struct {
+		RandomAssignment bool `json:"random_assignment"`
+	}{true}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/start", toBody(t, req))
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, 0)
+	handler := env.srv.requireGameAuth(env.srv.serveStartGame, isGameCreator(), isGamePending(), locksGame())
+	if err := handler(w, r); err == nil {
+		t.Error("game started with three players and a spectator")
+	}
+}
+
+func TestLeaveAndKick(t *testing.T) {
+	env := setup()
+	ts := httptest.NewServer(env.srv)
+	defer ts.Close()
+
+	for _, name := range []string{"Creator", "Player 1", "Player 2", "Ghost", "Leaver"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	for i := 0; i < 5; i++ {
+		env.joinGame(t, gID, i)
+	}
+
+	ghostConn := env.connect(t, ts, gID, 3)
+	defer ghostConn.Close()
+	ghostEvents := env.events(t, ts, gID, 3, "")
+	defer ghostEvents.Close()
+	env.readEvent(t, ghostEvents, "CONNECTED", nil)
+	conn := env.connect(t, ts, gID, 1)
+	defer conn.Close()
+
+	env.leave(t, gID, 4)
+	if err := env.kick(gID, 1, "user_3"); err == nil {
+		t.Error("non-creator was allowed to kick a player")
+	}
+	if err := env.kick(gID, 0, "user_3"); err != nil {
+		t.Fatalf("failed to kick player: %v", err)
+	}
+
+	// The kicked player hears about it, and then their connections are closed,
+	// so they don't get anything sent to the game after that.
+	env.readMsg(t, ghostConn, "PLAYER_LEFT", nil)
+	env.readMsg(t, ghostConn, "PLAYER_KICKED", nil)
+	env.readEvent(t, ghostEvents, "PLAYER_LEFT", nil)
+	env.readEvent(t, ghostEvents, "PLAYER_KICKED", nil)
+	if err := env.srv.hub.ToGame(gID, map[string]string{"action": "AFTER_KICK"}); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	env.readMsg(t, conn, "PLAYER_LEFT", nil)
+	env.readMsg(t, conn, "PLAYER_KICKED", nil)
+	env.readMsg(t, conn, "AFTER_KICK", nil)
+
+	ghostConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, dat, err := ghostConn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("kicked player's WebSocket wasn't closed: %v", err)
+		}
+		if strings.Contains(string(dat), "AFTER_KICK") {
+			t.Fatalf("kicked player's WebSocket got a message after the kick: %s", dat)
+		}
+	}
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case ev, ok := <-ghostEvents.events:
+			if !ok {
+				done = true
+			} else if strings.Contains(string(ev.data), "AFTER_KICK") {
+				t.Fatalf("kicked player's event stream got a message after the kick: %s", ev.data)
+			}
+		case <-timeout:
+			t.Fatal("kicked player's event stream wasn't closed")
+		}
+	}
+
+	var got []string
+	for _, p := range env.players(t, gID, 0) {
+		got = append(got, p.PlayerID.ID)
+	}
+	if diff := cmp.Diff([]string{"user_0", "user_1", "user_2"}, got); diff != "" {
+		t.Errorf("unexpected players after leaving and kicking (-want +got)\n%s", diff)
+	}
+
+	env.transferOwner(t, gID, 0, "user_1")
+	if creator := env.game(t, gID, 0).CreatedBy; creator != "user_1" {
+		t.Errorf("game creator was %q after transfer, want %q", creator, "user_1")
+	}
+
+	// When the creator leaves, the next person in the game takes over.
+	env.leave(t, gID, 1)
+	if creator := env.game(t, gID, 0).CreatedBy; creator != "user_0" {
+		t.Errorf("game creator was %q after creator left, want %q", creator, "user_0")
+	}
+}
+
+func TestLeaveMidGuess(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red Spy", "Red 1", "Red 2", "Blue Spy", "Blue 1", "Blue 2"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	for i := 0; i < 6; i++ {
+		env.joinGame(t, gID, i)
+	}
+	for i, team := range []codenames.Team{codenames.RedTeam, codenames.BlueTeam} {
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 3*i), codenames.SpymasterRole, team)
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 3*i+1), codenames.OperativeRole, team)
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 3*i+2), codenames.OperativeRole, team)
+	}
+	env.startGame(t, gID, 0)
+
+	spymaster := 0
+	g := env.game(t, gID, spymaster)
+	if g.State.ActiveTeam == codenames.BlueTeam {
+		spymaster = 3
+		g = env.game(t, gID, spymaster)
+	}
+	var word string
+	for _, card := range g.State.Board.Cards {
+		if card.Agent == codenames.AgentForTeam(g.State.ActiveTeam) {
+			word = card.Codename
+			break
+		}
+	}
+
+	env.giveClue(t, gID, spymaster, &codenames.Clue{Word: "thing", Count: 2})
+	// One of two operatives voting isn't a majority.
+	env.guess(t, gID, spymaster+1, word)
+	card, _ := findCard(env.game(t, gID, 0).State.Board.Cards, word)
+	if card.Revealed {
+		t.Fatalf("card %q was revealed with only half the team voting", word)
+	}
+
+	// But once the other operative leaves, it is.
+	env.leave(t, gID, spymaster+2)
+	card, _ = findCard(env.game(t, gID, 0).State.Board.Cards, word)
+	if !card.Revealed {
+		t.Errorf("card %q wasn't revealed after the only other voter left", word)
+	}
+}
+
+func TestVotesAcrossServers(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red Spy", "Red 1", "Red 2", "Blue Spy", "Blue 1", "Blue 2"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	for i := 0; i < 6; i++ {
+		env.joinGame(t, gID, i)
+	}
+	for i, team := range []codenames.Team{codenames.RedTeam, codenames.BlueTeam} {
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 3*i), codenames.SpymasterRole, team)
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 3*i+1), codenames.OperativeRole, team)
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 3*i+2), codenames.OperativeRole, team)
+	}
+	env.startGame(t, gID, 0)
+
+	spymaster := 0
+	g := env.game(t, gID, spymaster)
+	if g.State.ActiveTeam == codenames.BlueTeam {
+		spymaster = 3
+		g = env.game(t, gID, spymaster)
+	}
+	var word string
+	for _, card := range g.State.Board.Cards {
+		if card.Agent == codenames.AgentForTeam(g.State.ActiveTeam) {
+			word = card.Codename
+			break
+		}
+	}
+
+	env.giveClue(t, gID, spymaster, &codenames.Clue{Word: "thing", Count: 2})
+	env.guess(t, gID, spymaster+1, word)
+	want := []*codenames.Vote{{PlayerID: human(env.user(t, spymaster+1).ID), Word: word}}
+	if diff := cmp.Diff(want, env.game(t, gID, 0).State.Votes); diff != "" {
+		t.Errorf("unexpected votes (-want +got)\n%s", diff)
+	}
+
+	// The game moves to another server, e.g. during a deploy, and the first
+	// vote still counts there.
+	env.srv = New(env.db, rand.New(rand.NewSource(0)), setupCookies(), nil)
+	env.guess(t, gID, spymaster+2, word)
+	g = env.game(t, gID, 0)
+	if card, _ := findCard(g.State.Board.Cards, word); !card.Revealed {
+		t.Errorf("card %q wasn't revealed after both operatives voted for it on different servers", word)
+	}
+	if len(g.State.Votes) != 0 {
+		t.Errorf("votes weren't cleared after the guess, got %d", len(g.State.Votes))
+	}
+}
+
+func TestRematch(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red Spy", "Red Op", "Blue Spy", "Blue Op", "Watcher"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, gID, i)
+	}
+	env.spectate(t, gID, 4, false)
+	env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, gID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+	env.startGame(t, gID, 0)
+
+	// End the game quickly by having the starting team find the assassin.
+	g := env.game(t, gID, 0)
+	spymaster := 0
+	if g.State.ActiveTeam == codenames.BlueTeam {
+		spymaster = 2
+	}
+	var assassin string
+	for _, card := range g.State.Board.Cards {
+		if card.Agent == codenames.Assassin {
+			assassin = card.Codename
+		}
+	}
+	env.giveClue(t, gID, spymaster, &codenames.Clue{Word: "oops", Count: 1})
+	env.guess(t, gID, spymaster+1, assassin)
+
+	rematchID := env.rematch(t, gID, 1, true /* alternate starter */)
+	if again := env.rematch(t, gID, 3, true /* alternate starter */); again != rematchID {
+		t.Errorf("second rematch request created game %q, want existing rematch %q", again, rematchID)
+	}
+
+	if got := env.game(t, gID, 0).RematchID; got != rematchID {
+		t.Errorf("finished game had rematch %q, want %q", got, rematchID)
+	}
+	rm := env.game(t, rematchID, 0)
+	if rm.RematchOf != gID {
+		t.Errorf("rematch was a rematch of %q, want %q", rm.RematchOf, gID)
+	}
+	if rm.Status != codenames.Pending {
+		t.Errorf("rematch had status %q, want %q", rm.Status, codenames.Pending)
+	}
+	if want := codenames.OtherTeam(g.State.StartingTeam); rm.State.StartingTeam != want {
+		t.Errorf("rematch starting team was %q, want %q", rm.State.StartingTeam, want)
+	}
+
+	// Everyone who played comes along, but spectators don't.
+	var want []*Player
+	for _, p := range env.players(t, gID, 0) {
+		if !p.Role.IsSpectator() {
+			want = append(want, p)
+		}
+	}
+	opts := cmpopts.SortSlices(func(a, b *Player) bool { return a.PlayerID.ID < b.PlayerID.ID })
+	if diff := cmp.Diff(want, env.players(t, rematchID, 0), opts); diff != "" {
+		t.Errorf("rematch had different players (-want +got)\n%s", diff)
+	}
+
+	// Everyone already has their roles, so the rematch can start right away.
+	env.startGame(t, rematchID, 0)
+}
+
+func TestRatings(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red Spy", "Red Op", "Blue Spy", "Blue Op"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, gID, i)
+	}
+	env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, gID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+
+	// Nobody is rated until they've finished a game.
+	if got := env.leaderboard(t, "OPERATIVE"); len(got) != 0 {
+		t.Errorf("leaderboard had %d players before any games finished, want none", len(got))
+	}
+
+	env.startGame(t, gID, 0)
+
+	// The starting team finds the assassin, so the other team wins.
+	g := env.game(t, gID, 0)
+	spymaster, winner := 0, codenames.BlueTeam
+	if g.State.ActiveTeam == codenames.BlueTeam {
+		spymaster, winner = 2, codenames.RedTeam
+	}
+	var assassin string
+	for _, card := range g.State.Board.Cards {
+		if card.Agent == codenames.Assassin {
+			assassin = card.Codename
+		}
+	}
+	env.giveClue(t, gID, spymaster, &codenames.Clue{Word: "oops", Count: 1})
+	env.guess(t, gID, spymaster+1, assassin)
+
+	winningSpy, losingSpy := 2, 0
+	if winner == codenames.RedTeam {
+		winningSpy, losingSpy = losingSpy, winningSpy
+	}
+	want := []*RatedPlayer{
+		{
+			Name: env.user(t, winningSpy).Name,
+			Rating: &codenames.Rating{
+				PlayerID: human(env.user(t, winningSpy).ID),
+				Role:     codenames.SpymasterRole,
+				Rating:   1516,
+				Games:    1,
+				Wins:     1,
+			},
+		},
+		{
+			Name: env.user(t, losingSpy).Name,
+			Rating: &codenames.Rating{
+				PlayerID: human(env.user(t, losingSpy).ID),
+				Role:     codenames.SpymasterRole,
+				Rating:   1484,
+				Games:    1,
+			},
+		},
+	}
+	if diff := cmp.Diff(want, env.leaderboard(t, "SPYMASTER")); diff != "" {
+		t.Errorf("unexpected spymaster leaderboard (-want +got)\n%s", diff)
+	}
+
+	// The losing spymaster hasn't played as an operative, so they're still at
+	// the initial rating.
+	stats := env.stats(t, string(env.user(t, losingSpy).ID))
+	if stats.Spymaster.Rating != 1484 || stats.Operative.Rating != codenames.InitialRating {
+		t.Errorf("got spymaster, operative ratings %v, %v, want 1484, %v", stats.Spymaster.Rating, stats.Operative.Rating, codenames.InitialRating)
+	}
+	if stats.Record.Games != 1 || stats.Record.Wins != 0 {
+		t.Errorf("got record of %d wins in %d games, want 0 in 1", stats.Record.Wins, stats.Record.Games)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/user/nobody/stats", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "nobody"})
+	if code, _ := httperr.Extract(env.srv.serveUserStats(w, r)); code != http.StatusNotFound {
+		t.Errorf("stats for unknown user had status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestUserGames(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Red Spy", "Red Op", "Blue Spy", "Blue Op"} {
+		env.createUser(t, name)
+	}
+
+	finishedID := env.createGame(t, 0)
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, finishedID, i)
+	}
+	env.assignRole(t, finishedID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, finishedID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, finishedID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, finishedID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+	env.startGame(t, finishedID, 0)
+
+	// The starting team finds the assassin with their first guess.
+	g := env.game(t, finishedID, 0)
+	spymaster := 0
+	if g.State.ActiveTeam == codenames.BlueTeam {
+		spymaster = 2
+	}
+	var assassin string
+	for _, card := range g.State.Board.Cards {
+		if card.Agent == codenames.Assassin {
+			assassin = card.Codename
+		}
+	}
+	env.giveClue(t, finishedID, spymaster, &codenames.Clue{Word: "oops", Count: 1})
+	env.guess(t, finishedID, spymaster+1, assassin)
+
+	pendingID := env.createGame(t, spymaster)
+	env.joinGame(t, pendingID, spymaster)
+
+	// Pending games come before finished ones.
+	page := env.userGames(t, spymaster, 1, 0)
+	if len(page.Games) != 1 || page.Games[0].Game.ID != pendingID {
+		t.Fatalf("first page had games %+v, want just %q", page.Games, pendingID)
+	}
+	if page.Games[0].Role.RoleAssigned {
+		t.Error("player had a role in the pending game, but they were never assigned one")
+	}
+	if page.NextOffset != 1 {
+		t.Errorf("first page had next offset %d, want 1", page.NextOffset)
+	}
+
+	page = env.userGames(t, spymaster, 1, page.NextOffset)
+	if len(page.Games) != 1 || page.Games[0].Game.ID != finishedID {
+		t.Fatalf("second page had games %+v, want just %q", page.Games, finishedID)
+	}
+	if got := page.Games[0].Role.Role; got != codenames.SpymasterRole {
+		t.Errorf("player had role %q in the finished game, want %q", got, codenames.SpymasterRole)
+	}
+	if page.NextOffset != 0 {
+		t.Errorf("last page had next offset %d, want 0", page.NextOffset)
+	}
+
+	wantRecord := &codenames.PlayerRecord{
+		Games:                 1,
+		SpymasterGames:        1,
+		Clues:                 1,
+		Guesses:               1,
+		AverageGuessesPerClue: 1,
+		AssassinHits:          1,
+	}
+	opts := cmpopts.IgnoreFields(codenames.PlayerRecord{}, "LastSpymaster")
+	if diff := cmp.Diff(wantRecord, page.Record, opts); diff != "" {
+		t.Errorf("unexpected record (-want +got)\n%s", diff)
+	}
+}
+
+func TestListGames(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		env.createUser(t, name)
+	}
+
+	// Both teams have a spymaster in the first game.
+	fullID := env.createGame(t, 0)
+	env.joinGame(t, fullID, 0)
+	env.joinGame(t, fullID, 1)
+	env.assignRole(t, fullID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, fullID, 0, "user_1", codenames.SpymasterRole, codenames.BlueTeam)
+
+	// The second game has a robot and a spectator, but no spymasters.
+	openID := env.createGame(t, 1)
+	env.joinGame(t, openID, 1)
+	env.spectate(t, openID, 2, false /* spymaster view */)
+	rID, err := env.db.NewRobot("Robbie")
+	if err != nil {
+		t.Fatalf("failed to create robot: %v", err)
+	}
+	if err := env.db.JoinGame(openID, rID.AsPlayerID()); err != nil {
+		t.Fatalf("failed to add robot to game: %v", err)
+	}
+
+	// Unlisted games never show up.
+	env.createGameWithReq(t, 2, &createGameReq{Unlisted: true})
+
+	gameIDs := func(gl *GameListing) []codenames.GameID {
+		var out []codenames.GameID
+		for _, gs := range gl.Games {
+			out = append(out, gs.ID)
+		}
+		return out
+	}
+
+	all := env.listGames(t, "created_within=5")
+	if diff := cmp.Diff([]codenames.GameID{openID, fullID}, gameIDs(all)); diff != "" {
+		t.Errorf("unexpected games listed (-want +got)\n%s", diff)
+	}
+	if all.NextCursor != "" {
+		t.Errorf("listing everything had next cursor %q, want none", all.NextCursor)
+	}
+
+	want := &GameSummary{
+		ID:             openID,
+		Creator:        &codenames.Player{ID: human("user_1"), Name: "Bob"},
+		Mode:           codenames.ClassicMode,
+		Teams:          codenames.DefaultTeams,
+		NumPlayers:     2,
+		NumSpectators:  1,
+		HasAI:          true,
+		OpenSpymasters: []codenames.Team{codenames.RedTeam, codenames.BlueTeam},
+	}
+	opts := cmpopts.IgnoreFields(GameSummary{}, "CreatedAt", "AgeSecs")
+	if diff := cmp.Diff(want, all.Games[0], opts); diff != "" {
+		t.Errorf("unexpected game summary (-want +got)\n%s", diff)
+	}
+
+	for _, query := range []string{"open_spymaster=true", "has_ai=true"} {
+		got := gameIDs(env.listGames(t, query))
+		if diff := cmp.Diff([]codenames.GameID{openID}, got); diff != "" {
+			t.Errorf("unexpected games listed for %q (-want +got)\n%s", query, diff)
+		}
+	}
+
+	first := env.listGames(t, "limit=1")
+	if diff := cmp.Diff([]codenames.GameID{openID}, gameIDs(first)); diff != "" {
+		t.Errorf("unexpected first page (-want +got)\n%s", diff)
+	}
+	if first.NextCursor == "" {
+		t.Fatal("first page had no cursor for the next page")
+	}
+	second := env.listGames(t, "limit=1&cursor="+first.NextCursor)
+	if diff := cmp.Diff([]codenames.GameID{fullID}, gameIDs(second)); diff != "" {
+		t.Errorf("unexpected second page (-want +got)\n%s", diff)
+	}
+	if second.NextCursor != "" {
+		t.Errorf("last page had next cursor %q, want none", second.NextCursor)
+	}
+
+	// Started games aren't in the lobby anymore.
+	if err := env.db.StartGame(fullID); err != nil {
+		t.Fatalf("failed to start game: %v", err)
+	}
+	if diff := cmp.Diff([]codenames.GameID{openID}, env.pendingGames(t)); diff != "" {
+		t.Errorf("unexpected games listed after starting one (-want +got)\n%s", diff)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/games?cursor=garbage", nil)
+	if code, _ := httperr.Extract(env.srv.serveListGames(w, r)); code != http.StatusBadRequest {
+		t.Errorf("listing games with a bad cursor had status %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestRotateSpymasters(t *testing.T) {
+	pr := func(id string, team codenames.Team, role codenames.Role) *codenames.PlayerRole {
+		return &codenames.PlayerRole{
+			PlayerID:     codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: id},
+			Team:         team,
+			Role:         role,
+			RoleAssigned: role != codenames.NoRole,
+		}
+	}
+
+	prs := []*codenames.PlayerRole{
+		pr("red_1", codenames.RedTeam, codenames.OperativeRole),
+		pr("red_2", codenames.RedTeam, codenames.SpymasterRole),
+		pr("blue_1", codenames.BlueTeam, codenames.SpymasterRole),
+		pr("watcher", codenames.NoTeam, codenames.SpectatorRole),
+		pr("red_3", codenames.RedTeam, codenames.OperativeRole),
+		pr("blue_2", codenames.BlueTeam, codenames.OperativeRole),
+	}
+
+	got := rotateSpymasters(prs, codenames.DefaultTeams)
+	want := []*codenames.PlayerRole{
+		pr("red_1", codenames.RedTeam, codenames.OperativeRole),
+		pr("red_2", codenames.RedTeam, codenames.OperativeRole),
+		pr("blue_1", codenames.BlueTeam, codenames.OperativeRole),
+		pr("watcher", codenames.NoTeam, codenames.SpectatorRole),
+		pr("red_3", codenames.RedTeam, codenames.SpymasterRole),
+		pr("blue_2", codenames.BlueTeam, codenames.SpymasterRole),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected roles after rotating (-want +got)\n%s", diff)
+	}
+
+	// Rotating again wraps around to the start of the team.
+	got = rotateSpymasters(got, codenames.DefaultTeams)
+	if got[0].Role != codenames.SpymasterRole || got[2].Role != codenames.SpymasterRole {
+		t.Errorf("spymasters didn't wrap around, got %q and %q", got[0].Role, got[2].Role)
+	}
+	if prs[1].Role != codenames.SpymasterRole {
+		t.Error("rotateSpymasters modified its input")
+	}
+}
+
+func TestLobbyMessages(t *testing.T) {
+	env := setup()
+	ts := httptest.NewServer(env.srv)
+	defer ts.Close()
+
+	env.createUser(t, "Creator")
+	env.createUser(t, "Joiner")
+
+	gID := env.createGame(t, 0)
+	env.joinGame(t, gID, 0)
+	conn := env.connect(t, ts, gID, 0)
+	defer conn.Close()
+
+	var joined PlayerJoined
+	env.joinGame(t, gID, 1)
+	env.readMsg(t, conn, "PLAYER_JOINED", &joined)
+	if joined.Player == nil || joined.Player.Name != "Joiner" {
+		t.Errorf("PLAYER_JOINED had player %+v, want Joiner", joined.Player)
+	}
+	if len(joined.Players) != 2 {
+		t.Errorf("PLAYER_JOINED had %d players, want 2", len(joined.Players))
+	}
+
+	var assigned RoleAssigned
+	env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.BlueTeam)
+	env.readMsg(t, conn, "ROLE_ASSIGNED", &assigned)
+	if p := assigned.Player; p == nil || p.Team != codenames.BlueTeam || p.Role != codenames.OperativeRole {
+		t.Errorf("ROLE_ASSIGNED had player %+v, want a blue operative", p)
+	}
+
+	var left PlayerLeft
+	env.leave(t, gID, 1)
+	env.readMsg(t, conn, "PLAYER_LEFT", &left)
+	if left.PlayerID.ID != "user_1" {
+		t.Errorf("PLAYER_LEFT was for %q, want %q", left.PlayerID.ID, "user_1")
+	}
+	if len(left.Players) != 1 {
+		t.Errorf("PLAYER_LEFT had %d players, want 1", len(left.Players))
+	}
+}
+
+func TestWSActions(t *testing.T) {
+	env := setup()
+	ts := httptest.NewServer(env.srv)
+	defer ts.Close()
+
+	for _, name := range []string{"Red Spy", "Red Op", "Blue Spy", "Blue Op"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, gID, i)
+	}
+	for i, team := range []codenames.Team{codenames.RedTeam, codenames.BlueTeam} {
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 2*i), codenames.SpymasterRole, team)
+		env.assignRole(t, gID, 0, fmt.Sprintf("user_%d", 2*i+1), codenames.OperativeRole, team)
+	}
+	env.startGame(t, gID, 0)
+
+	spymaster := 0
+	if env.game(t, gID, 0).State.ActiveTeam == codenames.BlueTeam {
+		spymaster = 2
+	}
+	spyConn := env.connect(t, ts, gID, spymaster)
+	defer spyConn.Close()
+	opConn := env.connect(t, ts, gID, spymaster+1)
+	defer opConn.Close()
+
+	send := func(conn *websocket.Conn, req string) {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(req)); err != nil {
+			t.Fatalf("failed to send %s: %v", req, err)
+		}
+	}
+
+	// Operatives can't guess before there's a clue, same as over HTTP.
+	var actionErr ActionError
+	send(opConn, `{"v": 1, "id": "1", "action": "GUESS", "guess": "thing", "confirmed": true}`)
+	env.readMsg(t, opConn, "ERROR", &actionErr)
+	if actionErr.ID != "1" || actionErr.Code != http.StatusBadRequest {
+		t.Errorf("got error %+v for early guess, want a 400 for request 1", actionErr)
+	}
+
+	var (
+		cg  ClueGiven
+		ack Ack
+	)
+	send(spyConn, `{"v": 1, "id": "2", "action": "CLUE", "word": "thing", "count": 1}`)
+	env.readMsg(t, spyConn, "CLUE_GIVEN", &cg)
+	env.readMsg(t, spyConn, "ACK", &ack)
+	if cg.Clue == nil || cg.Clue.Word != "thing" {
+		t.Errorf("CLUE_GIVEN had clue %+v, want %q", cg.Clue, "thing")
+	}
+	if ack.ID != "2" {
+		t.Errorf("ACK was for request %q, want %q", ack.ID, "2")
+	}
+	// Everyone else hears about it too.
+	env.readMsg(t, opConn, "CLUE_GIVEN", nil)
+
+	// Unsupported versions and unknown actions are rejected.
+	for i, req := range []string{
+		`{"v": 2, "id": "3", "action": "PASS"}`,
+		`{"v": 1, "id": "4", "action": "START"}`,
+	} {
+		actionErr = ActionError{}
+		send(opConn, req)
+		env.readMsg(t, opConn, "ERROR", &actionErr)
+		if want := fmt.Sprint(i + 3); actionErr.ID != want || actionErr.Code != http.StatusBadRequest {
+			t.Errorf("got error %+v for %s, want a 400 for request %s", actionErr, req, want)
+		}
+	}
+
+	var chat ChatMessage
+	send(opConn, `{"v": 1, "id": "5", "action": "CHAT", "message": "  hmm  "}`)
+	env.readMsg(t, spyConn, "CHAT", &chat)
+	if chat.Message != "hmm" || chat.Name != "Red Op" && chat.Name != "Blue Op" {
+		t.Errorf("got chat %+v, want %q from an operative", chat, "hmm")
+	}
+	env.readMsg(t, opConn, "CHAT", nil)
+	env.readMsg(t, opConn, "ACK", nil)
+
+	actionErr = ActionError{}
+	send(opConn, `{"v": 1, "id": "6", "action": "CHAT", "message": ""}`)
+	env.readMsg(t, opConn, "ERROR", &actionErr)
+	if actionErr.Code != http.StatusBadRequest {
+		t.Errorf("got error %+v for empty chat, want a 400", actionErr)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	env := setup()
+	ts := httptest.NewServer(env.srv)
+	defer ts.Close()
+
+	for _, name := range []string{"Creator", "Joiner", "Latecomer"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	env.joinGame(t, gID, 0)
+
+	// The first message says where the game's messages are at, which we need
+	// to reconnect.
+	var connected struct {
+		Epoch string `json:"epoch"`
+		Seq   uint64 `json:"seq"`
+	}
+	conn := env.dial(t, ts, fmt.Sprintf("/api/game/%s/ws", gID), 0)
+	env.readMsg(t, conn, "CONNECTED", &connected)
+	if connected.Epoch == "" {
+		t.Fatal("CONNECTED had no epoch")
+	}
+
+	var joined struct {
+		PlayerJoined
+		Seq uint64 `json:"seq"`
+	}
+	env.joinGame(t, gID, 1)
+	env.readMsg(t, conn, "PLAYER_JOINED", &joined)
+	if joined.Seq == 0 {
+		t.Fatal("PLAYER_JOINED had no sequence number")
+	}
+	conn.Close()
+
+	// While we're gone, one player leaves and another joins.
+	env.leave(t, gID, 1)
+	env.joinGame(t, gID, 2)
+
+	conn = env.reconnect(t, ts, gID, 0, connected.Epoch, joined.Seq)
+	defer conn.Close()
+
+	var (
+		left  PlayerLeft
+		late  PlayerJoined
+		after struct {
+			Seq uint64 `json:"seq"`
+		}
+	)
+	env.readMsg(t, conn, "PLAYER_LEFT", &left)
+	if left.PlayerID.ID != "user_1" {
+		t.Errorf("PLAYER_LEFT was for %q, want %q", left.PlayerID.ID, "user_1")
+	}
+	env.readMsg(t, conn, "PLAYER_JOINED", &late)
+	if late.Player == nil || late.Player.Name != "Latecomer" {
+		t.Errorf("PLAYER_JOINED had player %+v, want Latecomer", late.Player)
+	}
+
+	// And new messages keep coming in order.
+	env.leave(t, gID, 2)
+	env.readMsg(t, conn, "PLAYER_LEFT", &after)
+	if after.Seq <= joined.Seq {
+		t.Errorf("new message had sequence number %d, want more than %d", after.Seq, joined.Seq)
+	}
+
+	// If we ask for messages that were never sent, we're told to start over.
+	var resync struct {
+		Seq uint64 `json:"seq"`
+	}
+	badConn := env.reconnect(t, ts, gID, 0, connected.Epoch, after.Seq+100)
+	defer badConn.Close()
+	env.readMsg(t, badConn, "RESYNC", &resync)
+	if resync.Seq < after.Seq {
+		t.Errorf("RESYNC had sequence number %d, want at least %d", resync.Seq, after.Seq)
+	}
+
+	// Same if the sequence number is from somewhere else, like another server.
+	var otherResync struct {
+		Epoch string `json:"epoch"`
+	}
+	otherConn := env.reconnect(t, ts, gID, 0, "some-other-epoch", joined.Seq)
+	defer otherConn.Close()
+	env.readMsg(t, otherConn, "RESYNC", &otherResync)
+	if otherResync.Epoch != connected.Epoch {
+		t.Errorf("RESYNC had epoch %q, want %q", otherResync.Epoch, connected.Epoch)
+	}
+}
+
+func TestPresence(t *testing.T) {
+	env := setup()
+	ts := httptest.NewServer(env.srv)
+	defer ts.Close()
+
+	env.createUser(t, "Creator")
+	env.createUser(t, "Joiner")
+
+	gID := env.createGame(t, 0)
+	env.joinGame(t, gID, 0)
+	env.joinGame(t, gID, 1)
+	for _, p := range env.players(t, gID, 0) {
+		if p.Online {
+			t.Errorf("player %q was online before connecting", p.Name)
+		}
+	}
+
+	conn := env.connect(t, ts, gID, 0)
+	defer conn.Close()
+
+	var online PlayerOnline
+	joinerConn := env.connect(t, ts, gID, 1)
+	env.readMsg(t, conn, "PLAYER_ONLINE", &online)
+	if online.PlayerID.ID != "user_1" {
+		t.Errorf("PLAYER_ONLINE was for %q, want %q", online.PlayerID.ID, "user_1")
+	}
+	env.waitForOnline(t, gID, "user_1", true)
+
+	// Opening a second tab and closing the first doesn't take them offline.
+	secondConn := env.connect(t, ts, gID, 1)
+	joinerConn.Close()
+	env.waitForOnline(t, gID, "user_1", true)
+
+	var offline PlayerOffline
+	secondConn.Close()
+	env.readMsg(t, conn, "PLAYER_OFFLINE", &offline)
+	if offline.PlayerID.ID != "user_1" {
+		t.Errorf("PLAYER_OFFLINE was for %q, want %q", offline.PlayerID.ID, "user_1")
+	}
+	env.waitForOnline(t, gID, "user_1", false)
+	env.waitForOnline(t, gID, "user_0", true)
+}
+
+func TestChat(t *testing.T) {
+	env := setup()
+	ts := httptest.NewServer(env.srv)
+	defer ts.Close()
+
+	const (
+		redSpy = iota
+		redOp
+		redOp2
+		blueSpy
+		blueOp
+		spectator
+	)
+	for _, name := range []string{"Red Spy", "Red Op", "Red Op 2", "Blue Spy", "Blue Op", "Spectator"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, redSpy)
+	for i := redSpy; i <= blueOp; i++ {
+		env.joinGame(t, gID, i)
+	}
+	env.spectate(t, gID, spectator, false)
+	env.assignRole(t, gID, redSpy, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, redSpy, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, redSpy, "user_2", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, redSpy, "user_3", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, gID, redSpy, "user_4", codenames.OperativeRole, codenames.BlueTeam)
+	env.startGame(t, gID, redSpy)
+
+	conns := make(map[int]*websocket.Conn)
+	for _, i := range []int{redSpy, redOp2, blueOp, spectator} {
+		conns[i] = env.connect(t, ts, gID, i)
+		defer conns[i].Close()
+	}
+
+	if err := env.chat(gID, redOp, codenames.ChatScopeTeam, "I think it's the boat"); err != nil {
+		t.Fatalf("failed to send team chat: %v", err)
+	}
+	if err := env.chat(gID, blueSpy, codenames.ChatScopeAll, "good luck"); err != nil {
+		t.Fatalf("failed to send chat: %v", err)
+	}
+
+	// The other red operative sees both, everyone else only sees the message
+	// to the whole game.
+	for i, conn := range conns {
+		var want []string
+		if i == redOp2 {
+			want = append(want, "I think it's the boat")
+		}
+		want = append(want, "good luck")
+
+		for _, w := range want {
+			var cm ChatMessage
+			env.readMsg(t, conn, "CHAT", &cm)
+			if cm.Message != w {
+				t.Errorf("player %d got chat %q, want %q", i, cm.Message, w)
+			}
+		}
+	}
+
+	// Same goes for loading the chat later.
+	if got := env.chatHistory(t, gID, redOp2); len(got) != 2 || got[0].Scope != codenames.ChatScopeTeam || got[0].Name != "Red Op" {
+		t.Errorf("red operative's chat history was %+v, want the team message from Red Op and one more", got)
+	}
+	for _, i := range []int{redSpy, blueOp, spectator} {
+		if got := env.chatHistory(t, gID, i); len(got) != 1 || got[0].Message != "good luck" {
+			t.Errorf("player %d's chat history was %+v, want just the message to everyone", i, got)
+		}
+	}
+
+	// Spymasters and spectators don't get team chat.
+	for _, i := range []int{redSpy, spectator} {
+		code, _ := httperr.Extract(env.chat(gID, i, codenames.ChatScopeTeam, "psst"))
+		if code != http.StatusForbidden {
+			t.Errorf("team chat from player %d got status %d, want %d", i, code, http.StatusForbidden)
+		}
+	}
+
+	// Red Op already sent one message, so they can send a few more before they
+	// get cut off.
+	for i := 1; i < chatRateLimit; i++ {
+		if err := env.chat(gID, redOp, codenames.ChatScopeAll, "spam"); err != nil {
+			t.Fatalf("failed to send message %d: %v", i, err)
+		}
+	}
+	code, _ := httperr.Extract(env.chat(gID, redOp, codenames.ChatScopeAll, "spam"))
+	if code != http.StatusTooManyRequests {
+		t.Errorf("chat over the rate limit got status %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestChatLimiter(t *testing.T) {
+	cl := newChatLimiter()
+	alice := codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: "alice"}
+	bob := codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: "bob"}
+
+	start := time.Now()
+	for i := 0; i < chatRateLimit; i++ {
+		if !cl.allow(alice, start) {
+			t.Fatalf("message %d was rate limited", i)
+		}
+	}
+	if cl.allow(alice, start) {
+		t.Error("message over the limit was allowed")
+	}
+	if !cl.allow(bob, start) {
+		t.Error("other player was rate limited")
+	}
+
+	// Once the window has passed, players who've gone quiet are forgotten.
+	later := start.Add(chatRateWindow)
+	if !cl.allow(alice, later) {
+		t.Error("message after the window was rate limited")
+	}
+	if _, ok := cl.sent[bob]; ok {
+		t.Error("quiet player's messages were never forgotten")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	env := setup()
+	ts := httptest.NewServer(env.srv)
+	defer ts.Close()
+
+	const (
+		redSpy = iota
+		redOp
+		redOp2
+		blueSpy
+		blueOp
+	)
+	for _, name := range []string{"Red Spy", "Red Op", "Red Op 2", "Blue Spy", "Blue Op"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, redSpy)
+	for i := redSpy; i <= blueOp; i++ {
+		env.joinGame(t, gID, i)
+	}
+	env.assignRole(t, gID, redSpy, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, redSpy, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, redSpy, "user_2", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, redSpy, "user_3", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, gID, redSpy, "user_4", codenames.OperativeRole, codenames.BlueTeam)
+	env.startGame(t, gID, redSpy)
+
+	// The greeting is sent once the stream is registered.
+	spyEvents := env.events(t, ts, gID, redSpy, "")
+	env.readEvent(t, spyEvents, "CONNECTED", nil)
+	opEvents := env.events(t, ts, gID, redOp2, "")
+	defer opEvents.Close()
+	env.readEvent(t, opEvents, "CONNECTED", nil)
+
+	if err := env.chat(gID, redOp, codenames.ChatScopeTeam, "I think it's the boat"); err != nil {
+		t.Fatalf("failed to send team chat: %v", err)
+	}
+	if err := env.chat(gID, blueSpy, codenames.ChatScopeAll, "good luck"); err != nil {
+		t.Fatalf("failed to send chat: %v", err)
+	}
+
+	// Streams get the same messages as WebSockets, so only the operative sees
+	// the team chat.
+	for _, want := range []string{"I think it's the boat", "good luck"} {
+		var cm ChatMessage
+		env.readEvent(t, opEvents, "CHAT", &cm)
+		if cm.Message != want {
+			t.Errorf("operative got chat %q, want %q", cm.Message, want)
+		}
+	}
+	var cm ChatMessage
+	lastID := env.readEvent(t, spyEvents, "CHAT", &cm)
+	if cm.Message != "good luck" {
+		t.Errorf("spymaster got chat %q, want %q", cm.Message, "good luck")
+	}
+	if lastID == "" {
+		t.Fatal("CHAT event had no ID")
+	}
+
+	// If the spymaster's stream drops, they get what they missed when they
+	// come back with the last ID they saw.
+	spyEvents.Close()
+	if err := env.chat(gID, blueSpy, codenames.ChatScopeAll, "still there?"); err != nil {
+		t.Fatalf("failed to send chat: %v", err)
+	}
+	spyEvents = env.events(t, ts, gID, redSpy, lastID)
+	defer spyEvents.Close()
+	env.readEvent(t, spyEvents, "CHAT", &cm)
+	if cm.Message != "still there?" {
+		t.Errorf("spymaster got chat %q after reconnecting, want %q", cm.Message, "still there?")
+	}
+
+	// IDs from somewhere else mean starting over.
+	otherEvents := env.events(t, ts, gID, redSpy, "some-other-epoch:3")
+	defer otherEvents.Close()
+	env.readEvent(t, otherEvents, "RESYNC", nil)
+
+	// And made up ones are rejected.
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/game/"+string(gID)+"/events", nil)
+	if err != nil {
+		t.Fatalf("failed to form request: %v", err)
+	}
+	req.Header.Set("Last-Event-ID", "nonsense")
+	env.addAuth(req, redSpy)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to request events: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad Last-Event-ID got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+func TestChooseRole(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Creator", "Picky", "Other", "Late"} {
+		env.createUser(t, name)
+	}
+
+	gID := env.createGame(t, 0)
+	if err := env.chooseRole(gID, 1, codenames.RedTeam, codenames.SpymasterRole); err == nil {
+		t.Error("player picked a role without joining the game")
+	}
+
+	env.joinGame(t, gID, 1)
+	env.joinGame(t, gID, 2)
+	if err := env.chooseRole(gID, 1, codenames.RedTeam, codenames.SpymasterRole); err != nil {
+		t.Fatalf("failed to choose role: %v", err)
+	}
+	if err := env.chooseRole(gID, 2, codenames.RedTeam, codenames.SpymasterRole); err == nil {
+		t.Error("second player was allowed to be red spymaster")
+	}
+	if err := env.chooseRole(gID, 2, codenames.GreenTeam, codenames.OperativeRole); err == nil {
+		t.Error("player was allowed to join a team that isn't playing")
+	}
+
+	// Players can change their minds, which opens up their old role.
+	if err := env.chooseRole(gID, 1, codenames.RedTeam, codenames.OperativeRole); err != nil {
+		t.Fatalf("failed to switch roles: %v", err)
+	}
+	if err := env.chooseRole(gID, 2, codenames.RedTeam, codenames.SpymasterRole); err != nil {
+		t.Fatalf("failed to take open spymaster role: %v", err)
+	}
+
+	got := make(map[string]codenames.Role)
+	for _, p := range env.players(t, gID, 0) {
+		got[p.PlayerID.ID] = p.Role
+	}
+	want := map[string]codenames.Role{
+		"user_1": codenames.OperativeRole,
+		"user_2": codenames.SpymasterRole,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected roles (-want +got)\n%s", diff)
+	}
+
+	// Once teams are locked, only the creator can assign roles.
+	env.lockTeams(t, gID, 0, true)
+	env.joinGame(t, gID, 3)
+	if err := env.chooseRole(gID, 3, codenames.BlueTeam, codenames.SpymasterRole); err == nil {
+		t.Error("player picked a role after teams were locked")
+	}
+	env.assignRole(t, gID, 0, "user_3", codenames.SpymasterRole, codenames.BlueTeam)
+
+	env.lockTeams(t, gID, 0, false)
+	if err := env.chooseRole(gID, 3, codenames.BlueTeam, codenames.OperativeRole); err != nil {
+		t.Errorf("failed to choose role after teams were unlocked: %v", err)
+	}
+}
+
+func TestChooseRoleConcurrently(t *testing.T) {
+	env := setup()
+
+	const n = 8
+	env.createUser(t, "Creator")
+	for i := 1; i <= n; i++ {
+		env.createUser(t, fmt.Sprintf("Picky %d", i))
+	}
+
+	for round := 0; round < 10; round++ {
+		gID := env.createGame(t, 0)
+		for i := 1; i <= n; i++ {
+			env.joinGame(t, gID, i)
+		}
+
+		// Everyone goes for the red spymaster at once, only one gets it.
+		var (
+			wg  sync.WaitGroup
+			mu  sync.Mutex
+			won int
+		)
+		for i := 1; i <= n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := env.chooseRole(gID, i, codenames.RedTeam, codenames.SpymasterRole); err == nil {
+					mu.Lock()
+					won++
+					mu.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if won != 1 {
+			t.Errorf("%d players got the red spymaster role, want 1", won)
+		}
+		spymasters := 0
+		for _, p := range env.players(t, gID, 0) {
+			if p.Team == codenames.RedTeam && p.Role == codenames.SpymasterRole {
+				spymasters++
+			}
+		}
+		if spymasters != 1 {
+			t.Errorf("game had %d red spymasters, want 1", spymasters)
+		}
+	}
+}
+
+func TestBalancedAssignments(t *testing.T) {
+	human := func(id string) codenames.PlayerID {
+		return codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: id}
+	}
+	robot := func(id string) codenames.PlayerID {
+		return codenames.PlayerID{PlayerType: codenames.PlayerTypeRobot, ID: id}
+	}
+	at := func(day int) *time.Time {
+		t := time.Date(2020, time.January, day, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	var unassigned []*codenames.PlayerRole
+	for _, pID := range []codenames.PlayerID{human("h1"), human("h2"), human("h3"), human("h4"), robot("r1"), robot("r2")} {
+		unassigned = append(unassigned, &codenames.PlayerRole{PlayerID: pID})
+	}
+	records := map[codenames.PlayerID]*codenames.PlayerRecord{
+		human("h1"): {Games: 10, Wins: 9, SpymasterGames: 3, LastSpymaster: at(3)},
+		human("h2"): {Games: 10, Wins: 8},
+		human("h3"): {Games: 10, Wins: 1, SpymasterGames: 1, LastSpymaster: at(1)},
+		human("h4"): {Games: 10, Wins: 2, SpymasterGames: 1, LastSpymaster: at(2)},
+		robot("r1"): {Games: 10, Wins: 5, SpymasterGames: 5, LastSpymaster: at(3)},
+		robot("r2"): {Games: 10, Wins: 5, SpymasterGames: 5, LastSpymaster: at(3)},
+	}
+	open := map[codenames.Team]bool{codenames.RedTeam: true, codenames.BlueTeam: true}
+
+	got := balancedAssignments(codenames.DefaultTeams, unassigned, unassigned, open, records)
+	want := []*codenames.PlayerRole{
+		// The players that haven't been spymaster for the longest get to go.
+		{PlayerID: human("h2"), Team: codenames.RedTeam, Role: codenames.SpymasterRole},
+		{PlayerID: human("h3"), Team: codenames.BlueTeam, Role: codenames.SpymasterRole},
+		// Then the robots get split up, and the rest even out the teams.
+		{PlayerID: robot("r1"), Team: codenames.BlueTeam, Role: codenames.OperativeRole},
+		{PlayerID: robot("r2"), Team: codenames.RedTeam, Role: codenames.OperativeRole},
+		{PlayerID: human("h1"), Team: codenames.BlueTeam, Role: codenames.OperativeRole},
+		{PlayerID: human("h4"), Team: codenames.RedTeam, Role: codenames.OperativeRole},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected assignments (-want +got)\n%s", diff)
+	}
+}
+
+func TestBalancedStart(t *testing.T) {
+	env := setup()
+
+	for _, name := range []string{"Player 0", "Player 1", "Player 2", "Player 3"} {
+		env.createUser(t, name)
+	}
+
+	// Play a quick game where players 0 and 2 are spymasters.
+	gID := env.createGame(t, 0)
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, gID, i)
+	}
+	env.assignRole(t, gID, 0, "user_0", codenames.SpymasterRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_1", codenames.OperativeRole, codenames.RedTeam)
+	env.assignRole(t, gID, 0, "user_2", codenames.SpymasterRole, codenames.BlueTeam)
+	env.assignRole(t, gID, 0, "user_3", codenames.OperativeRole, codenames.BlueTeam)
+	env.startGame(t, gID, 0)
+
+	g := env.game(t, gID, 0)
+	spymaster := 0
+	if g.State.ActiveTeam == codenames.BlueTeam {
+		spymaster = 2
+	}
+	for _, card := range g.State.Board.Cards {
+		if card.Agent == codenames.Assassin {
+			env.giveClue(t, gID, spymaster, &codenames.Clue{Word: "oops", Count: 1})
+			env.guess(t, gID, spymaster+1, card.Codename)
+		}
+	}
+
+	// In the next game, the other two get a turn as spymaster.
+	gID = env.createGame(t, 0)
+	for i := 0; i < 4; i++ {
+		env.joinGame(t, gID, i)
+	}
+	req := struct {
+		RandomAssignment   bool               `json:"random_assignment"`
+		AssignmentStrategy AssignmentStrategy `json:"assignment_strategy"`
+	}{true, BalancedAssignment}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/start", toBody(t, req))
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, 0)
+	handler := env.srv.requireGameAuth(env.srv.serveStartGame, isGameCreator(), isGamePending(), locksGame())
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to start game: %v", err)
+	}
+
+	var spymasters []string
+	for _, p := range env.players(t, gID, 0) {
+		if p.Role == codenames.SpymasterRole {
+			spymasters = append(spymasters, p.PlayerID.ID)
+		}
+	}
+	sort.Strings(spymasters)
+	if diff := cmp.Diff([]string{"user_1", "user_3"}, spymasters); diff != "" {
+		t.Errorf("unexpected spymasters (-want +got)\n%s", diff)
+	}
 }
 
 func human(uID codenames.UserID) codenames.PlayerID {
@@ -200,8 +2062,31 @@ func (env *testEnv) user(t *testing.T, authIdx int) *codenames.User {
 }
 
 func (env *testEnv) createGame(t *testing.T, authIdx int) codenames.GameID {
+	return env.createGameWithMode(t, authIdx, "")
+}
+
+func (env *testEnv) createGameWithMode(t *testing.T, authIdx int, mode codenames.GameMode) codenames.GameID {
+	return env.createGameWithTeams(t, authIdx, mode, 0)
+}
+
+func (env *testEnv) createGameWithTeams(t *testing.T, authIdx int, mode codenames.GameMode, numTeams int) codenames.GameID {
+	return env.createGameWithReq(t, authIdx, &createGameReq{Mode: mode, NumTeams: numTeams})
+}
+
+type createGameReq struct {
+	Mode      codenames.GameMode    `json:"mode"`
+	NumTeams  int                   `json:"num_teams"`
+	BoardSpec *codenames.BoardSpec  `json:"board_spec"`
+	Board     string                `json:"board"`
+	Timers    *codenames.TurnTimers `json:"timers"`
+
+	AllowSpymasterSpectators bool `json:"allow_spymaster_spectators"`
+	Unlisted                 bool `json:"unlisted"`
+}
+
+func (env *testEnv) createGameWithReq(t *testing.T, authIdx int, req *createGameReq) codenames.GameID {
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodPost, "/api/game", nil)
+	r := httptest.NewRequest(http.MethodPost, "/api/game", toBody(t, req))
 	env.addAuth(r, authIdx)
 
 	if err := env.srv.serveCreateGame(w, r); err != nil {
@@ -216,16 +2101,24 @@ func (env *testEnv) createGame(t *testing.T, authIdx int) codenames.GameID {
 }
 
 func (env *testEnv) pendingGames(t *testing.T) []codenames.GameID {
+	var gIDs []codenames.GameID
+	for _, gs := range env.listGames(t, "").Games {
+		gIDs = append(gIDs, gs.ID)
+	}
+	return gIDs
+}
+
+func (env *testEnv) listGames(t *testing.T, query string) *GameListing {
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/games", nil)
+	r := httptest.NewRequest(http.MethodGet, "/api/games?"+query, nil)
 
-	if err := env.srv.servePendingGames(w, r); err != nil {
-		t.Fatalf("failed to get pending games: %v", err)
+	if err := env.srv.serveListGames(w, r); err != nil {
+		t.Fatalf("failed to list games: %v", err)
 	}
 
-	var resp []codenames.GameID
+	var resp GameListing
 	fromBody(t, w, &resp)
-	return resp
+	return &resp
 }
 
 func (env *testEnv) joinGame(t *testing.T, gID codenames.GameID, authIdx int) {
@@ -238,12 +2131,131 @@ func (env *testEnv) joinGame(t *testing.T, gID codenames.GameID, authIdx int) {
 	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
 	env.addAuth(r, authIdx)
 
-	handler := env.srv.requireGameAuth(env.srv.serveJoinGame, isGamePending())
+	handler := env.srv.requireGameAuth(env.srv.serveJoinGame, isGamePending(), locksGame())
 	if err := handler(w, r); err != nil {
 		t.Fatalf("failed to join game: %v", err)
 	}
 }
 
+func (env *testEnv) spectate(t *testing.T, gID codenames.GameID, authIdx int, spymasterView bool) {
+	req := struct {
+		SpymasterView bool `json:"spymaster_view"`
+	}{spymasterView}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/spectate", toBody(t, req))
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveSpectate)
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to spectate game: %v", err)
+	}
+}
+
+func (env *testEnv) leave(t *testing.T, gID codenames.GameID, authIdx int) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/leave", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveLeaveGame, locksGame())
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to leave game: %v", err)
+	}
+}
+
+func (env *testEnv) chat(gID codenames.GameID, authIdx int, scope codenames.ChatScope, msg string) error {
+	req := struct {
+		Message string              `json:"message"`
+		Scope   codenames.ChatScope `json:"scope"`
+	}{msg, scope}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(req); err != nil {
+		return err
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/chat", &buf)
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	return env.srv.requireGameAuth(env.srv.serveChat)(w, r)
+}
+
+func (env *testEnv) chatHistory(t *testing.T, gID codenames.GameID, authIdx int) []*ChatMessage {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/game/"+string(gID)+"/chat", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveChatHistory)
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to get chat history: %v", err)
+	}
+
+	var resp []*ChatMessage
+	fromBody(t, w, &resp)
+	return resp
+}
+
+func (env *testEnv) kick(gID codenames.GameID, authIdx int, userID string) error {
+	req := struct {
+		PlayerID codenames.PlayerID `json:"player_id"`
+	}{codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: userID}}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(req); err != nil {
+		return err
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/kick", &buf)
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	return env.srv.requireGameAuth(env.srv.serveKickPlayer, isGameCreator(), locksGame())(w, r)
+}
+
+func (env *testEnv) transferOwner(t *testing.T, gID codenames.GameID, authIdx int, userID string) {
+	req := struct {
+		PlayerID codenames.PlayerID `json:"player_id"`
+	}{codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: userID}}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/transferOwner", toBody(t, req))
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveTransferOwner, isGameCreator())
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to transfer game: %v", err)
+	}
+}
+
+func (env *testEnv) rematch(t *testing.T, gID codenames.GameID, authIdx int, alternateStarter bool) codenames.GameID {
+	req := struct {
+		AlternateStarter bool `json:"alternate_starter"`
+	}{alternateStarter}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/rematch", toBody(t, req))
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveRematch, isGameStatus(codenames.Finished))
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to start rematch: %v", err)
+	}
+
+	var resp struct {
+		ID string `json:"id"`
+	}
+	fromBody(t, w, &resp)
+	return codenames.GameID(resp.ID)
+}
+
 func (env *testEnv) players(t *testing.T, gID codenames.GameID, authIdx int) []*Player {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/game/"+string(gID)+"/players", nil)
@@ -260,6 +2272,28 @@ func (env *testEnv) players(t *testing.T, gID codenames.GameID, authIdx int) []*
 	return resp
 }
 
+// waitForOnline waits until the /players endpoint shows the given user as
+// online or offline, since connections are registered and unregistered in the
+// background.
+func (env *testEnv) waitForOnline(t *testing.T, gID codenames.GameID, userID string, want bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var got bool
+		for _, p := range env.players(t, gID, 0) {
+			if p.PlayerID.ID == userID {
+				got = p.Online
+			}
+		}
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("player %q had online = %t, want %t", userID, got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
 func (env *testEnv) assignRole(t *testing.T, gID codenames.GameID, authIdx int, userID string, role codenames.Role, team codenames.Team) {
 	pID := codenames.PlayerID{
 		PlayerType: codenames.PlayerTypeHuman,
@@ -276,12 +2310,47 @@ func (env *testEnv) assignRole(t *testing.T, gID codenames.GameID, authIdx int,
 	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
 	env.addAuth(r, authIdx)
 
-	handler := env.srv.requireGameAuth(env.srv.serveAssignRole, isGameCreator(), isGamePending())
+	handler := env.srv.requireGameAuth(env.srv.serveAssignRole, isGameCreator(), isGamePending(), locksGame())
 	if err := handler(w, r); err != nil {
 		t.Fatalf("failed to assign role: %v", err)
 	}
 }
 
+func (env *testEnv) chooseRole(gID codenames.GameID, authIdx int, team codenames.Team, role codenames.Role) error {
+	req := struct {
+		Team string `json:"team"`
+		Role string `json:"role"`
+	}{string(team), string(role)}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(req); err != nil {
+		return err
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/chooseRole", &buf)
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	return env.srv.requireGameAuth(env.srv.serveChooseRole, isGamePending(), locksGame())(w, r)
+}
+
+func (env *testEnv) lockTeams(t *testing.T, gID codenames.GameID, authIdx int, locked bool) {
+	req := struct {
+		Locked bool `json:"locked"`
+	}{locked}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/lockTeams", toBody(t, req))
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveLockTeams, isGameCreator(), isGamePending(), locksGame())
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to lock teams: %v", err)
+	}
+}
+
 func (env *testEnv) startGame(t *testing.T, gID codenames.GameID, authIdx int) {
 	req := struct {
 		RandomAssignment bool `json:"random_assignment"`
@@ -292,12 +2361,312 @@ func (env *testEnv) startGame(t *testing.T, gID codenames.GameID, authIdx int) {
 	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
 	env.addAuth(r, authIdx)
 
-	handler := env.srv.requireGameAuth(env.srv.serveStartGame, isGameCreator(), isGamePending())
+	handler := env.srv.requireGameAuth(env.srv.serveStartGame, isGameCreator(), isGamePending(), locksGame())
 	if err := handler(w, r); err != nil {
 		t.Fatalf("failed to start game: %v", err)
 	}
 }
 
+func (env *testEnv) giveClue(t *testing.T, gID codenames.GameID, authIdx int, clue *codenames.Clue) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/clue", toBody(t, clue))
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveClue, isSpymaster(), anyRoleInDuet(), isGamePlaying(), locksGame())
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to give clue: %v", err)
+	}
+}
+
+func (env *testEnv) guess(t *testing.T, gID codenames.GameID, authIdx int, guess string) {
+	req := struct {
+		Guess     string `json:"guess"`
+		Confirmed bool   `json:"confirmed"`
+	}{guess, true}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/guess", toBody(t, req))
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveGuess, isOperative(), anyRoleInDuet(), isGamePlaying(), locksGame())
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to guess: %v", err)
+	}
+}
+
+func (env *testEnv) pass(t *testing.T, gID codenames.GameID, authIdx int) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/game/"+string(gID)+"/pass", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.servePass, isOperative(), anyRoleInDuet(), isGamePlaying(), locksGame())
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to pass: %v", err)
+	}
+}
+
+func (env *testEnv) userGames(t *testing.T, authIdx, limit, offset int) *PlayerGames {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/user/games?limit=%d&offset=%d", limit, offset), nil)
+	env.addAuth(r, authIdx)
+
+	if err := env.srv.serveUserGames(w, r); err != nil {
+		t.Fatalf("failed to get games: %v", err)
+	}
+
+	var resp PlayerGames
+	fromBody(t, w, &resp)
+	return &resp
+}
+
+func (env *testEnv) leaderboard(t *testing.T, role string) []*RatedPlayer {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/leaderboard?role="+role, nil)
+
+	if err := env.srv.serveLeaderboard(w, r); err != nil {
+		t.Fatalf("failed to get leaderboard: %v", err)
+	}
+
+	var resp []*RatedPlayer
+	fromBody(t, w, &resp)
+	return resp
+}
+
+func (env *testEnv) stats(t *testing.T, userID string) *PlayerStats {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/user/"+userID+"/stats", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": userID})
+
+	if err := env.srv.serveUserStats(w, r); err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+
+	var resp PlayerStats
+	fromBody(t, w, &resp)
+	return &resp
+}
+
+func (env *testEnv) game(t *testing.T, gID codenames.GameID, authIdx int) *codenames.Game {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/game/"+string(gID), nil)
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveGame)
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to get game: %v", err)
+	}
+
+	var resp codenames.Game
+	fromBody(t, w, &resp)
+	return &resp
+}
+
+func (env *testEnv) history(t *testing.T, gID codenames.GameID, authIdx int) []*HistoryStep {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/game/"+string(gID)+"/history", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": string(gID)})
+	env.addAuth(r, authIdx)
+
+	handler := env.srv.requireGameAuth(env.srv.serveGameHistory)
+	if err := handler(w, r); err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+
+	var resp []*HistoryStep
+	fromBody(t, w, &resp)
+	return resp
+}
+
+// connect opens a WebSocket to the game as the given user, and waits until the
+// server is sending messages to it.
+func (env *testEnv) connect(t *testing.T, ts *httptest.Server, gID codenames.GameID, authIdx int) *websocket.Conn {
+	conn := env.dial(t, ts, "/api/game/"+string(gID)+"/ws", authIdx)
+
+	// The connection is registered with the hub in the background, so keep
+	// pinging it until it's listening.
+	pID := codenames.PlayerID{PlayerType: codenames.PlayerTypeHuman, ID: fmt.Sprintf("user_%d", authIdx)}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+				env.srv.hub.ToPlayer(gID, pID, map[string]string{"action": testPing})
+			}
+		}
+	}()
+	env.readMsg(t, conn, testPing, nil)
+
+	return conn
+}
+
+// reconnect opens a WebSocket to the game as the given user, asking for the
+// messages sent after the given sequence number in the given epoch. Those are
+// sent before anything else, so unlike connect, there's no need to wait for it
+// to be registered.
+func (env *testEnv) reconnect(t *testing.T, ts *httptest.Server, gID codenames.GameID, authIdx int, epoch string, since uint64) *websocket.Conn {
+	return env.dial(t, ts, fmt.Sprintf("/api/game/%s/ws?epoch=%s&since=%d", gID, url.QueryEscape(epoch), since), authIdx)
+}
+
+func (env *testEnv) dial(t *testing.T, ts *httptest.Server, path string, authIdx int) *websocket.Conn {
+	addr := "ws" + strings.TrimPrefix(ts.URL, "http") + path
+	header := http.Header{}
+	header.Add("Cookie", (&http.Cookie{Name: "Authorization", Value: env.userAuth[authIdx]}).String())
+
+	conn, _, err := websocket.DefaultDialer.Dial(addr, header)
+	if err != nil {
+		t.Fatalf("failed to connect to game: %v", err)
+	}
+	return conn
+}
+
+const testPing = "TEST_PING"
+
+var skippedActions = map[string]bool{
+	testPing:         true,
+	"CONNECTED":      true,
+	"PLAYER_ONLINE":  true,
+	"PLAYER_OFFLINE": true,
+}
+
+// readMsg reads the next message from the connection, which should have the
+// given action, and decodes it into v. Greetings and leftover pings from
+// connect are skipped, as are players coming and going, unless that's what
+// we're waiting for.
+func (env *testEnv) readMsg(t *testing.T, conn *websocket.Conn, action string, v interface{}) {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, dat, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read %q message: %v", action, err)
+		}
+
+		var msg struct {
+			Action string `json:"action"`
+		}
+		if err := json.Unmarshal(dat, &msg); err != nil {
+			t.Fatalf("failed to decode message action: %v", err)
+		}
+		if msg.Action != action && skippedActions[msg.Action] {
+			continue
+		}
+		if msg.Action != action {
+			t.Fatalf("got %q message, want %q: %s", msg.Action, action, dat)
+		}
+		if v == nil {
+			return
+		}
+		if err := json.Unmarshal(dat, v); err != nil {
+			t.Fatalf("failed to decode %q message: %v", action, err)
+		}
+		return
+	}
+}
+
+// testEvents is a Server-Sent Events stream for a game.
+type testEvents struct {
+	body   io.Closer
+	events chan *testEvent
+}
+
+type testEvent struct {
+	id   string
+	data []byte
+}
+
+func (te *testEvents) Close() {
+	te.body.Close()
+}
+
+// events opens an event stream for the game as the given user, resuming after
+// lastID if it's set.
+func (env *testEnv) events(t *testing.T, ts *httptest.Server, gID codenames.GameID, authIdx int, lastID string) *testEvents {
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/game/"+string(gID)+"/events", nil)
+	if err != nil {
+		t.Fatalf("failed to form request: %v", err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	env.addAuth(req, authIdx)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open event stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		t.Fatalf("event stream had status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("event stream had content type %q, want %q", ct, "text/event-stream")
+	}
+
+	te := &testEvents{body: resp.Body, events: make(chan *testEvent, 100)}
+	go func() {
+		defer close(te.events)
+		sc := bufio.NewScanner(resp.Body)
+		ev := &testEvent{}
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "":
+				if len(ev.data) > 0 {
+					te.events <- ev
+				}
+				ev = &testEvent{}
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = append(ev.data, strings.TrimPrefix(line, "data: ")...)
+			}
+		}
+	}()
+	return te
+}
+
+// readEvent is like readMsg, but for event streams. It returns the ID of the
+// event.
+func (env *testEnv) readEvent(t *testing.T, te *testEvents, action string, v interface{}) string {
+	timeout := time.After(5 * time.Second)
+	for {
+		var ev *testEvent
+		select {
+		case ev = <-te.events:
+			if ev == nil {
+				t.Fatalf("event stream closed while waiting for %q", action)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q event", action)
+		}
+
+		var msg struct {
+			Action string `json:"action"`
+		}
+		if err := json.Unmarshal(ev.data, &msg); err != nil {
+			t.Fatalf("failed to decode event action: %v", err)
+		}
+		if msg.Action != action && skippedActions[msg.Action] {
+			continue
+		}
+		if msg.Action != action {
+			t.Fatalf("got %q event, want %q: %s", msg.Action, action, ev.data)
+		}
+		if v != nil {
+			if err := json.Unmarshal(ev.data, v); err != nil {
+				t.Fatalf("failed to decode %q event: %v", action, err)
+			}
+		}
+		return ev.id
+	}
+}
+
 func (env *testEnv) addAuth(r *http.Request, authIdx int) {
 	r.AddCookie(&http.Cookie{
 		Name:  "Authorization",
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/bcspragu/Codenames/codenames"
+	"github.com/bcspragu/Codenames/httperr"
+	"github.com/bcspragu/Codenames/hub"
+	"github.com/gorilla/mux"
+)
+
+// WSProtocolVersion is the version of the protocol for actions that players
+// send over their WebSocket connection to a game. Every request includes it,
+// so we can change the protocol later without breaking old clients.
+const WSProtocolVersion = 1
+
+// WSRequest is the header of an action a player sends over their WebSocket
+// connection. The rest of the request has the same fields as the body of the
+// equivalent HTTP request, e.g.
+//
+//	{"v": 1, "id": "1", "action": "CLUE", "word": "muffins", "count": 3}
+//
+// The server responds with an Ack or an ActionError with the same ID.
+type WSRequest struct {
+	Version int    `json:"v"`
+	ID      string `json:"id"`
+	Action  string `json:"action"`
+}
+
+// wsResponse collects what a handler would have written back over HTTP, so it
+// can be sent back over a WebSocket instead.
+type wsResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (wr *wsResponse) Header() http.Header         { return wr.header }
+func (wr *wsResponse) Write(b []byte) (int, error) { return wr.body.Write(b) }
+func (wr *wsResponse) WriteHeader(code int)        { wr.code = code }
+
+// wsHandler returns a handler for actions sent over a WebSocket connection to
+// the given game. Each action is passed through the same handler as the
+// equivalent HTTP endpoint, as the player that opened the connection.
+func (s *Srv) wsHandler(r *http.Request, gID codenames.GameID) hub.MessageHandler {
+	return func(msg []byte, reply func(interface{}) error) {
+		var req WSRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			s.replyWSError(reply, "", httperr.
+				BadRequest("failed to decode WebSocket request: %w", err).
+				WithMessage("malformed request"))
+			return
+		}
+
+		if req.Version != WSProtocolVersion {
+			s.replyWSError(reply, req.ID, httperr.
+				BadRequest("WebSocket request had unsupported version %d", req.Version).
+				WithMessage("unsupported protocol version"))
+			return
+		}
+
+		action, ok := s.wsActions[req.Action]
+		if !ok {
+			s.replyWSError(reply, req.ID, httperr.
+				BadRequest("WebSocket request had unknown action %q", req.Action).
+				WithMessage("unknown action"))
+			return
+		}
+
+		hr, err := http.NewRequest(http.MethodPost, action.path, bytes.NewReader(msg))
+		if err != nil {
+			s.replyWSError(reply, req.ID, httperr.Internal("failed to form request for WebSocket action: %w", err))
+			return
+		}
+		// The connection was authenticated when it was opened, and the handler
+		// loads the player the same way.
+		for _, c := range r.Cookies() {
+			hr.AddCookie(c)
+		}
+		hr = mux.SetURLVars(hr, map[string]string{"id": string(gID)})
+
+		resp := &wsResponse{header: make(http.Header), code: http.StatusOK}
+		if err := action.handlerFunc(resp, hr); err != nil {
+			s.replyWSError(reply, req.ID, err)
+			return
+		}
+
+		ack := &Ack{ID: req.ID}
+		if data := bytes.TrimSpace(resp.body.Bytes()); len(data) > 0 {
+			ack.Data = data
+		}
+		if err := reply(ack); err != nil {
+			log.Printf("failed to ack WebSocket request %q: %v", req.ID, err)
+		}
+	}
+}
+
+func (s *Srv) replyWSError(reply func(interface{}) error, id string, err error) {
+	log.Println(err)
+	code, msg := httperr.Extract(err)
+	if err := reply(&ActionError{ID: id, Code: code, Message: msg}); err != nil {
+		log.Printf("failed to send error for WebSocket request %q: %v", id, err)
+	}
+}